@@ -0,0 +1,143 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// config.go is the cobra command to get, set, and view the global configuration file
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/config"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"text/tabwriter"
+)
+
+// configRootCmd runs `klone config`. The klone command takes a query as it's first
+// argument, so config can not be one of it's subcommands.
+var configRootCmd = &cobra.Command{
+	Use: "klone",
+}
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Get, set, and view the configuration in ~/.klone/config.yaml",
+	Long: `The configuration file holds the defaults for every klone. A flag wins over an environmental
+variable, and an environmental variable wins over the configuration file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a key",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			exitConfig(fmt.Errorf("usage: klone config get <key>"))
+		}
+		if _, err := config.Lookup(args[0]); err != nil {
+			exitConfig(err)
+		}
+		c, err := config.Load(config.File)
+		if err != nil {
+			exitConfig(err)
+		}
+		value, ok := c.Get(args[0])
+		if !ok {
+			os.Exit(1)
+		}
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set the value of a key, an empty value removes it",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			exitConfig(fmt.Errorf("usage: klone config set <key> <value>"))
+		}
+		c, err := config.Load(config.File)
+		if err != nil {
+			exitConfig(err)
+		}
+		if args[1] == "" {
+			err = c.Unset(args[0])
+		} else {
+			err = c.Set(args[0], args[1])
+		}
+		if err != nil {
+			exitConfig(err)
+		}
+		err = c.Save(config.File)
+		if err != nil {
+			exitConfig(err)
+		}
+	},
+}
+
+var viewKeys bool
+
+var configViewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Print the configuration file ( or every key we understand with --keys )",
+	Run: func(cmd *cobra.Command, args []string) {
+		if viewKeys {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tFLAG\tENV\tDESCRIPTION")
+			for _, s := range config.Settings {
+				flag := ""
+				if s.Flag != "" {
+					flag = fmt.Sprintf("--%s", s.Flag)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, flag, s.Env, s.Description)
+			}
+			w.Flush()
+			return
+		}
+		if _, err := config.Load(config.File); err != nil {
+			exitConfig(err)
+		}
+		content, err := ioutil.ReadFile(config.File)
+		if err != nil && !os.IsNotExist(err) {
+			exitConfig(err)
+		}
+		fmt.Print(string(content))
+	},
+}
+
+func init() {
+	configViewCmd.Flags().BoolVar(&viewKeys, "keys", false, "Print every key we understand, and the flag and environmental variable that win over it")
+	ConfigCmd.AddCommand(configGetCmd, configSetCmd, configViewCmd)
+	configRootCmd.AddCommand(ConfigCmd)
+}
+
+// isConfig is true if we were asked to run `klone config`
+func isConfig(args []string) bool {
+	return len(args) > 1 && args[1] == ConfigCmd.Name()
+}
+
+func exitConfig(err error) {
+	local.PrintError(err)
+	os.Exit(2)
+}
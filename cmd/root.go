@@ -23,52 +23,143 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/kris-nova/klone/pkg/auth"
+	"github.com/kris-nova/klone/pkg/config"
 	"github.com/kris-nova/klone/pkg/container"
 	"github.com/kris-nova/klone/pkg/klone"
+	"github.com/kris-nova/klone/pkg/klone/kloners/gogit"
+	"github.com/kris-nova/klone/pkg/klone/kloners/javascript"
+	"github.com/kris-nova/klone/pkg/klone/kloners/jvm"
+	"github.com/kris-nova/klone/pkg/klone/kloners/python"
+	"github.com/kris-nova/klone/pkg/klone/kloners/rust"
 	"github.com/kris-nova/klone/pkg/local"
 	"github.com/spf13/cobra"
 	"os"
 )
 
 var RootCmd = &cobra.Command{
-	Use:   "klone",
-	Short: "klone <query>",
-	Long:  `klone provides easy functionality to begin working, running, and contributing to software repositories.`,
-	Run:   runKlone,
+	Use:              "klone",
+	Short:            "klone <query>",
+	Long:             "klone provides easy functionality to begin working, running, and contributing to software repositories.\n\nRun `klone config` to set the defaults for every klone in ~/.klone/config.yaml.\n\nKlone ships no GitHub OAuth App, so the OAuth device flow needs the client ID of your own in KLONE_GITHUBCLIENTID. Without it klone asks for a personal access token (or reads KLONE_GITHUBTOKEN).",
+	PersistentPreRun: preRunKlone,
+	Run:              runKlone,
 }
 
 func Execute() {
-	if err := RootCmd.Execute(); err != nil {
+	cmd := RootCmd
+	if isConfig(os.Args) {
+		cmd = configRootCmd
+	}
+	if err := cmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(-1)
 	}
 }
 
+var (
+	dryRun bool
+	output string
+)
+
+// klonefileImage is the --container that asks for the image in the repository's Klonefile
+const klonefileImage = "klonefile"
+
 var containerOptions = &container.Options{
 	//Command: []string{"sleep", "10"},
 	Command: []string{"/bin/bash"},
 }
 
 func init() {
-	RootCmd.Flags().StringVarP(&auth.OptPrivateKey, "identity-file", "i", "~/.ssh/id_rsa", "The private key to use for a git clone operation.")
+	RootCmd.Flags().StringVarP(&auth.OptPrivateKey, "identity-file", "i", "", "The private key to use for a git clone operation ( default: ~/.ssh/config, or ~/.ssh/id_{ed25519,ecdsa,rsa} )")
+	RootCmd.Flags().StringVar(&auth.OptTransport, "transport", auth.TransportSSH, "How to clone, fetch, and push ( auto, ssh, https )")
+	RootCmd.Flags().StringSliceVar(&auth.OptHostTransports, "host-transport", []string{}, "The transport for a host, E.G. github.com=https ( comma separated )")
 	RootCmd.Flags().BoolVarP(&klone.RefreshCredentials, "refresh-credentials", "r", false, "Hard reset local credential cache")
-	RootCmd.Flags().StringVarP(&containerOptions.Image, "container", "c", "", "Run the klone in a container, and use the image string defined")
+	RootCmd.Flags().StringVarP(&containerOptions.Image, "container", "c", "", "Run the klone in a container, and use the image string defined ( or klonefile for the image the repository asks for )")
 	RootCmd.Flags().StringSliceVarP(&containerOptions.Command, "container-command", "x", []string{"/bin/bash"}, "The command to run in the container that we are kloning into.")
-	RootCmd.Flags().StringVarP(&klone.ForceKloner, "kloner", "k", "", "Will force a kloner implementation ( simple, gogit )")
-	local.PrintStartBanner()
+	RootCmd.Flags().StringVarP(&klone.ForceKloner, "kloner", "k", "", "Will force a kloner implementation ( simple, gogit, rust, python, javascript, jvm )")
+	RootCmd.Flags().StringVar(&gogit.Mode, "go-mode", gogit.ModeGopath, "Where the Go kloner puts repositories ( gopath, module )")
+	RootCmd.Flags().StringVar(&gogit.Workspace, "go-workspace", "", "Where to klone Go repositories in module mode ( defaults to $KLONE_WORKSPACE, or the current directory )")
+	RootCmd.Flags().StringVar(&gogit.GoWork, "go-work", "", "A go.work file to add Go repositories to in module mode")
+	RootCmd.Flags().StringVar(&rust.Root, "rust-root", "~/src/rust", "Where to klone Rust repositories, as $root/$owner/$name")
+	RootCmd.Flags().BoolVar(&rust.Fetch, "cargo-fetch", false, "Run cargo fetch after kloning a Rust repository")
+	RootCmd.Flags().StringVar(&python.Root, "python-root", "~/src/python", "Where to klone Python repositories, as $root/$owner/$name")
+	RootCmd.Flags().BoolVar(&python.Venv, "python-venv", true, "Create a .venv after kloning a Python repository")
+	RootCmd.Flags().BoolVar(&python.Install, "pip-install", false, "Install the requirements.txt and pyproject.toml (editable) dependencies of a Python repository into it's .venv")
+	RootCmd.Flags().BoolVar(&javascript.Install, "npm-install", false, "Install the dependencies of a JavaScript repository with it's package manager ( npm, yarn, pnpm )")
+	RootCmd.Flags().StringVar(&jvm.Root, "jvm-root", "~/src/jvm", "Where to klone Java and Kotlin repositories, as $root/$group/$name")
+	RootCmd.Flags().BoolVar(&jvm.WarmCache, "jvm-warm-cache", false, "Download the Maven or Gradle dependencies of a Java or Kotlin repository after kloning it")
+	RootCmd.Flags().BoolVar(&klone.TrustKlonefile, "trust-klonefile", false, "Run the commands in a repository's .Klonefile after the klone, and use it's path outside of the workspace")
+	RootCmd.Flags().BoolVar(&klone.KeepPartial, "keep-partial", false, "Keep the work of a klone that fails instead of rolling it back")
+	RootCmd.Flags().StringVar(&klone.NoForkRemote, "no-fork-remote", "origin", "The remote to register when the git server is unable to fork ( origin, upstream )")
+	RootCmd.Flags().StringVar(&klone.OriginRemote, "origin-remote", "origin", "What to call the remote we push to")
+	RootCmd.Flags().StringVar(&klone.UpstreamRemote, "upstream-remote", "upstream", "What to call the remote we forked from")
+	RootCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what klone would do, without changing anything on the git server or on disk")
+	RootCmd.Flags().StringVarP(&output, "output", "o", "text", "The format to print a --dry-run in ( text, json )")
 	RootCmd.SetUsageTemplate(UsageTemplate)
+	help := RootCmd.HelpFunc()
+	RootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		local.PrintStartBanner()
+		help(cmd, args)
+	})
 	if len(os.Args) <= 1 {
 		RootCmd.Help()
 		os.Exit(0)
 	}
 }
 
+// preRunKlone runs once our flags are parsed. Machine readable output gets
+// STDOUT to itself, so everything else (including the banner) goes to STDERR.
+// The configuration file fills in anything we did not get from a flag (or the environment).
+func preRunKlone(cmd *cobra.Command, args []string) {
+	c, err := config.Load(config.File)
+	if err == nil {
+		err = c.Apply(cmd.Flags())
+	}
+	if output == "json" {
+		local.PrintToStderr()
+	}
+	local.PrintStartBanner()
+	if err != nil {
+		local.PrintError(err)
+		os.Exit(2)
+	}
+}
+
 func runKlone(cmd *cobra.Command, args []string) {
-	local.SPutContent(local.Version, fmt.Sprintf("%s/.klone/version", local.Home()))
 	query := args[0]
+	if gogit.Mode != gogit.ModeGopath && gogit.Mode != gogit.ModeModule {
+		local.PrintError(fmt.Errorf("unknown go mode [%s]", gogit.Mode))
+		os.Exit(4)
+	}
+	err := auth.ValidateTransports()
+	if err != nil {
+		local.PrintError(err)
+		os.Exit(4)
+	}
+	if klone.OriginRemote == klone.UpstreamRemote {
+		local.PrintError(fmt.Errorf("origin and upstream remotes can not both be named [%s]", klone.OriginRemote))
+		os.Exit(4)
+	}
+	if dryRun {
+		err := printPlan(query)
+		if err != nil {
+			local.PrintError(err)
+			os.Exit(4)
+		}
+		return
+	}
+	local.SPutContent(local.Version, fmt.Sprintf("%s/.klone/version", local.Home()))
 	if containerOptions.Image != "" {
+		if containerOptions.Image == klonefileImage {
+			image, err := klone.KlonefileContainer(query)
+			if err != nil {
+				local.PrintError(err)
+				os.Exit(3)
+			}
+			containerOptions.Image = image
+		}
 		containerOptions.Query = query
 		err := container.Run(containerOptions)
 		if err != nil {
@@ -84,6 +175,27 @@ func runKlone(cmd *cobra.Command, args []string) {
 	}
 }
 
+// printPlan will print what a klone would do in the --output format
+func printPlan(query string) error {
+	plan, err := klone.Plan(query)
+	if err != nil {
+		return err
+	}
+	switch output {
+	case "json":
+		b, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "text":
+		fmt.Print(plan)
+	default:
+		return fmt.Errorf("unknown output format [%s]", output)
+	}
+	return nil
+}
+
 const UsageTemplate = `Usage:{{if .Runnable}}
   {{if .HasAvailableFlags}}{{appendIfNotPresent .UseLine "<query> [flags]"}}{{else}}{{.UseLine}}{{end}}{{end}}{{if .HasAvailableSubCommands}}
   {{ .CommandPath}} [command]{{end}}{{if gt .Aliases 0}}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	TransportAuto  = "auto"  // SSH if we have a key and can reach the host over SSH, otherwise HTTPS
+	TransportSSH   = "ssh"   // Always SSH
+	TransportHTTPS = "https" // Always HTTPS, with the access token of the git server
+)
+
+// OptTransport is how we talk to every git server, defined in /cmd
+var OptTransport = TransportSSH
+
+// OptHostTransports are the transports for hosts (E.G. github.com=https), defined in /cmd
+var OptHostTransports []string
+
+// SSHDialTimeout is how long auto will wait to reach a host over SSH
+var SSHDialTimeout = 3 * time.Second
+
+// dialSSH will check we can reach a host over SSH
+var dialSSH = func(address string) error {
+	conn, err := net.DialTimeout("tcp", address, SSHDialTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// transports are the transports auto has decided on for each host
+var transports = make(map[string]string)
+
+// httpsCredentials are the basic auth credentials for each host
+var httpsCredentials = make(map[string]*http.BasicAuth)
+
+// ValidateTransports will check every transport we have been given
+func ValidateTransports() error {
+	err := validateTransport(OptTransport)
+	if err != nil {
+		return err
+	}
+	for _, hostTransport := range OptHostTransports {
+		parts := strings.SplitN(hostTransport, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid host transport [%s] ( host=mode )", hostTransport)
+		}
+		err := validateTransport(parts[1])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTransport(mode string) error {
+	switch mode {
+	case TransportAuto, TransportSSH, TransportHTTPS:
+		return nil
+	}
+	return fmt.Errorf("unknown transport [%s] ( %s, %s, %s )", mode, TransportAuto, TransportSSH, TransportHTTPS)
+}
+
+// Transport is the transport we use for a host, ssh or https
+func Transport(host string) string {
+	mode := OptTransport
+	for _, hostTransport := range OptHostTransports {
+		parts := strings.SplitN(hostTransport, "=", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], host) {
+			mode = parts[1]
+		}
+	}
+	if mode != TransportAuto {
+		return mode
+	}
+	if mode, ok := transports[host]; ok {
+		return mode
+	}
+	transports[host] = autoTransport(host)
+	return transports[host]
+}
+
+// UseHTTPS is true if we should clone, fetch, and push to a host over HTTPS
+func UseHTTPS(host string) bool {
+	return Transport(host) == TransportHTTPS
+}
+
+// autoTransport will use SSH if we have a key for the host, and we are able to reach it
+func autoTransport(host string) string {
+	h := LoadSSHConfig(SSHConfigFile).Host(host)
+	if !haveSSHKeys(h) {
+		local.Printf("No SSH keys for [%s], using HTTPS", host)
+		return TransportHTTPS
+	}
+	port := h.Port
+	if port == 0 {
+		port = 22
+	}
+	err := dialSSH(fmt.Sprintf("%s:%d", h.HostName, port))
+	if err != nil {
+		local.Printf("Unable to reach [%s] over SSH, using HTTPS: %v", host, err)
+		return TransportHTTPS
+	}
+	return TransportSSH
+}
+
+// haveSSHKeys is true if ssh-agent has a key, or we have a private key for a host. We
+// never load the keys, so we never ask for a passphrase.
+func haveSSHKeys(h *SSHHost) bool {
+	if len(PrivateKeyBytesOverride) > 1 {
+		return true
+	}
+	if a := getAgent(); a != nil {
+		if keys, err := a.List(); err == nil && len(keys) > 0 {
+			return true
+		}
+	}
+	files, _ := identityFiles(h)
+	for _, file := range files {
+		if _, err := os.Stat(local.Expand(file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SetHTTPSCredentials will set the credentials we use for git over HTTPS to a host
+func SetHTTPSCredentials(host, user, password string) {
+	if password == "" {
+		return
+	}
+	httpsCredentials[strings.ToLower(host)] = http.NewBasicAuth(user, password)
+}
+
+// getHTTPSTransport is the basic auth for an HTTPS endpoint, or nil if we have no credentials
+// for it's host. Credentials in the url always win.
+func getHTTPSTransport(ep transport.Endpoint) transport.AuthMethod {
+	if ep.User() != "" {
+		return nil
+	}
+	if a, ok := httpsCredentials[strings.ToLower(ep.Host())]; ok {
+		return a
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"fmt"
+	"gopkg.in/src-d/go-git.v4"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resetTransports will forget every transport and credential we have
+func resetTransports() {
+	OptTransport = TransportSSH
+	OptHostTransports = nil
+	transports = make(map[string]string)
+	httpsCredentials = make(map[string]*githttp.BasicAuth)
+}
+
+func TestTransport(t *testing.T) {
+	defer resetTransports()
+	defer func(files []string) { DefaultIdentityFiles = files }(DefaultIdentityFiles)
+	defer func(sock string) { os.Setenv("SSH_AUTH_SOCK", sock) }(os.Getenv("SSH_AUTH_SOCK"))
+	defer func(f func(string) error) { dialSSH = f }(dialSSH)
+	os.Unsetenv("SSH_AUTH_SOCK")
+	agentClient = nil
+
+	dir, err := ioutil.TempDir("", "klone-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	DefaultIdentityFiles = []string{filepath.Join(dir, "id_ed25519")}
+	dialed := map[string]bool{}
+	dialSSH = func(address string) error {
+		dialed[address] = true
+		if strings.HasPrefix(address, "blocked.example.com") {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+
+	resetTransports()
+	OptHostTransports = []string{"gitlab.com=https", "git.corp.example=auto"}
+	if Transport("github.com") != TransportSSH || !UseHTTPS("gitlab.com") {
+		t.Fatal("Unexpected transports for ssh with a host transport")
+	}
+	if !UseHTTPS("git.corp.example") || len(dialed) != 0 {
+		t.Fatal("Auto used SSH without an SSH key")
+	}
+
+	ioutil.WriteFile(DefaultIdentityFiles[0], []byte(ed25519Encrypted), 0600)
+	resetTransports()
+	OptTransport = TransportAuto
+	if UseHTTPS("github.com") || !dialed["github.com:22"] {
+		t.Fatal("Auto did not use SSH for a host we can reach")
+	}
+	if !UseHTTPS("blocked.example.com") {
+		t.Fatal("Auto used SSH for a host we can not reach")
+	}
+
+	cases := map[string]bool{
+		"":                   false,
+		"https":              true,
+		"telnet":             false,
+		"github.com=https":   true,
+		"github.com":         false,
+		"=https":             false,
+		"github.com=carrier": false,
+	}
+	for transport, valid := range cases {
+		resetTransports()
+		if strings.Contains(transport, "=") || transport == "github.com" {
+			OptHostTransports = []string{transport}
+		} else {
+			OptTransport = transport
+		}
+		if err := ValidateTransports(); (err == nil) != valid {
+			t.Fatalf("Expected valid [%t] for [%s], got: %v", valid, transport, err)
+		}
+	}
+}
+
+// newGitHTTPServer will serve the git repositories in a directory over HTTP with
+// git http-backend, for only one user and password
+func newGitHTTPServer(t *testing.T, dir, user, password string) *httptest.Server {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	backend := &cgi.Handler{
+		Path: git,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + dir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || u != user || p != password {
+			w.Header().Set("WWW-Authenticate", `Basic realm="klone"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+}
+
+func TestGetTransportForUrlHTTPS(t *testing.T) {
+	defer resetTransports()
+	dir, err := ioutil.TempDir("", "klone-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bare := filepath.Join(dir, "service.git")
+	out, err := exec.Command("git", "init", "--bare", bare).CombinedOutput()
+	if err != nil {
+		t.Skipf("Unable to create a repository: %s", out)
+	}
+	work := filepath.Join(dir, "work")
+	for _, args := range [][]string{
+		{"clone", bare, work},
+		{"-C", work, "-c", "user.name=klone", "-c", "user.email=klone@example.com", "commit", "--allow-empty", "-m", "klone"},
+		{"-C", work, "push", "origin", "HEAD"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unable to run git %v: %s", args, out)
+		}
+	}
+
+	server := newGitHTTPServer(t, dir, "oauth2", "token")
+	defer server.Close()
+	remote := server.URL + "/service.git"
+	u, _ := url.Parse(server.URL)
+
+	a, err := GetTransportForUrl(remote)
+	if err != nil || a != nil {
+		t.Fatalf("Found credentials for a host we know nothing about: %v %v", a, err)
+	}
+	_, err = git.PlainClone(filepath.Join(dir, "unauthorized"), false, &git.CloneOptions{URL: remote})
+	if err == nil {
+		t.Fatal("Able to clone without credentials")
+	}
+
+	SetHTTPSCredentials(u.Hostname(), "oauth2", "token")
+	a, err = GetTransportForUrl(remote)
+	if err != nil || a == nil {
+		t.Fatalf("No credentials for [%s]: %v", u.Hostname(), err)
+	}
+	_, err = git.PlainClone(filepath.Join(dir, "cloned"), false, &git.CloneOptions{URL: remote, Auth: a})
+	if err != nil {
+		t.Fatalf("Unable to clone over HTTP with credentials: %v", err)
+	}
+	a, _ = GetTransportForUrl(fmt.Sprintf("http://someone@%s/service.git", u.Host))
+	if a != nil {
+		t.Fatal("Credentials in the url did not win")
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+)
+
+// PassphraseFunc will return the passphrase of an encrypted private key
+type PassphraseFunc func() ([]byte, error)
+
+// ParsePrivateKey will parse a private key in PEM (RSA, ECDSA, DSA, PKCS#8) or
+// OpenSSH (RSA, ECDSA, ed25519) format. passphrase is only called if the key is encrypted.
+func ParsePrivateKey(pemBytes []byte, passphrase PassphraseFunc) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	if _, ok := err.(*ssh.PassphraseMissingError); !ok {
+		return signer, err
+	}
+	if passphrase == nil {
+		return nil, fmt.Errorf("private key is encrypted")
+	}
+	pass, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, pass)
+	if err == x509.IncorrectPasswordError {
+		return nil, fmt.Errorf("incorrect passphrase")
+	}
+	return signer, err
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// KnownHostsFiles are where we verify host keys, unless ~/.ssh/config has a UserKnownHostsFile
+var KnownHostsFiles = []string{"~/.ssh/known_hosts", "/etc/ssh/ssh_known_hosts"}
+
+// HostKeyCallback will verify host keys against our known_hosts files. An unknown host is
+// refused, unless StrictHostKeyChecking is accept-new (or no) and then we remember it's key.
+// A host key that has changed is always refused.
+func HostKeyCallback(h *SSHHost) (ssh.HostKeyCallback, error) {
+	files := h.UserKnownHostsFiles
+	if len(files) == 0 {
+		files = KnownHostsFiles
+	}
+	var existing []string
+	for _, file := range files {
+		file = local.Expand(file)
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	known, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known_hosts: %v", err)
+	}
+	userFile := local.Expand(files[0])
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		fingerprint := ssh.FingerprintSHA256(key)
+		switch e := err.(type) {
+		case *knownhosts.KeyError:
+			if len(e.Want) > 0 {
+				return fmt.Errorf("host key for [%s] has changed to [%s], and does not match %s", hostname, fingerprint, e.Want[0].String())
+			}
+			if h.StrictHostKeyChecking != "accept-new" && h.StrictHostKeyChecking != "no" {
+				return fmt.Errorf("unknown host key [%s] for [%s] ( ssh-keyscan %s >> %s )", fingerprint, hostname, h.HostName, files[0])
+			}
+			err := addKnownHost(userFile, hostname, key)
+			if err != nil {
+				return err
+			}
+			local.Printf("Permanently added [%s] (%s) to %s", hostname, fingerprint, files[0])
+			return nil
+		case *knownhosts.RevokedError:
+			return fmt.Errorf("host key [%s] for [%s] has been revoked", fingerprint, hostname)
+		}
+		return err
+	}, nil
+}
+
+func addKnownHost(file, hostname string, key ssh.PublicKey) error {
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to add [%s] to known_hosts: %v", hostname, err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
@@ -1,28 +1,269 @@
 package auth
 
 import (
-	"errors"
+	"bytes"
+	"fmt"
 	"github.com/kris-nova/klone/pkg/local"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"syscall"
 )
 
-// Private key to use, defined in /cmd
-var OptPrivateKey = "~/.ssh/id_rsa"
+// Private key to use, defined in /cmd. By default we use the IdentityFile entries
+// in ~/.ssh/config, and then the same keys ssh would.
+var OptPrivateKey = ""
 var PrivateKeyBytesOverride []byte
 
-func GetTransport() (transport.AuthMethod, error) {
-	bytes := local.BGetContent(OptPrivateKey)
-	if len(PrivateKeyBytesOverride) > 1 {
-		bytes = PrivateKeyBytesOverride
+// SSHConfigFile is the OpenSSH client configuration we honor
+var SSHConfigFile = "~/.ssh/config"
+
+// DefaultIdentityFiles are the keys we try when nothing else tells us which key to use
+var DefaultIdentityFiles = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+
+// Passphrase will return the passphrase for an encrypted private key, from
+// $KLONE_SSHPASSPHRASE or by asking for it.
+var Passphrase = func(file string) ([]byte, error) {
+	if pass, ok := os.LookupEnv("KLONE_SSHPASSPHRASE"); ok {
+		return []byte(pass), nil
 	}
-	if len(bytes) < 256 {
-		// Then it's an invalid RSA key
-		return nil, errors.New("Invalid RSA key")
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return nil, fmt.Errorf("no passphrase for [%s] ( KLONE_SSHPASSPHRASE )", file)
+	}
+	local.PrintPrompt(fmt.Sprintf("Passphrase for [%s]: ", file))
+	pass, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	return pass, err
+}
+
+// signerCache is every private key we have already loaded, so we only ask for a passphrase once
+var signerCache = make(map[string]gossh.Signer)
+
+// agentClient is our connection to ssh-agent, which has to stay open while we use it's keys
+var agentClient agent.Agent
+
+func init() {
+	// go-git dials the host in the url, so we use ssh's HostName and Port for it
+	client.InstallProtocol("ssh", &hostTransport{Transport: ssh.DefaultClient})
+}
+
+// GetTransport will return the auth method for an SSH host. We try the keys in
+// ssh-agent ($SSH_AUTH_SOCK) first, and then the private keys for the host.
+func GetTransport(host, user string) (transport.AuthMethod, error) {
+	h := LoadSSHConfig(SSHConfigFile).Host(host)
+	if user == "" {
+		user = h.User
 	}
-	pk, err := ssh.NewPublicKeys("git", bytes, "")
+	if user == "" {
+		user = "git"
+	}
+	signers, err := getSigners(h)
 	if err != nil {
 		return nil, err
 	}
+	callback, err := HostKeyCallback(h)
+	if err != nil {
+		return nil, err
+	}
+	pk := &ssh.PublicKeysCallback{
+		User: user,
+		Callback: func() ([]gossh.Signer, error) {
+			return signers, nil
+		},
+	}
+	pk.HostKeyCallback = callback
 	return pk, nil
 }
+
+// GetTransportForUrl will return the auth method for a remote url. SSH remotes need
+// our private key, and HTTPS remotes use the access token of their git server (if
+// we have one). Every other protocol gets a nil AuthMethod.
+func GetTransportForUrl(url string) (transport.AuthMethod, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	switch ep.Protocol() {
+	case "ssh":
+		return GetTransport(ep.Host(), ep.User())
+	case "https", "http":
+		return getHTTPSTransport(ep), nil
+	}
+	return nil, nil
+}
+
+// getSigners will find every key we can use for a host. Private keys that
+// ssh-agent already has are skipped, so we never ask for a passphrase we don't need.
+func getSigners(h *SSHHost) ([]gossh.Signer, error) {
+	if len(PrivateKeyBytesOverride) > 1 {
+		signer, err := ParsePrivateKey(PrivateKeyBytesOverride, nil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err)
+		}
+		return []gossh.Signer{signer}, nil
+	}
+
+	files, explicit := identityFiles(h)
+	var agentSigners []gossh.Signer
+	if a := getAgent(); a != nil {
+		var err error
+		agentSigners, err = a.Signers()
+		if err != nil {
+			local.RecoverableErrorf("Unable to list keys in ssh-agent: %v", err)
+		}
+	}
+
+	var signers []gossh.Signer
+	inAgent := make(map[string]bool)
+	if !h.IdentitiesOnly {
+		for _, signer := range agentSigners {
+			signers = append(signers, signer)
+			inAgent[string(signer.PublicKey().Marshal())] = true
+		}
+	}
+	for _, file := range files {
+		path := local.Expand(file)
+		if pub := publicKey(path); pub != nil {
+			if inAgent[string(pub.Marshal())] {
+				continue
+			}
+			if h.IdentitiesOnly {
+				if signer := findSigner(agentSigners, pub); signer != nil {
+					signers = append(signers, signer)
+					continue
+				}
+			}
+		}
+		signer, err := loadSigner(path)
+		if os.IsNotExist(err) && !explicit {
+			continue
+		}
+		if err != nil {
+			local.RecoverableErrorf("Unable to use private key [%s]: %v", file, err)
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no SSH keys for [%s] ( ssh-add, or --identity-file )", h.HostName)
+	}
+	return signers, nil
+}
+
+// identityFiles are the private keys to try for a host, and if they were asked for
+func identityFiles(h *SSHHost) ([]string, bool) {
+	if OptPrivateKey != "" {
+		return []string{OptPrivateKey}, true
+	}
+	if len(h.IdentityFiles) > 0 {
+		return h.IdentityFiles, true
+	}
+	return DefaultIdentityFiles, false
+}
+
+func getAgent() agent.Agent {
+	if agentClient != nil {
+		return agentClient
+	}
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		local.RecoverableErrorf("Unable to connect to ssh-agent: %v", err)
+		return nil
+	}
+	agentClient = agent.NewClient(conn)
+	return agentClient
+}
+
+func loadSigner(path string) (gossh.Signer, error) {
+	if signer, ok := signerCache[path]; ok {
+		return signer, nil
+	}
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ParsePrivateKey(pemBytes, func() ([]byte, error) {
+		return Passphrase(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	signerCache[path] = signer
+	return signer, nil
+}
+
+// publicKey is the public key of a private key, without decrypting it
+func publicKey(path string) gossh.PublicKey {
+	if content, err := ioutil.ReadFile(path + ".pub"); err == nil {
+		if pub, _, _, _, err := gossh.ParseAuthorizedKey(content); err == nil {
+			return pub
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	signer, err := gossh.ParsePrivateKey(content)
+	if err == nil {
+		return signer.PublicKey()
+	}
+	// OpenSSH keys keep their public key outside of the encrypted part
+	if missing, ok := err.(*gossh.PassphraseMissingError); ok && missing.PublicKey != nil {
+		return missing.PublicKey
+	}
+	return nil
+}
+
+func findSigner(signers []gossh.Signer, pub gossh.PublicKey) gossh.Signer {
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), pub.Marshal()) {
+			return signer
+		}
+	}
+	return nil
+}
+
+// hostTransport will dial the HostName and Port from ~/.ssh/config instead of the host in the url
+type hostTransport struct {
+	transport.Transport
+}
+
+func (t *hostTransport) NewUploadPackSession(ep transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	return t.Transport.NewUploadPackSession(hostEndpoint(ep), auth)
+}
+
+func (t *hostTransport) NewReceivePackSession(ep transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	return t.Transport.NewReceivePackSession(hostEndpoint(ep), auth)
+}
+
+type sshEndpoint struct {
+	transport.Endpoint
+	host *SSHHost
+}
+
+func (e *sshEndpoint) Host() string {
+	return e.host.HostName
+}
+
+// Port is the port in the url, or from ~/.ssh/config. An scp-like url (user@host:path) can't have a port.
+func (e *sshEndpoint) Port() int {
+	if strings.Contains(e.Endpoint.String(), "://") && e.Endpoint.Port() > 0 {
+		return e.Endpoint.Port()
+	}
+	return e.host.Port
+}
+
+func hostEndpoint(ep transport.Endpoint) transport.Endpoint {
+	return &sshEndpoint{Endpoint: ep, host: LoadSSHConfig(SSHConfigFile).Host(ep.Host())}
+}
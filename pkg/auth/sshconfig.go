@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/kris-nova/klone/pkg/local"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// SSHConfig is the part of an OpenSSH client configuration (~/.ssh/config) that we understand
+type SSHConfig struct {
+	blocks []*sshConfigBlock
+}
+
+type sshConfigBlock struct {
+	patterns []string
+	options  [][2]string
+}
+
+// SSHHost is the configuration for one host
+type SSHHost struct {
+	HostName              string
+	User                  string
+	Port                  int
+	IdentityFiles         []string
+	IdentitiesOnly        bool
+	StrictHostKeyChecking string
+	UserKnownHostsFiles   []string
+}
+
+// LoadSSHConfig will load an OpenSSH client configuration, having no file is fine
+func LoadSSHConfig(path string) *SSHConfig {
+	content, err := ioutil.ReadFile(local.Expand(path))
+	if err != nil {
+		return &SSHConfig{}
+	}
+	return ParseSSHConfig(content)
+}
+
+// ParseSSHConfig will parse the Host blocks of an OpenSSH client configuration.
+// Match blocks are skipped, as we are unable to evaluate them.
+func ParseSSHConfig(content []byte) *SSHConfig {
+	c := &SSHConfig{}
+	block := &sshConfigBlock{patterns: []string{"*"}}
+	c.blocks = append(c.blocks, block)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value := splitSSHOption(line)
+		switch strings.ToLower(key) {
+		case "host":
+			block = &sshConfigBlock{patterns: strings.Fields(value)}
+			c.blocks = append(c.blocks, block)
+			continue
+		case "match":
+			block = &sshConfigBlock{}
+			c.blocks = append(c.blocks, block)
+			continue
+		}
+		block.options = append(block.options, [2]string{strings.ToLower(key), unquote(value)})
+	}
+	return c
+}
+
+// splitSSHOption will split "Key Value" or "Key=Value"
+func splitSSHOption(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	key, value := line[:i], strings.TrimSpace(line[i:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	return key, value
+}
+
+func unquote(value string) string {
+	if len(value) > 1 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// Host is the configuration for a host. Like ssh the first value we find wins,
+// except for IdentityFile where every value is used.
+func (c *SSHConfig) Host(host string) *SSHHost {
+	h := &SSHHost{}
+	seen := make(map[string]bool)
+	for _, block := range c.blocks {
+		if !matchHost(block.patterns, host) {
+			continue
+		}
+		for _, option := range block.options {
+			key, value := option[0], option[1]
+			if key == "identityfile" {
+				h.IdentityFiles = append(h.IdentityFiles, value)
+				continue
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			switch key {
+			case "hostname":
+				h.HostName = value
+			case "user":
+				h.User = value
+			case "port":
+				h.Port, _ = strconv.Atoi(value)
+			case "identitiesonly":
+				h.IdentitiesOnly = strings.ToLower(value) == "yes"
+			case "stricthostkeychecking":
+				h.StrictHostKeyChecking = strings.ToLower(value)
+			case "userknownhostsfile":
+				h.UserKnownHostsFiles = strings.Fields(value)
+			}
+		}
+	}
+	if h.HostName == "" {
+		h.HostName = host
+	}
+	h.HostName = strings.Replace(h.HostName, "%h", host, -1)
+	for i, file := range h.IdentityFiles {
+		h.IdentityFiles[i] = h.expand(file)
+	}
+	for i, file := range h.UserKnownHostsFiles {
+		h.UserKnownHostsFiles[i] = h.expand(file)
+	}
+	return h
+}
+
+// expand will expand the tokens ssh understands in a path
+func (h *SSHHost) expand(path string) string {
+	r := strings.NewReplacer("%%", "%", "%d", local.Home(), "%h", h.HostName, "%r", h.User)
+	path = r.Replace(path)
+	if strings.HasPrefix(path, "~") {
+		path = local.Expand(path)
+	}
+	return path
+}
+
+// matchHost is true if a host matches one of the patterns, and none of the negated patterns
+func matchHost(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			if matchPattern(strings.ToLower(pattern[1:]), strings.ToLower(host)) {
+				return false
+			}
+			continue
+		}
+		if matchPattern(strings.ToLower(pattern), strings.ToLower(host)) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// matchPattern will match an ssh pattern, where * is any number of characters and ? is one
+func matchPattern(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if matchPattern(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
@@ -0,0 +1,272 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// config.go is the global configuration file, the defaults for every klone
+
+package config
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// File is where the configuration for every klone lives
+var File = fmt.Sprintf("%s/.klone/config.yaml", local.Home())
+
+// Setting is a key in the configuration file. A flag wins over an environmental
+// variable, and an environmental variable wins over the configuration file.
+type Setting struct {
+	Key         string // E.G. remotes.origin
+	Flag        string // The flag for the setting, if it has one
+	Env         string // The environmental variable for the setting, if it has one
+	Description string
+}
+
+// Settings are every key we understand in the configuration file
+//
+//	kloner: gogit
+//	workspace: ~/src
+//	identityFile: ~/.ssh/id_ed25519
+//	roots:
+//	  go: ~/go
+//	  rust: ~/src/rust
+//	remotes:
+//	  origin: mine
+//	  upstream: theirs
+//	transport:
+//	  mode: auto
+//	  hosts: github.com=https
+//	container:
+//	  command: /bin/zsh
+//	providers:
+//	  gitlab:
+//	    url: https://gitlab.corp.example
+var Settings = []*Setting{
+	{Key: "kloner", Flag: "kloner", Env: "KLONE_KLONER", Description: "The kloner to use for every klone"},
+	{Key: "workspace", Env: "KLONE_WORKSPACE", Description: "Where to klone repositories, instead of the current directory"},
+	{Key: "identityFile", Flag: "identity-file", Env: "KLONE_IDENTITYFILE", Description: "The private key to use for git over SSH"},
+	{Key: "roots.go", Env: "GOPATH", Description: "Where to klone Go repositories ( $GOPATH )"},
+	{Key: "roots.gomodule", Flag: "go-workspace", Env: "KLONE_GOWORKSPACE", Description: "Where to klone Go repositories in module mode"},
+	{Key: "roots.rust", Flag: "rust-root", Env: "KLONE_RUSTROOT", Description: "Where to klone Rust repositories"},
+	{Key: "roots.python", Flag: "python-root", Env: "KLONE_PYTHONROOT", Description: "Where to klone Python repositories"},
+	{Key: "roots.jvm", Flag: "jvm-root", Env: "KLONE_JVMROOT", Description: "Where to klone Java and Kotlin repositories"},
+	{Key: "go.mode", Flag: "go-mode", Env: "KLONE_GOMODE", Description: "Where the Go kloner puts repositories ( gopath, module )"},
+	{Key: "go.work", Flag: "go-work", Env: "KLONE_GOWORK", Description: "A go.work file to add Go repositories to in module mode"},
+	{Key: "transport.mode", Flag: "transport", Env: "KLONE_TRANSPORT", Description: "How to clone, fetch, and push ( auto, ssh, https )"},
+	{Key: "transport.hosts", Flag: "host-transport", Env: "KLONE_HOSTTRANSPORTS", Description: "The transport for hosts, E.G. github.com=https ( comma separated )"},
+	{Key: "remotes.origin", Flag: "origin-remote", Env: "KLONE_ORIGINREMOTE", Description: "What to call the remote we push to"},
+	{Key: "remotes.upstream", Flag: "upstream-remote", Env: "KLONE_UPSTREAMREMOTE", Description: "What to call the remote we forked from"},
+	{Key: "remotes.noFork", Flag: "no-fork-remote", Env: "KLONE_NOFORKREMOTE", Description: "The remote to register when the git server is unable to fork ( origin, upstream )"},
+	{Key: "container.image", Flag: "container", Env: "KLONE_CONTAINERIMAGE", Description: "Run every klone in a container with this image"},
+	{Key: "container.command", Flag: "container-command", Env: "KLONE_CONTAINERCOMMAND", Description: "The command to run in the container ( comma separated )"},
+	{Key: "providers.github.user", Env: "KLONE_GITHUBUSER", Description: "GitHub user name to authenticate with"},
+	{Key: "providers.github.enterprise", Env: "KLONE_GITHUBENTERPRISE", Description: "GitHub Enterprise hostnames ( comma separated )"},
+	{Key: "providers.github.clientID", Env: "KLONE_GITHUBCLIENTID", Description: "Client ID of a GitHub OAuth App, required for the device flow ( klone ships none )"},
+	{Key: "providers.github.credentials", Env: "KLONE_GITHUBCREDENTIALS", Description: "Where to keep GitHub access tokens ( auto, git, keyring, klone )"},
+	{Key: "providers.gitlab.url", Env: "KLONE_GITLABURL", Description: "Base URL of a self-hosted GitLab instance"},
+	{Key: "providers.bitbucket.url", Env: "KLONE_BITBUCKETURL", Description: "Base URL of a Bitbucket Server instance"},
+	{Key: "providers.bitbucket.user", Env: "KLONE_BITBUCKETUSER", Description: "Bitbucket user name to authenticate with"},
+	{Key: "providers.gitea.url", Env: "KLONE_GITEAURL", Description: "Base URL of a Gitea (or Forgejo) instance"},
+	{Key: "providers.git.hosts", Env: "KLONE_GITHOSTS", Description: "Plain git hostnames ( comma separated )"},
+}
+
+// Lookup will find the setting for a key
+func Lookup(key string) (*Setting, error) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown config key [%s] (klone config view --keys)", key)
+}
+
+// Config is the content of a configuration file, as nested maps
+type Config struct {
+	values map[interface{}]interface{}
+}
+
+// Load will read a configuration file, having no file is fine
+func Load(path string) (*Config, error) {
+	c := &Config{values: make(map[interface{}]interface{})}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config [%s]: %v", path, err)
+	}
+	err = yaml.Unmarshal(content, &c.values)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config [%s]: %v", path, err)
+	}
+	if c.values == nil {
+		c.values = make(map[interface{}]interface{})
+	}
+	for _, key := range c.Keys() {
+		if _, err := Lookup(key); err != nil {
+			return nil, fmt.Errorf("invalid config [%s]: %v", path, err)
+		}
+	}
+	return c, nil
+}
+
+// Save will write a configuration file, only we should be able to read it
+func (c *Config) Save(path string) error {
+	content, err := yaml.Marshal(c.values)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return fmt.Errorf("unable to create [%s]: %v", filepath.Dir(path), err)
+	}
+	err = ioutil.WriteFile(path, content, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write config [%s]: %v", path, err)
+	}
+	return nil
+}
+
+// Get is the value of a key, lists are comma separated. ok is false if the key is not set.
+func (c *Config) Get(key string) (value string, ok bool) {
+	m := c.values
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[interface{}]interface{})
+		if !ok {
+			return "", false
+		}
+		m = next
+	}
+	v, ok := m[parts[len(parts)-1]]
+	if !ok || v == nil {
+		return "", false
+	}
+	if list, isList := v.([]interface{}); isList {
+		var values []string
+		for _, item := range list {
+			values = append(values, fmt.Sprint(item))
+		}
+		return strings.Join(values, ","), true
+	}
+	return fmt.Sprint(v), true
+}
+
+// Set will set the value of a key we understand
+func (c *Config) Set(key, value string) error {
+	if _, err := Lookup(key); err != nil {
+		return err
+	}
+	m := c.values
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[interface{}]interface{})
+		if !ok {
+			next = make(map[interface{}]interface{})
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+	return nil
+}
+
+// Unset will remove a key
+func (c *Config) Unset(key string) error {
+	if _, err := Lookup(key); err != nil {
+		return err
+	}
+	m := c.values
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[interface{}]interface{})
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	delete(m, parts[len(parts)-1])
+	return nil
+}
+
+// Keys are the dotted keys that are set, in order
+func (c *Config) Keys() []string {
+	var keys []string
+	var walk func(prefix string, m map[interface{}]interface{})
+	walk = func(prefix string, m map[interface{}]interface{}) {
+		for k, v := range m {
+			key := fmt.Sprint(k)
+			if prefix != "" {
+				key = fmt.Sprintf("%s.%s", prefix, key)
+			}
+			if next, ok := v.(map[interface{}]interface{}); ok {
+				walk(key, next)
+				continue
+			}
+			keys = append(keys, key)
+		}
+	}
+	walk("", c.values)
+	sort.Strings(keys)
+	return keys
+}
+
+// Apply will use the configuration for every setting that was not set with a flag or
+// an environmental variable. Settings with a flag are set through the flag, and the
+// rest are set in our environment.
+func (c *Config) Apply(flags *pflag.FlagSet) error {
+	for _, s := range Settings {
+		if s.Flag != "" {
+			if f := flags.Lookup(s.Flag); f != nil && f.Changed {
+				continue
+			}
+		}
+		value, ok := "", false
+		if s.Env != "" {
+			value, ok = os.LookupEnv(s.Env)
+		}
+		if !ok {
+			value, ok = c.Get(s.Key)
+		}
+		if !ok {
+			continue
+		}
+		if s.Flag == "" {
+			os.Setenv(s.Env, value)
+			continue
+		}
+		f := flags.Lookup(s.Flag)
+		if f == nil {
+			continue
+		}
+		err := f.Value.Set(value)
+		if err != nil {
+			return fmt.Errorf("invalid value [%s] for [%s]: %v", value, s.Key, err)
+		}
+	}
+	return nil
+}
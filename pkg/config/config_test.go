@@ -0,0 +1,122 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, ".klone", "config.yaml")
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unable to load a missing config: %v", err)
+	}
+	if len(c.Keys()) != 0 {
+		t.Fatalf("Keys in a missing config: %v", c.Keys())
+	}
+	c.Set("remotes.origin", "mine")
+	c.Set("kloner", "golang")
+	err = c.Set("remotes.nope", "x")
+	if err == nil {
+		t.Fatal("Able to set an unknown key")
+	}
+	err = c.Save(path)
+	if err != nil {
+		t.Fatalf("Unable to save: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Config is readable by others: %v", info.Mode())
+	}
+	c, err = Load(path)
+	if err != nil {
+		t.Fatalf("Unable to load: %v", err)
+	}
+	if value, ok := c.Get("remotes.origin"); !ok || value != "mine" {
+		t.Fatalf("Unexpected remotes.origin: %s", value)
+	}
+	if keys := strings.Join(c.Keys(), " "); keys != "kloner remotes.origin" {
+		t.Fatalf("Unexpected keys: %s", keys)
+	}
+	c.Unset("remotes.origin")
+	if _, ok := c.Get("remotes.origin"); ok {
+		t.Fatal("Able to get an unset key")
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	cases := map[string]string{
+		"remotes: [":                "unable to parse",
+		"remotes:\n  nope: x\n":     "unknown config key [remotes.nope]",
+		"providers:\n  github: x\n": "unknown config key [providers.github]",
+	}
+	for content, expected := range cases {
+		ioutil.WriteFile(path, []byte(content), 0600)
+		_, err := Load(path)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected [%s] for [%s], got: %v", expected, content, err)
+		}
+	}
+	ioutil.WriteFile(path, []byte("providers:\n  git:\n    hosts:\n      - git.a\n      - git.b\n"), 0600)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unable to load: %v", err)
+	}
+	if value, _ := c.Get("providers.git.hosts"); value != "git.a,git.b" {
+		t.Fatalf("Unexpected list: %s", value)
+	}
+}
+
+func TestApply(t *testing.T) {
+	for _, env := range []string{"KLONE_KLONER", "KLONE_ORIGINREMOTE", "KLONE_UPSTREAMREMOTE", "KLONE_GITLABURL"} {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	c := &Config{values: make(map[interface{}]interface{})}
+	c.Set("kloner", "simple")
+	c.Set("remotes.origin", "mine")
+	c.Set("remotes.upstream", "theirs")
+	c.Set("providers.gitlab.url", "https://gitlab.corp.example")
+	os.Setenv("KLONE_ORIGINREMOTE", "env")
+
+	var kloner, origin, upstream string
+	flags := pflag.NewFlagSet("klone", pflag.ContinueOnError)
+	flags.StringVarP(&kloner, "kloner", "k", "", "")
+	flags.StringVarP(&origin, "origin-remote", "", "origin", "")
+	flags.StringVarP(&upstream, "upstream-remote", "", "upstream", "")
+	flags.Parse([]string{"--kloner", "golang"})
+	err := c.Apply(flags)
+	if err != nil {
+		t.Fatalf("Unable to apply: %v", err)
+	}
+	if kloner != "golang" {
+		t.Fatalf("Config won over a flag: %s", kloner)
+	}
+	if origin != "env" {
+		t.Fatalf("Config won over an environmental variable: %s", origin)
+	}
+	if upstream != "theirs" {
+		t.Fatalf("Config was not applied to a flag: %s", upstream)
+	}
+	if url := os.Getenv("KLONE_GITLABURL"); url != "https://gitlab.corp.example" {
+		t.Fatalf("Config was not applied to the environment: %s", url)
+	}
+}
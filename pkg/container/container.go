@@ -104,7 +104,12 @@ func ensureBootstrapFileLocal() error {
 		local.PrintExclaimf("Found local hack directory for container bootstrap")
 		localBootstrapFile := fmt.Sprintf("%s/hack/BOOTSTRAP.sh", wd)
 		local.SPutContent(local.Version, fmt.Sprintf("%s/hack/version", wd))
-		local.SPutContent(local.SGetContent(fmt.Sprintf("%s/.klone/auth", local.Home())), fmt.Sprintf("%s/hack/auth", wd))
+		// The access token cache is no use to klone in the container without the identity it is encrypted with
+		for _, f := range []string{"auth", "auth.key"} {
+			if content := local.SGetContent(fmt.Sprintf("%s/.klone/%s", local.Home(), f)); content != "" {
+				local.SPutPrivateContent(content, fmt.Sprintf("%s/hack/%s", wd, f))
+			}
+		}
 		bootstrapFile = localBootstrapFile
 		return nil
 	}
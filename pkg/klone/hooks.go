@@ -0,0 +1,161 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// hooks.go runs commands at points in a klone, from the global hooks file
+// and from a repository's Klonefile
+
+package klone
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// HooksFile is where the hooks for every klone live
+var HooksFile = fmt.Sprintf("%s/.klone/hooks", local.Home())
+
+// loadHooks will read the global hooks, having no hooks file is fine
+func loadHooks(path string) (*klonefile.Hooks, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &klonefile.Hooks{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read hooks [%s]: %v", path, err)
+	}
+	hooks, err := klonefile.ParseHooks(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load hooks [%s]: %v", path, err)
+	}
+	return hooks, nil
+}
+
+// runHook will run the global commands for a hook, and then the commands from
+// the Klonefile if we trust it. Any command that fails will fail the klone.
+func (k *Kloneable) runHook(hook, dir string) error {
+	env := k.hookEnv(hook)
+	if k.hooks != nil {
+		for _, command := range k.hooks.Commands(hook) {
+			local.Printf("Running %s hook [%s]", hook, command)
+			err := runCommand(dir, command, env)
+			if err != nil {
+				return fmt.Errorf("%s hook [%s] failed: %v", hook, command, err)
+			}
+		}
+	}
+	if k.klonefile != nil {
+		for _, command := range k.klonefile.Hooks.Commands(hook) {
+			if !TrustKlonefile {
+				local.Printf("Skipping %s %s hook [%s] (use --trust-klonefile to run it)", klonefile.Filename, hook, command)
+				continue
+			}
+			local.Printf("Running %s %s hook [%s]", klonefile.Filename, hook, command)
+			err := runCommand(dir, command, env)
+			if err != nil {
+				return fmt.Errorf("%s %s hook [%s] failed: %v", klonefile.Filename, hook, command, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runCommands will run the commands from a Klonefile in a klone, but only if we
+// trust the Klonefile. They come from the repository, and could do anything.
+func (k *Kloneable) runCommands(path string) error {
+	if k.klonefile == nil {
+		return nil
+	}
+	env := k.hookEnv("")
+	for _, command := range k.klonefile.Commands {
+		if !TrustKlonefile {
+			local.Printf("Skipping %s command [%s] (use --trust-klonefile to run it)", klonefile.Filename, command)
+			continue
+		}
+		local.Printf("Running %s command [%s]", klonefile.Filename, command)
+		err := runCommand(path, command, env)
+		if err != nil {
+			return fmt.Errorf("%s command [%s] failed: %v", klonefile.Filename, command, err)
+		}
+	}
+	return nil
+}
+
+// hookEnv describes the klone to the commands we run. KLONE_PATH is where the
+// klone will be once it has completed, which might not be where it is now.
+func (k *Kloneable) hookEnv(hook string) []string {
+	origin, upstream := k.remoteURLs()
+	var path string
+	if k.tx != nil {
+		path = k.tx.path
+	}
+	env := []string{
+		fmt.Sprintf("KLONE_PATH=%s", path),
+		fmt.Sprintf("KLONE_STYLE=%s", k.style),
+		fmt.Sprintf("KLONE_SERVER=%s", k.gitServer.GetServerString()),
+		fmt.Sprintf("KLONE_REPOSITORY=%s", fullName(k.repo)),
+		fmt.Sprintf("KLONE_ORIGIN=%s", origin),
+		fmt.Sprintf("KLONE_UPSTREAM=%s", upstream),
+	}
+	if hook != "" {
+		env = append(env, fmt.Sprintf("KLONE_HOOK=%s", hook))
+	}
+	if environer, ok := k.kloner.(kloners.Environer); ok {
+		env = append(env, environer.Environ()...)
+	}
+	return env
+}
+
+// remoteURLs are the urls of the origin and upstream remotes for our style,
+// the origin of a fork we have not created yet is empty
+func (k *Kloneable) remoteURLs() (string, string) {
+	switch k.style {
+	case StyleOwner:
+		return k.repo.GitRemoteUrl(), ""
+	case StyleAlreadyForked:
+		return k.repo.GitRemoteUrl(), k.repo.ForkedFrom().GitRemoteUrl()
+	case StyleNeedsFork, StyleTryingFork:
+		if k.fork == nil {
+			return "", k.repo.GitRemoteUrl()
+		}
+		return k.fork.GitRemoteUrl(), k.repo.GitRemoteUrl()
+	case StyleNoFork:
+		if NoForkRemote == "upstream" {
+			return "", k.repo.GitRemoteUrl()
+		}
+		return k.repo.GitRemoteUrl(), ""
+	}
+	return "", ""
+}
+
+// runCommand will run a shell command in dir with our STDIN, STDOUT, and STDERR
+func runCommand(dir, command string, env []string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,134 @@
+package klone
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHooks will test global hooks run at each point of a klone, and know about the klone
+func TestHooks(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	log := filepath.Join(filepath.Dir(HooksFile), "log")
+	hooks := fmt.Sprintf(`pre-clone:
+  - echo "$KLONE_HOOK $KLONE_STYLE $KLONE_PATH $KLONE_REPOSITORY" >> %s
+post-clone:
+  - test -d .git && echo "$KLONE_HOOK $KLONE_ORIGIN" >> %s
+post-remote:
+  - git remote >> %s
+`, log, log, log)
+	err := ioutil.WriteFile(HooksFile, []byte(hooks), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.repo = &klonefileRepo{Repo: k.repo, klonefile: "hooks:\n  post-remote:\n    - touch from-klonefile\n"}
+	err = k.loadKlonefile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = k.Klone()
+	if err != nil {
+		t.Fatalf("Unable to klone: %v", err)
+	}
+	content, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatalf("Hooks did not run: %v", err)
+	}
+	expected := fmt.Sprintf("pre-clone no-fork %s team/service\npost-clone %s\norigin\n", path, k.repo.GitRemoteUrl())
+	if string(content) != expected {
+		t.Fatalf("Unexpected hooks:\n%s\nexpected:\n%s", content, expected)
+	}
+	if _, err := os.Stat(filepath.Join(path, "from-klonefile")); !os.IsNotExist(err) {
+		t.Fatalf("Ran a hook from an untrusted Klonefile: %v", err)
+	}
+}
+
+// TestHooksRollback will test a hook that fails rolls the klone back
+func TestHooksRollback(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	err := ioutil.WriteFile(HooksFile, []byte("post-clone:\n  - exit 3\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = k.Klone()
+	if err == nil || !strings.Contains(err.Error(), "post-clone hook [exit 3] failed") {
+		t.Fatalf("Expected post-clone hook to fail: %v", err)
+	}
+	entries, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(entries) != 0 {
+		t.Fatalf("Partial klone left behind: %d entries in workspace", len(entries))
+	}
+}
+
+// TestHooksTrustKlonefile will test Klonefile hooks run after the global hooks when we trust it
+func TestHooksTrustKlonefile(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	err := ioutil.WriteFile(HooksFile, []byte("post-clone:\n  - echo global > order\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.repo = &klonefileRepo{Repo: k.repo, klonefile: "hooks:\n  post-clone:\n    - echo klonefile >> order\n"}
+	err = k.loadKlonefile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	TrustKlonefile = true
+	defer func() { TrustKlonefile = false }()
+	_, err = k.Klone()
+	if err != nil {
+		t.Fatalf("Unable to klone: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(path, "order"))
+	if err != nil || string(content) != "global\nklonefile\n" {
+		t.Fatalf("Unexpected hooks: %v %s", err, content)
+	}
+}
+
+// TestHooksKlonerEnviron will test hooks know what the kloner found in the klone
+func TestHooksKlonerEnviron(t *testing.T) {
+	k, _, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	dir := filepath.Dir(HooksFile)
+	work := filepath.Join(dir, "work")
+	for file, content := range map[string]string{"package.json": "{}\n", "yarn.lock": "\n", ".nvmrc": "18\n"} {
+		err := ioutil.WriteFile(filepath.Join(work, file), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"-C", work, "add", "."},
+		{"-C", work, "-c", "user.name=klone", "-c", "user.email=klone@example.com", "commit", "-q", "-m", "yarn"},
+		{"-C", work, "push", "-q", filepath.Join(dir, "srv", "team", "service.git"), "HEAD"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unable to run git %v: %v %s", args, err, out)
+		}
+	}
+	log := filepath.Join(dir, "log")
+	err := ioutil.WriteFile(HooksFile, []byte(fmt.Sprintf("post-clone:\n  - echo \"$KLONE_PACKAGE_MANAGER $KLONE_NODE_VERSION\" > %s\n", log)), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ForceKloner = "javascript"
+	defer func() { ForceKloner = "" }()
+	_, err = k.Klone()
+	if err != nil {
+		t.Fatalf("Unable to klone: %v", err)
+	}
+	content, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatalf("Hook did not run: %v", err)
+	}
+	if string(content) != "yarn 18\n" {
+		t.Fatalf("Unexpected hook environment: %s", content)
+	}
+}
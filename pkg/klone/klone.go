@@ -26,32 +26,127 @@ package klone
 
 import (
 	"fmt"
+	"github.com/kris-nova/klone/pkg/auth"
+	"github.com/kris-nova/klone/pkg/klonefile"
 	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/paths"
+	"github.com/kris-nova/klone/pkg/provider"
 )
 
 type Style int
 
+// String is the name of a style, as it is printed while reasoning about a klone
+func (s Style) String() string {
+	switch s {
+	case StyleOwner:
+		return "owner"
+	case StyleAlreadyForked:
+		return "already-forked"
+	case StyleNeedsFork:
+		return "needs-fork"
+	case StyleTryingFork:
+		return "trying-fork"
+	case StyleNoFork:
+		return "no-fork"
+	}
+	return "unknown"
+}
+
 // Klone is the main entry point for a klone routine. This
 // is the procedural logic for "kloning" a git repository.
 // This will attempt to look up relevant repository information
 // and set a kloning "style" for the klone
 func Klone(name string) error {
 	local.Printf("Kloning [%s]", name)
+	kloneable, err := newKloneable(name)
+	if err != nil {
+		return err
+	}
+
+	// We now have something that is Klonable, let's klone it
+	path, err := kloneable.Klone()
+	if err != nil {
+		if _, ok := err.(*finishError); ok {
+			local.Printf("Klone is in place [%s], but was not finished", path)
+		} else if KeepPartial {
+			local.Printf("Unable to complete klone, partial work has been kept")
+		} else {
+			local.Printf("Unable to complete klone, partial work has been rolled back")
+		}
+		return err
+	}
+	local.PrintExclaimf("Klone completed [%s]", path)
+	return kloneable.runCommands(path)
+}
+
+// Plan will reason about a klone exactly like Klone, and return what the klone
+// would do without changing anything on the git server or on disk
+func Plan(name string) (*KlonePlan, error) {
+	local.Printf("Planning klone [%s]", name)
+	// A plan can ask for credentials, but never keeps them
+	defer func(keep bool) { provider.KeepCredentials = keep }(provider.KeepCredentials)
+	provider.KeepCredentials = false
+	kloneable, err := newKloneable(name)
+	if err != nil {
+		return nil, err
+	}
+	err = kloneable.findKloner()
+	if err != nil {
+		return nil, err
+	}
+	kloneable.useImportPath()
+	return kloneable.plan(name), nil
+}
+
+// KlonefileContainer will return the container image the Klonefile of a repository asks for
+func KlonefileContainer(name string) (string, error) {
+	kloneable, err := newKloneable(name)
+	if err != nil {
+		return "", err
+	}
+	if kloneable.klonefile == nil || kloneable.klonefile.Container == "" {
+		return "", fmt.Errorf("[%s] has no container in it's %s", name, klonefile.Filename)
+	}
+	return kloneable.klonefile.Container, nil
+}
+
+// newKloneable will parse a query and reason about the style of klone we need
+func newKloneable(name string) (*Kloneable, error) {
+	err := paths.Load(paths.File)
+	if err != nil {
+		return nil, err
+	}
 	// ParseQuery
 	ok, queryInfo := ParseQuery(name)
 	if !ok {
-		return fmt.Errorf("Failure to parse query: %s", name)
+		return nil, fmt.Errorf("Failure to parse query: %s", name)
 	}
 	gitServer := queryInfo.gitServer
 	repo := queryInfo.repo
+	if c, ok := gitServer.(provider.HTTPSCredentialer); ok {
+		user, password := c.HTTPSCredentials()
+		auth.SetHTTPSCredentials(gitServer.GetServerString(), user, password)
+	}
 	local.Printf("Found repository [%s/%s]", repo.Owner(), repo.Name())
-	var err error
 	kloneable := &Kloneable{
-		gitServer: gitServer,
+		gitServer:  gitServer,
+		selector:   queryInfo.selector,
+		importPath: queryInfo.importPath,
+	}
+	if kloneable.selector != nil && kloneable.selector.PullRequest > 0 {
+		kloneable.selector.PullRequestRef, err = provider.PullRequestRef(gitServer, kloneable.selector.PullRequest)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Reason about our repository
-	if (repo.Owner() == gitServer.OwnerName()) && (repo.ForkedFrom() == nil) {
+	if gitServer.OwnerName() == "" {
+		// We have no user on the git server (E.G. plain git), so there is nowhere to fork to
+		local.Printf("[NO-FORK] klone found [%s/%s]", repo.Owner(), repo.Name())
+		kloneable.style = StyleNoFork
+		kloneable.repo = repo
+	} else if (repo.Owner() == gitServer.OwnerName()) && (repo.ForkedFrom() == nil) {
 		// It's ours, and we have no parent - just a normal klone
 		local.Printf("[OWNER] klone found [%s/%s]", repo.Owner(), repo.Name())
 		kloneable.style = StyleOwner
@@ -83,13 +178,10 @@ func Klone(name string) error {
 		local.PrintFatal("Unable to parse kloning style! Major error!")
 	}
 
-	// We now have something that is Klonable, let's klone it
-	path, err := kloneable.Klone()
+	// The repository can tell us how it would like to be kloned
+	err = kloneable.loadKlonefile()
 	if err != nil {
-		// Todo (@kris-nova) Can we please make klone atomic? :)
-		local.Printf("Unable to complete klone. Klone does not clean up after itself, there might be incomplete work!")
-		return err
+		return nil, err
 	}
-	local.PrintExclaimf("Klone completed [%s]", path)
-	return nil
+	return kloneable, nil
 }
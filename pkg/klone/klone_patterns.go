@@ -14,19 +14,12 @@ const waitForForkSeconds = 10
 // a repository where we are the owner. E.G. this is ours, and not a fork.
 func (k *Kloneable) kloneOwner() (string, error) {
 	local.Printf("Attempting git clone")
-	path, err := k.kloner.Clone(k.repo)
+	path, err := k.clone(k.repo)
 	if err != nil {
 		return "", err
 	}
-	// Always delete origins
-	local.Printf("Register remote [origin]")
-	err = k.kloner.DeleteRemote("origin")
-	if err != nil && !strings.Contains(err.Error(), "remote not found") {
-		return path, err
-	}
-	// Add Origin
 	// Origin is our remote URL, and location is ours too!
-	err = k.kloner.AddRemote("origin", k.repo.GitRemoteUrl())
+	err = k.setRemote(k.remoteName("origin"), k.repo.GitRemoteUrl())
 	if err != nil {
 		return path, err
 	}
@@ -37,34 +30,39 @@ func (k *Kloneable) kloneOwner() (string, error) {
 // on disk, but the origin is ours.
 func (k *Kloneable) kloneAlreadyForked() (string, error) {
 	local.Printf("Attempting git clone")
-	path, err := k.kloner.Clone(k.repo.ForkedFrom())
+	path, err := k.clone(k.repo.ForkedFrom())
 	if err != nil {
 		return "", err
 	}
-	// Add Origin
-	local.Printf("Register remote [origin]")
-	err = k.kloner.DeleteRemote("origin")
-	if err != nil && !strings.Contains(err.Error(), "remote not found") {
-		return path, err
-	}
 	// Origin is our remote URL, but their location on disk
-	err = k.kloner.AddRemote("origin", k.repo.GitRemoteUrl())
+	err = k.setRemote(k.remoteName("origin"), k.repo.GitRemoteUrl())
 	if err != nil {
 		return path, err
 	}
-	local.Printf("Register remote [upstream]")
-	err = k.kloner.DeleteRemote("upstream")
-	if err != nil && !strings.Contains(err.Error(), "remote not found") {
-		return path, err
-	}
 	// Upstream is their remote URL, and their location on disk
-	err = k.kloner.AddRemote("upstream", k.repo.ForkedFrom().GitRemoteUrl())
+	upstream := k.remoteName("upstream")
+	err = k.setRemote(upstream, k.repo.ForkedFrom().GitRemoteUrl())
 	if err != nil {
 		return path, err
 	}
 	// Pull
-	local.Printf("Pull [upstream]")
-	err = k.kloner.Pull("upstream")
+	local.Printf("Pull [%s]", upstream)
+	err = k.kloner.Pull(upstream)
+	if err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// kloneNoFork is called when the git server is unable to fork. We clone the
+// repository just like it was ours, and register it as our only remote.
+func (k *Kloneable) kloneNoFork() (string, error) {
+	local.Printf("Attempting git clone")
+	path, err := k.clone(k.repo)
+	if err != nil {
+		return "", err
+	}
+	err = k.setRemote(k.remoteName(NoForkRemote), k.repo.GitRemoteUrl())
 	if err != nil {
 		return path, err
 	}
@@ -80,9 +78,17 @@ func (k *Kloneable) kloneTryingFork() (string, error) {
 // We will clone to the parent's location on disk, but with our origin
 func (k *Kloneable) kloneNeedsFork() (string, error) {
 	local.Printf("Forking [%s/%s] to [%s/%s]", k.repo.Owner(), k.repo.Name(), k.gitServer.OwnerName(), k.repo.Name())
+	// Only forks we create are ours to delete if the klone fails, so we have to know
+	// there was no fork before we asked for one
+	_, existsErr := k.gitServer.GetRepoByOwner(k.gitServer.OwnerName(), k.repo.Name())
+	created := isNotFound(existsErr)
 	var newRepo provider.Repo
 	newRepo, err := k.gitServer.Fork(k.repo, k.gitServer.OwnerName())
-	if err != nil {
+	if err == provider.ErrForkNotSupported {
+		local.Printf("Unable to fork [%s/%s]: %v", k.repo.Owner(), k.repo.Name(), err)
+		k.style = StyleNoFork
+		return k.kloneNoFork()
+	} else if err != nil {
 		if strings.Contains(err.Error(), "job scheduled on GitHub side") || strings.Contains(err.Error(), "404 Not Found") {
 			// Forking might take a while, so poll for it
 			for i := 1; i <= waitForForkSeconds; i++ {
@@ -101,40 +107,51 @@ func (k *Kloneable) kloneNeedsFork() (string, error) {
 			return "", err
 		}
 	}
+	// A git server will hand us back a fork we already had, even if it has another name
+	if created && newRepo.Owner() == k.gitServer.OwnerName() && newRepo.Name() == k.repo.Name() {
+		k.tx.recordFork(newRepo)
+	}
+	k.fork = newRepo
 	local.Printf("Attempting git clone")
 	// clone with the original repo
-	path, err := k.kloner.Clone(k.repo)
+	path, err := k.clone(k.repo)
 	if err != nil {
 		return "", err
 	}
-	// Always delete origin
-	local.Printf("Register remote [origin]")
-	err = k.kloner.DeleteRemote("origin")
-	if err != nil && !strings.Contains(err.Error(), "remote not found") {
-		return path, err
-	}
 	// Add origin
-	err = k.kloner.AddRemote("origin", newRepo.GitRemoteUrl())
+	err = k.setRemote(k.remoteName("origin"), newRepo.GitRemoteUrl())
 	if err != nil {
 		return path, err
 	}
 
 	// Add Upstream
-	local.Printf("Register remote [upstream]")
-	err = k.kloner.DeleteRemote("upstream")
-	if err != nil && !strings.Contains(err.Error(), "remote not found") {
-		return path, err
-	}
-	err = k.kloner.AddRemote("upstream", k.repo.GitRemoteUrl())
+	upstream := k.remoteName("upstream")
+	err = k.setRemote(upstream, k.repo.GitRemoteUrl())
 	if err != nil {
 		return path, err
 	}
 
 	// Pull
-	err = k.kloner.Pull("upstream")
+	err = k.kloner.Pull(upstream)
 	if err != nil {
 		return path, err
 	}
 
 	return path, nil
 }
+
+// setRemote will register a remote, replacing any remote that already has the name
+func (k *Kloneable) setRemote(name, url string) error {
+	local.Printf("Register remote [%s]", name)
+	err := k.kloner.DeleteRemote(name)
+	if err != nil && !strings.Contains(err.Error(), "remote not found") {
+		return err
+	}
+	return k.kloner.AddRemote(name, url)
+}
+
+// isNotFound is true if a git server told us a repository does not exist, and not
+// just that it was unable to look it up
+func isNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "404")
+}
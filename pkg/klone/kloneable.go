@@ -24,11 +24,19 @@ package klone
 
 import (
 	"errors"
+	"fmt"
 	"github.com/kris-nova/klone/pkg/klone/kloners"
 	"github.com/kris-nova/klone/pkg/klone/kloners/gogit"
+	"github.com/kris-nova/klone/pkg/klone/kloners/javascript"
+	"github.com/kris-nova/klone/pkg/klone/kloners/jvm"
+	"github.com/kris-nova/klone/pkg/klone/kloners/python"
+	"github.com/kris-nova/klone/pkg/klone/kloners/rust"
 	"github.com/kris-nova/klone/pkg/klone/kloners/simple"
+	"github.com/kris-nova/klone/pkg/klonefile"
 	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/paths"
 	"github.com/kris-nova/klone/pkg/provider"
+	"path/filepath"
 	"strings"
 )
 
@@ -37,73 +45,304 @@ const (
 	StyleAlreadyForked Style = 2 // The user is the owner, and the repository was forked from somewhere
 	StyleNeedsFork     Style = 3 // The user is NOT the owner, and the user does NOT have a fork already
 	StyleTryingFork    Style = 4 // The user is NOT the owner, and the repository is already forked
+	StyleNoFork        Style = 5 // The git server is unable to fork, so we klone without one
 )
 
 // ForceKloner will force a kloner implementation if set
 var ForceKloner string
 
+// TrustKlonefile will run the commands a Klonefile defines after a klone, and
+// klone to it's path even if it is outside of the workspace
+var TrustKlonefile = false
+
+// NoForkRemote is the name of the only remote we register when we are unable to fork
+var NoForkRemote = "origin"
+
+// OriginRemote and UpstreamRemote are what we call our remotes, unless a Klonefile renames them
+var (
+	OriginRemote   = "origin"
+	UpstreamRemote = "upstream"
+)
+
 // NewKlonerFunc defines the type of function we expect for new kloners
 type NewKlonerFunc func(server provider.GitServer) kloners.Kloner
 
 // LanguageToKloner maps languages to kloners
 // All language keys should be lower case, and they are cast as such before assertion
 var LanguageToKloner = map[string]NewKlonerFunc{
-	"":   simple.NewKloner, // Empty lang can use a simple kloner
-	"go": gogit.NewKloner,  // Go gets a special kloner
+	"":           simple.NewKloner,     // Empty lang can use a simple kloner
+	"go":         gogit.NewKloner,      // Go gets a special kloner
+	"rust":       rust.NewKloner,       // Rust goes in it's own tree, and knows about Cargo
+	"python":     python.NewKloner,     // Python gets a virtual environment
+	"javascript": javascript.NewKloner, // JavaScript and TypeScript know their package manager
+	"typescript": javascript.NewKloner,
+	"java":       jvm.NewKloner, // Java and Kotlin are laid out by group, and know their build
+	"kotlin":     jvm.NewKloner,
 }
 
 // Kloneable is a data structure that holds all relevant data to klone a repository
 type Kloneable struct {
-	gitServer provider.GitServer
-	repo      provider.Repo
-	style     Style
-	kloner    kloners.Kloner
+	gitServer  provider.GitServer
+	repo       provider.Repo
+	style      Style
+	kloner     kloners.Kloner
+	klonerName string
+	selector   *kloners.Selector
+	tx         *transaction
+	klonefile  *klonefile.Klonefile
+	path       string // The clone directory from the Klonefile, if it has one
+	hooks      *klonefile.Hooks
+	fork       provider.Repo // The fork we created, if we created one
+	importPath string        // The vanity import path we found the repository with, if we did
 }
 
 // Klone is the only exported method, and is the only way to take action on a Kloneable data structure
 func (k *Kloneable) Klone() (string, error) {
 	k.findKloner() // First things first, we will need a kloner
+	k.useImportPath()
+	hooks, err := loadHooks(HooksFile)
+	if err != nil {
+		return "", err
+	}
+	k.hooks = hooks
+	k.tx = &transaction{}
+	path, err := k.klone()
+	if err != nil {
+		k.tx.rollback(k.gitServer)
+		return path, err
+	}
+	path, err = k.tx.commit()
+	if err != nil {
+		return path, err
+	}
+	if finisher, ok := k.kloner.(kloners.Finisher); ok {
+		err = finisher.Finish(path)
+		if err != nil {
+			// The klone is already in place, so there is nothing to roll back
+			return path, &finishError{path: path, err: err}
+		}
+	}
+	return path, nil
+}
+
+// finishError is a kloner that was unable to finish a klone, once it was in place
+type finishError struct {
+	path string
+	err  error
+}
+
+func (e *finishError) Error() string {
+	return fmt.Sprintf("unable to finish klone [%s]: %v", e.path, e.err)
+}
+
+// klone will run the klone for our style, and check out our selector
+func (k *Kloneable) klone() (string, error) {
+	var path string
+	var err error
 	switch k.style {
 	case StyleOwner:
-		return k.kloneOwner()
+		path, err = k.kloneOwner()
 	case StyleAlreadyForked:
-		return k.kloneAlreadyForked()
+		path, err = k.kloneAlreadyForked()
 	case StyleNeedsFork:
-		return k.kloneNeedsFork()
+		path, err = k.kloneNeedsFork()
 	case StyleTryingFork:
-		return k.kloneTryingFork()
+		path, err = k.kloneTryingFork()
+	case StyleNoFork:
+		path, err = k.kloneNoFork()
+	}
+	if err != nil {
+		return path, err
+	}
+	if k.selector != nil {
+		// Now that our remotes are configured, check out what was asked for
+		remote := k.selectorRemote()
+		local.Printf("Checking out [%s] from [%s]", k.selector, remote)
+		err = k.kloner.Checkout(remote, k.selector)
+		if err != nil {
+			return path, err
+		}
+	}
+	return path, k.runHook(klonefile.HookPostRemote, path)
+}
+
+// clone will clone a repository as part of our transaction, new repositories
+// are cloned into a staging directory until the klone has completed
+func (k *Kloneable) clone(repo provider.Repo) (string, error) {
+	dir, err := k.tx.begin(k.cloneDirectory(repo))
+	if err != nil {
+		return "", err
+	}
+	err = k.runHook(klonefile.HookPreClone, filepath.Dir(k.tx.path))
+	if err != nil {
+		return "", err
+	}
+	k.kloner.SetCloneDirectory(dir)
+	if k.klonefile != nil {
+		k.kloner.SetRecurseSubmodules(k.klonefile.RecurseSubmodules())
+	}
+	path, err := k.kloner.Clone(repo)
+	if err != nil {
+		return "", err
+	}
+	// Some kloners only know where a repository belongs once they have cloned it
+	if relocator, ok := k.kloner.(kloners.Relocator); ok && k.path == "" {
+		if moved := relocator.Relocate(repo, path); moved != "" {
+			local.Printf("Klone belongs in [%s]", moved)
+			dir, err = k.tx.relocate(moved)
+			if err != nil {
+				return "", err
+			}
+			if dir != path {
+				// There is already a klone, so we work on it in place
+				k.kloner.SetCloneDirectory(dir)
+				path, err = k.kloner.Clone(repo)
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+	// Always delete origin, the patterns register their own remotes
+	err = k.kloner.DeleteRemote("origin")
+	if err != nil && !strings.Contains(err.Error(), "remote not found") {
+		return path, err
+	}
+	return path, k.runHook(klonefile.HookPostClone, path)
+}
+
+// cloneDirectory is where we will clone a repository, a Klonefile wins over the kloner
+func (k *Kloneable) cloneDirectory(repo provider.Repo) string {
+	if k.path != "" {
+		return k.path
+	}
+	return k.kloner.GetCloneDirectory(repo)
+}
+
+// useImportPath will tell our kloner the import path we found the repository with
+func (k *Kloneable) useImportPath() {
+	if k.importPath == "" {
+		return
 	}
-	return "", nil
+	if importer, ok := k.kloner.(kloners.Importer); ok {
+		importer.SetImportPath(k.importPath)
+	}
+}
+
+// clonedRepo is the repository we clone, if we already have a fork we clone it's parent
+func (k *Kloneable) clonedRepo() provider.Repo {
+	if k.style == StyleAlreadyForked {
+		return k.repo.ForkedFrom()
+	}
+	return k.repo
+}
+
+// selectorRemote is the remote we check out a selector from. If we have
+// forked, refs (and pull requests) come from the parent repository.
+func (k *Kloneable) selectorRemote() string {
+	switch k.style {
+	case StyleOwner:
+		return k.remoteName("origin")
+	case StyleNoFork:
+		return k.remoteName(NoForkRemote)
+	}
+	return k.remoteName("upstream")
+}
+
+// remoteName is what we call the origin or upstream remote, a Klonefile wins over our own names
+func (k *Kloneable) remoteName(name string) string {
+	if k.klonefile != nil {
+		if name == "origin" && k.klonefile.Remotes.Origin != "" {
+			return k.klonefile.Remotes.Origin
+		}
+		if name == "upstream" && k.klonefile.Remotes.Upstream != "" {
+			return k.klonefile.Remotes.Upstream
+		}
+	}
+	switch name {
+	case "origin":
+		return OriginRemote
+	case "upstream":
+		return UpstreamRemote
+	}
+	return name
+}
+
+// loadKlonefile will fetch the Klonefile of the repository we are going to clone
+// (before we clone it) and render it's path.
+func (k *Kloneable) loadKlonefile() error {
+	repo := k.clonedRepo()
+	content := repo.GetKlonefile()
+	if len(content) == 0 {
+		return nil
+	}
+	local.Printf("Found %s in [%s/%s]", klonefile.Filename, repo.Owner(), repo.Name())
+	kf, err := klonefile.Parse(content)
+	if err != nil {
+		return err
+	}
+	if kf.Path != "" {
+		values := paths.NewValues(k.gitServer, repo)
+		path, err := kf.ClonePath(values)
+		if err != nil {
+			return fmt.Errorf("unable to find clone directory from %s: %v", klonefile.Filename, err)
+		}
+		// The path comes from the repository, so it stays in the workspace unless we trust it
+		if !TrustKlonefile && !paths.Under(values.Workspace, path) {
+			local.Printf("Skipping %s path [%s] outside of the workspace (use --trust-klonefile to use it)", klonefile.Filename, path)
+		} else {
+			k.path = path
+		}
+	}
+	k.klonefile = kf
+	return nil
+}
+
+// useKloner will use a kloner by name, and return false if we have no kloner by that name
+func (k *Kloneable) useKloner(name string) bool {
+	switch strings.ToLower(name) {
+	case "simple":
+		k.kloner = simple.NewKloner(k.gitServer)
+		k.klonerName = "simple"
+	case "gogit", "golang", "go":
+		k.kloner = gogit.NewKloner(k.gitServer)
+		k.klonerName = "golang"
+	case "rust":
+		k.kloner = rust.NewKloner(k.gitServer)
+		k.klonerName = "rust"
+	case "python":
+		k.kloner = python.NewKloner(k.gitServer)
+		k.klonerName = "python"
+	case "javascript", "typescript", "js", "ts":
+		k.kloner = javascript.NewKloner(k.gitServer)
+		k.klonerName = "javascript"
+	case "jvm", "java", "kotlin":
+		k.kloner = jvm.NewKloner(k.gitServer)
+		k.klonerName = "jvm"
+	default:
+		return false
+	}
+	local.Printf("Using kloner [%s]", k.klonerName)
+	return true
 }
 
 // findKloner is the logic that selects a kloner to use on a repository.
-// Todo (@kris-nova) let's support .Klonefile's!
+// A forced kloner wins over a Klonefile, and a Klonefile wins over the language.
 func (k *Kloneable) findKloner() error {
 	if k.gitServer == nil {
 		return errors.New("nil getServer")
 	}
 	if ForceKloner != "" {
-		//local.Printf("Trying force kloner [%s]", ForceKloner)
-		switch ForceKloner {
-		case "simple":
-			k.kloner = simple.NewKloner(k.gitServer)
-			local.Printf("Using kloner [%s]", "simple")
+		if k.useKloner(ForceKloner) {
 			return nil
-		case "gogit":
-			k.kloner = gogit.NewKloner(k.gitServer)
-			local.Printf("Using kloner [%s]", "golang")
-			return nil
-		case "golang":
-			k.kloner = gogit.NewKloner(k.gitServer)
-			local.Printf("Using kloner [%s]", "golang")
-			return nil
-		case "go":
-			k.kloner = gogit.NewKloner(k.gitServer)
-			local.Printf("Using kloner [%s]", "golang")
+		}
+		local.Printf("Unable to force kloner [%s] (not defined)", ForceKloner)
+	}
+	if k.klonefile != nil && k.klonefile.Kloner != "" {
+		if k.useKloner(k.klonefile.Kloner) {
 			return nil
-		default:
-			local.Printf("Unable to force kloner [%s] (not defined)", ForceKloner)
 		}
+		local.Printf("Unable to use kloner [%s] from %s (not defined)", k.klonefile.Kloner, klonefile.Filename)
 	}
 
 	var lang string
@@ -115,6 +354,7 @@ func (k *Kloneable) findKloner() error {
 		} else {
 			local.Printf("Unable to detect language, using Kloner [simple]")
 			k.kloner = simple.NewKloner(k.gitServer)
+			k.klonerName = "simple"
 			return nil
 		}
 	} else {
@@ -125,9 +365,11 @@ func (k *Kloneable) findKloner() error {
 		kloner := newKlonerFunc(k.gitServer)
 		local.Printf("Found Kloner [%s]", k.repo.Language())
 		k.kloner = kloner
+		k.klonerName = lowerlang
 	} else {
 		local.Printf("Unsupported language [%s], using Kloner [simple]", lowerlang)
 		k.kloner = simple.NewKloner(k.gitServer)
+		k.klonerName = "simple"
 	}
 	return nil
 }
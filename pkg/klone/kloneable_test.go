@@ -0,0 +1,102 @@
+package klone
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// klonefileRepo is a repository that ships a Klonefile
+type klonefileRepo struct {
+	provider.Repo
+	klonefile string
+}
+
+func (r *klonefileRepo) GetKlonefile() []byte { return []byte(r.klonefile) }
+
+// TestKlonefile will test a klone honors the path, remotes, and commands of a Klonefile
+func TestKlonefile(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	k.repo = &klonefileRepo{
+		Repo:      k.repo,
+		klonefile: "path: \"{{ .Owner }}/{{ .Name }}\"\nremotes:\n  origin: mine\nsubmodules: none\ncommands:\n  - touch kloned\n",
+	}
+	err := k.loadKlonefile()
+	if err != nil {
+		t.Fatalf("Unable to load Klonefile: %v", err)
+	}
+	kloned, err := k.Klone()
+	if err != nil {
+		t.Fatalf("Unable to klone: %v", err)
+	}
+	if expected := filepath.Join(filepath.Dir(path), "team", "service"); kloned != expected {
+		t.Fatalf("Expected klone in [%s], got [%s]", expected, kloned)
+	}
+	out, err := exec.Command("git", "-C", kloned, "remote").CombinedOutput()
+	if err != nil || strings.TrimSpace(string(out)) != "mine" {
+		t.Fatalf("Unexpected remotes: %v %s", err, out)
+	}
+
+	// Commands only run when we trust the Klonefile
+	err = k.runCommands(kloned)
+	if err != nil {
+		t.Fatalf("Unable to skip commands: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(kloned, "kloned")); !os.IsNotExist(err) {
+		t.Fatalf("Ran a command from an untrusted Klonefile: %v", err)
+	}
+	TrustKlonefile = true
+	defer func() { TrustKlonefile = false }()
+	err = k.runCommands(kloned)
+	if err != nil {
+		t.Fatalf("Unable to run commands: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(kloned, "kloned")); err != nil {
+		t.Fatalf("Command did not run: %v", err)
+	}
+}
+
+// TestKlonefileUntrustedPath will test a Klonefile is unable to send a klone outside
+// of the workspace unless we trust it
+func TestKlonefileUntrustedPath(t *testing.T) {
+	k, _, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	repo := k.repo
+	for _, path := range []string{"~/service", "/tmp/service", "../service", "{{ .Home }}/.ssh", "."} {
+		k.path = ""
+		k.repo = &klonefileRepo{Repo: repo, klonefile: fmt.Sprintf("path: \"%s\"\n", path)}
+		err := k.loadKlonefile()
+		if err != nil {
+			t.Fatalf("Unable to load Klonefile: %v", err)
+		}
+		if k.path != "" {
+			t.Fatalf("Untrusted Klonefile path [%s] used [%s]", path, k.path)
+		}
+	}
+	TrustKlonefile = true
+	defer func() { TrustKlonefile = false }()
+	k.repo = &klonefileRepo{Repo: repo, klonefile: "path: /tmp/service\n"}
+	err := k.loadKlonefile()
+	if err != nil {
+		t.Fatalf("Unable to load Klonefile: %v", err)
+	}
+	if k.path != "/tmp/service" {
+		t.Fatalf("Trusted Klonefile path not used: %s", k.path)
+	}
+}
+
+// TestKlonefileInvalid will test we refuse to klone with a Klonefile we do not understand
+func TestKlonefileInvalid(t *testing.T) {
+	k, _, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	k.repo = &klonefileRepo{Repo: k.repo, klonefile: "submodules: sometimes\n"}
+	err := k.loadKlonefile()
+	if err == nil {
+		t.Fatal("Able to load an invalid Klonefile")
+	}
+}
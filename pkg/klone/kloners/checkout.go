@@ -0,0 +1,241 @@
+package kloners
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/auth"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"strings"
+)
+
+// Checkout is the shared logic for checking out a Selector from a remote. Branches
+// and pull requests get a local branch that tracks the remote, tags and commits
+// are checked out with a detached HEAD.
+func Checkout(r *git.Repository, remote string, selector *Selector) error {
+	if r == nil {
+		return fmt.Errorf("unable to checkout [%s] without a repository", selector)
+	}
+	if selector.PullRequest > 0 {
+		return checkoutPullRequest(r, remote, selector)
+	}
+	return checkoutRef(r, remote, selector.Ref)
+}
+
+// IsCheckout is true if dir is a git repository with a remote for repo, which is what
+// a klone we already have looks like. A kloner that relocates a klone into a directory that
+// exists will work in it, so it should check it is ours first.
+func IsCheckout(dir string, repo provider.Repo) bool {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return false
+	}
+	remotes, err := r.Remotes()
+	if err != nil {
+		return false
+	}
+	for _, remote := range remotes {
+		url := remote.Config().URL
+		if url == repo.GitCloneUrl() || url == repo.GitRemoteUrl() || url == repo.HttpsCloneUrl() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkoutPullRequest will fetch the pull request head into refs/remotes/$remote/pr/$N
+// and check it out as the local branch pr-$N
+func checkoutPullRequest(r *git.Repository, remote string, selector *Selector) error {
+	if selector.PullRequestRef == "" {
+		return fmt.Errorf("unable to find a ref for pull request [%d]", selector.PullRequest)
+	}
+	local.Printf("Fetching pull request [%d] from [%s]", selector.PullRequest, remote)
+	hash, found, err := fetchRef(r, remote, selector.PullRequestRef, fmt.Sprintf("refs/remotes/%s/pr/%d", remote, selector.PullRequest))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("unable to find pull request [%d] on [%s]", selector.PullRequest, remote)
+	}
+	return checkoutBranch(r, fmt.Sprintf("pr-%d", selector.PullRequest), hash, remote, selector.PullRequestRef)
+}
+
+// checkoutRef will look for ref as a branch on the remote, then a tag, then a commit
+func checkoutRef(r *git.Repository, remote, ref string) error {
+	branchRef := fmt.Sprintf("refs/heads/%s", ref)
+	hash, found, err := fetchRef(r, remote, branchRef, fmt.Sprintf("refs/remotes/%s/%s", remote, ref))
+	if err != nil {
+		return err
+	}
+	if found {
+		return checkoutBranch(r, ref, hash, remote, branchRef)
+	}
+	tagRef := fmt.Sprintf("refs/tags/%s", ref)
+	hash, found, err = fetchRef(r, remote, tagRef, tagRef)
+	if err != nil {
+		return err
+	}
+	if found {
+		// Annotated tags point to a tag object, not a commit
+		if t, err := r.TagObject(hash); err == nil {
+			c, err := t.Commit()
+			if err != nil {
+				return fmt.Errorf("unable to find commit for tag [%s]: %v", ref, err)
+			}
+			hash = c.Hash
+		}
+		return checkoutHash(r, ref, hash)
+	}
+	hash, err = findCommit(r, ref)
+	if err != nil {
+		return err
+	}
+	return checkoutHash(r, ref, hash)
+}
+
+// checkoutBranch will create (if needed) a local branch that tracks merge on
+// remote, and check it out. Existing local branches are never moved.
+func checkoutBranch(r *git.Repository, name string, hash plumbing.Hash, remote, merge string) error {
+	branchRef := plumbing.ReferenceName(fmt.Sprintf("refs/heads/%s", name))
+	existing, err := r.Reference(branchRef, true)
+	if err == nil {
+		local.Printf("Branch [%s] already exists at [%s]", name, existing.Hash())
+	} else {
+		local.Printf("Creating branch [%s] tracking [%s][%s]", name, remote, merge)
+		err = r.Storer.SetReference(plumbing.NewHashReference(branchRef, hash))
+		if err != nil {
+			return fmt.Errorf("unable to create branch [%s]: %v", name, err)
+		}
+		cfg, err := r.Config()
+		if err != nil {
+			return err
+		}
+		cfg.Raw.Section("branch").Subsection(name).SetOption("remote", remote).SetOption("merge", merge)
+		err = r.Storer.SetConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to track [%s] with branch [%s]: %v", merge, name, err)
+		}
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	local.Printf("Checking out branch [%s]", name)
+	err = w.Checkout(&git.CheckoutOptions{Branch: branchRef})
+	if err != nil {
+		return fmt.Errorf("unable to checkout branch [%s]: %v", name, err)
+	}
+	return nil
+}
+
+// checkoutHash will check out a detached HEAD
+func checkoutHash(r *git.Repository, ref string, hash plumbing.Hash) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	local.Printf("Checking out [%s] at [%s]", ref, hash)
+	err = w.Checkout(&git.CheckoutOptions{Hash: hash})
+	if err != nil {
+		return fmt.Errorf("unable to checkout [%s]: %v", ref, err)
+	}
+	return nil
+}
+
+// findCommit will find a commit we already have by it's full or abbreviated hash
+func findCommit(r *git.Repository, ref string) (plumbing.Hash, error) {
+	notFound := fmt.Errorf("unable to find branch, tag, or commit [%s]", ref)
+	if len(ref) < 4 || len(ref) > 40 {
+		return plumbing.ZeroHash, notFound
+	}
+	if _, err := hex.DecodeString(ref + strings.Repeat("0", len(ref)%2)); err != nil {
+		return plumbing.ZeroHash, notFound
+	}
+	ref = strings.ToLower(ref)
+	commits, err := r.CommitObjects()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	var found []plumbing.Hash
+	err = commits.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), ref) {
+			found = append(found, c.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if len(found) > 1 {
+		return plumbing.ZeroHash, fmt.Errorf("commit [%s] is ambiguous", ref)
+	}
+	if len(found) == 0 {
+		return plumbing.ZeroHash, notFound
+	}
+	return found[0], nil
+}
+
+// fetchRef will fetch remoteRef from a remote into localRef, and return the hash
+// of the ref and if the remote has it. We always set localRef ourselves, as go-git
+// will not update references for objects we already have.
+func fetchRef(r *git.Repository, remote, remoteRef, localRef string) (plumbing.Hash, bool, error) {
+	rem, err := r.Remote(remote)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	url := rem.Config().URL
+	pk, err := auth.GetTransportForUrl(url)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	refs, err := lsRemote(url, pk)
+	if err != nil {
+		return plumbing.ZeroHash, false, fmt.Errorf("unable to list references on [%s]: %v", remote, err)
+	}
+	hash, ok := refs[remoteRef]
+	if !ok {
+		return plumbing.ZeroHash, false, nil
+	}
+	f := &git.FetchOptions{
+		RemoteName: remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", remoteRef, localRef))},
+		Auth:       pk,
+	}
+	err = r.Fetch(f)
+	if err != nil && !strings.Contains(err.Error(), "already up-to-date") {
+		return plumbing.ZeroHash, false, fmt.Errorf("unable to fetch [%s] from [%s]: %v", remoteRef, remote, err)
+	}
+	err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(localRef), hash))
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	return hash, true, nil
+}
+
+// lsRemote will return the references advertised by a remote url
+func lsRemote(url string, pk transport.AuthMethod) (map[string]plumbing.Hash, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return nil, err
+	}
+	sess, err := c.NewUploadPackSession(ep, pk)
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+	ar, err := sess.AdvertisedReferences()
+	if err != nil {
+		return nil, err
+	}
+	return ar.References, nil
+}
@@ -6,16 +6,19 @@ import (
 	"github.com/kris-nova/klone/pkg/auth"
 	"github.com/kris-nova/klone/pkg/klone/kloners"
 	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/paths"
 	"github.com/kris-nova/klone/pkg/provider"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
-	"os"
 	"strings"
 )
 
 type Kloner struct {
-	gitServer provider.GitServer
-	r         *git.Repository
+	gitServer    provider.GitServer
+	r            *git.Repository
+	cloneDir     string
+	noSubmodules bool
+	importPath   string
 }
 
 // This is the logic that defins a Clone() for a Go repository
@@ -26,7 +29,13 @@ func (k *Kloner) Clone(repo provider.Repo) (string, error) {
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 		//Progress:          os.Stdout,
 	}
+	if k.noSubmodules {
+		o.RecurseSubmodules = git.NoRecurseSubmodules
+	}
 	path := k.GetCloneDirectory(repo)
+	if k.cloneDir != "" {
+		path = k.cloneDir
+	}
 	local.Printf("Cloning into $GOPATH [%s]", path)
 	r, err := git.PlainClone(path, false, o)
 	if err != nil {
@@ -61,6 +70,26 @@ func (k *Kloner) Clone(repo provider.Repo) (string, error) {
 	return path, nil
 }
 
+// SetCloneDirectory will override the directory Clone() clones into
+func (k *Kloner) SetCloneDirectory(path string) {
+	k.cloneDir = path
+}
+
+// SetRecurseSubmodules will decide if Clone() clones submodules (it does by default)
+func (k *Kloner) SetRecurseSubmodules(recurse bool) {
+	k.noSubmodules = !recurse
+}
+
+// SetImportPath will place the repository by it's import path instead of by where the git server keeps it
+func (k *Kloner) SetImportPath(importPath string) {
+	k.importPath = importPath
+}
+
+// Checkout will check out a branch, tag, commit, or pull request from a remote
+func (k *Kloner) Checkout(remote string, selector *kloners.Selector) error {
+	return kloners.Checkout(k.r, remote, selector)
+}
+
 func (k *Kloner) DeleteRemote(name string) error {
 	err := k.r.DeleteRemote(name)
 	if err != nil {
@@ -87,7 +116,7 @@ func (k *Kloner) AddRemote(name, url string) error {
 	}
 
 	local.Printf("Fetching remote [%s]", url)
-	pk, err := auth.GetTransport()
+	pk, err := auth.GetTransportForUrl(url)
 	if err != nil {
 		return err
 	}
@@ -108,7 +137,11 @@ func (k *Kloner) AddRemote(name, url string) error {
 }
 
 func (k *Kloner) Pull(name string) error {
-	pk, err := auth.GetTransport()
+	remote, err := k.r.Remote(name)
+	if err != nil {
+		return err
+	}
+	pk, err := auth.GetTransportForUrl(remote.Config().URL)
 	if err != nil {
 		return err
 	}
@@ -135,47 +168,22 @@ func NewKloner(srv provider.GitServer) kloners.Kloner {
 	}
 }
 
-type customPathFunc func(repo provider.Repo) string
-
-// customPaths is a map of git owners to conversion functions
-var customPaths = map[string]customPathFunc{
-	"kubernetes": repoToKubernetesPath,
-}
-
 // repoToCloneDirectory will take a repository and reason about
 // where to check out the repository on your local filesystem
 func (k *Kloner) GetCloneDirectory(repo provider.Repo) string {
-	var path string
-	// Default path
-	path = fmt.Sprintf("%s/src/%s/%s/%s", Gopath(), k.gitServer.GetServerString(), repo.Owner(), repo.Name())
-	// Check for custom path overrides
-	for owner, customFunc := range customPaths {
-		if repo.Owner() == owner {
-			path = customFunc(repo)
-			//local.Printf("Local path override to [%s]", path)
-			break
-		}
+	if path, ok := paths.Configured(k.gitServer, repo, "go"); ok {
+		return path
 	}
-	return path
+	if Mode == ModeModule {
+		return fmt.Sprintf("%s/%s", workspace(), repo.Name())
+	}
+	if k.importPath != "" {
+		return fmt.Sprintf("%s/src/%s", Gopath(), k.importPath)
+	}
+	return fmt.Sprintf("%s/src/%s/%s/%s", Gopath(), k.gitServer.GetServerString(), repo.Owner(), repo.Name())
 }
 
 // Logic for getting $GOPATH
 func Gopath() string {
-	epath := os.Getenv("GOPATH")
-	if epath == "" {
-		// It's now safe to assume $HOME/go
-		// thanks to Dave Cheney and the folks
-		// who work on the standard library
-		// https://github.com/golang/go/issues/17262
-		path := fmt.Sprintf("%s/go", local.Home())
-		return path
-	} else if strings.Contains(epath, ":") {
-		espl := strings.Split(epath, ":")
-		if len(espl) <= 1 {
-			return epath
-		}
-		// Here we will take the first gopath defined and use that
-		return espl[0]
-	}
-	return epath
+	return paths.Gopath()
 }
@@ -0,0 +1,197 @@
+package gogit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/paths"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ModeGopath = "gopath" // Klone into $GOPATH/src/$importpath
+	ModeModule = "module" // Klone into a workspace, Go modules do not care where they live
+)
+
+var (
+	// Mode is how we lay out Go repositories on disk
+	Mode = ModeGopath
+
+	// Workspace is where we klone in module mode, $KLONE_WORKSPACE (or the
+	// current directory) if it is not set
+	Workspace string
+
+	// GoWork is a go.work file we add klones to in module mode
+	GoWork string
+)
+
+var (
+	majorVersionRegExp  = regexp.MustCompile(`/v[0-9]+$`)
+	importCommentRegExp = regexp.MustCompile(`(?m)^package[ \t]+\w+[ \t]*//[ \t]*import[ \t]+"([^"]+)"`)
+)
+
+// Relocate will read the import path a repository declares. In GOPATH mode a repository
+// belongs at it's import path (E.G. a vanity import like k8s.io/...), not where the git
+// server keeps it.
+func (k *Kloner) Relocate(repo provider.Repo, cloned string) string {
+	if Mode != ModeGopath {
+		return ""
+	}
+	if _, ok := paths.Configured(k.gitServer, repo, "go"); ok {
+		// A path template wins over the import path
+		return ""
+	}
+	importPath := DeclaredImportPath(cloned)
+	if importPath == "" {
+		return ""
+	}
+	// The import path comes from the repository, so it has to stay in $GOPATH/src
+	err := CheckImportPath(importPath)
+	if err != nil {
+		local.RecoverableErrorf("Unable to use import path [%s]: %v", importPath, err)
+		return ""
+	}
+	path := fmt.Sprintf("%s/src/%s", Gopath(), importPath)
+	if path == k.GetCloneDirectory(repo) {
+		return ""
+	}
+	if _, err := os.Stat(path); err == nil && !kloners.IsCheckout(path, repo) {
+		local.RecoverableErrorf("Unable to klone into [%s], it is not a klone of [%s/%s]", path, repo.Owner(), repo.Name())
+		return ""
+	}
+	return path
+}
+
+// CheckImportPath will make sure an import path is only elements of the characters
+// Go allows, so it is unable to leave $GOPATH/src (E.G. module ../../.ssh)
+func CheckImportPath(importPath string) error {
+	if importPath == "" {
+		return fmt.Errorf("empty import path")
+	}
+	for _, elem := range strings.Split(importPath, "/") {
+		if elem == "" {
+			return fmt.Errorf("empty path element")
+		}
+		if strings.HasPrefix(elem, ".") || strings.HasSuffix(elem, ".") {
+			return fmt.Errorf("path element [%s] begins or ends with a dot", elem)
+		}
+		for _, r := range elem {
+			if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("-._~+", r)) {
+				return fmt.Errorf("invalid character [%q] in path element [%s]", r, elem)
+			}
+		}
+	}
+	return nil
+}
+
+// Finish will add a klone to our go.work file in module mode
+func (k *Kloner) Finish(path string) error {
+	if Mode != ModeModule || GoWork == "" {
+		return nil
+	}
+	gowork := local.Expand(GoWork)
+	local.Printf("Adding [%s] to [%s]", path, gowork)
+	return addToGoWork(gowork, path)
+}
+
+// DeclaredImportPath is the import path a repository declares in it's go.mod, or in
+// an import comment (E.G. package yaml // import "gopkg.in/yaml.v2") if it has no go.mod
+func DeclaredImportPath(dir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "go.mod"))
+	if err == nil {
+		// Major versions live in the same repository, GOPATH has no idea about /v2
+		return majorVersionRegExp.ReplaceAllString(ModulePath(content), "")
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return ""
+	}
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if importPath := ImportComment(content); importPath != "" {
+			return importPath
+		}
+	}
+	return ""
+}
+
+// ImportComment will find the import comment on the package clause of a go file
+func ImportComment(src []byte) string {
+	match := importCommentRegExp.FindSubmatch(src)
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// ModulePath will find the module path in the content of a go.mod
+func ModulePath(gomod []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(gomod))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "module" {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+			return unquoted
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// addToGoWork will add a use directive for path to a go.work file, and create it if we need to
+func addToGoWork(gowork, path string) error {
+	use := path
+	if rel, err := filepath.Rel(filepath.Dir(gowork), path); err == nil && !strings.HasPrefix(rel, "..") {
+		use = "./" + filepath.ToSlash(rel)
+	}
+	content, err := ioutil.ReadFile(gowork)
+	if os.IsNotExist(err) {
+		content = []byte("go 1.18\n")
+	} else if err != nil {
+		return fmt.Errorf("unable to read [%s]: %v", gowork, err)
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[len(fields)-1] == use {
+			local.Printf("Found [%s] in [%s]", use, gowork)
+			return nil
+		}
+	}
+	if !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	content = append(content, []byte(fmt.Sprintf("\nuse %s\n", use))...)
+	err = ioutil.WriteFile(gowork, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write [%s]: %v", gowork, err)
+	}
+	return nil
+}
+
+// workspace is where we klone in module mode, --go-workspace wins over $KLONE_WORKSPACE
+func workspace() string {
+	if Workspace != "" {
+		return local.Expand(Workspace)
+	}
+	return paths.Workspace()
+}
@@ -0,0 +1,154 @@
+package gogit
+
+import (
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModulePath(t *testing.T) {
+	cases := map[string]string{
+		"module k8s.io/kubernetes\n\ngo 1.20\n":         "k8s.io/kubernetes",
+		"// Copyright\nmodule \"example.com/quoted\"\n": "example.com/quoted",
+		"module github.com/team/service // comment\n":   "github.com/team/service",
+		"go 1.20\n": "",
+	}
+	for gomod, expected := range cases {
+		if actual := ModulePath([]byte(gomod)); actual != expected {
+			t.Fatalf("Expected [%s] for [%s], got [%s]", expected, gomod, actual)
+		}
+	}
+}
+
+// modulesRepo is a repository that only knows it's owner and name
+type modulesRepo struct {
+	provider.Repo
+	owner, name string
+}
+
+func (r *modulesRepo) Owner() string    { return r.owner }
+func (r *modulesRepo) Name() string     { return r.name }
+func (r *modulesRepo) Language() string { return "" }
+
+// modulesServer is a git server that only knows it's name
+type modulesServer struct {
+	provider.GitServer
+}
+
+func (s *modulesServer) GetServerString() string { return "github.com" }
+
+func TestRelocate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-gogit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gopath := os.Getenv("GOPATH")
+	os.Setenv("GOPATH", filepath.Join(dir, "go"))
+	defer os.Setenv("GOPATH", gopath)
+	k := &Kloner{gitServer: &modulesServer{}}
+	repo := &modulesRepo{owner: "team", name: "service"}
+	cloned := filepath.Join(dir, "cloned")
+	os.MkdirAll(cloned, 0755)
+
+	cases := map[string]string{
+		"":                                 "",
+		"module github.com/team/service\n": "",
+		"module example.com/service\n":     filepath.Join(dir, "go", "src", "example.com", "service"),
+		"module example.com/service/v3\n":  filepath.Join(dir, "go", "src", "example.com", "service"),
+		"module ../../.ssh\n":              "",
+		"module /etc/service\n":            "",
+		"module example.com//service\n":    "",
+		"module example.com/taken\n":       "",
+	}
+	// A directory that is not a klone of the repository is never ours to klone into
+	os.MkdirAll(filepath.Join(dir, "go", "src", "example.com", "taken"), 0755)
+	for gomod, expected := range cases {
+		os.Remove(filepath.Join(cloned, "go.mod"))
+		if gomod != "" {
+			ioutil.WriteFile(filepath.Join(cloned, "go.mod"), []byte(gomod), 0644)
+		}
+		if actual := k.Relocate(repo, cloned); actual != expected {
+			t.Fatalf("Expected [%s] for [%s], got [%s]", expected, gomod, actual)
+		}
+	}
+	Mode = ModeModule
+	defer func() { Mode = ModeGopath }()
+	ioutil.WriteFile(filepath.Join(cloned, "go.mod"), []byte("module example.com/service\n"), 0644)
+	if actual := k.Relocate(repo, cloned); actual != "" {
+		t.Fatalf("Relocated in module mode: %s", actual)
+	}
+}
+
+func TestImportComment(t *testing.T) {
+	cases := map[string]string{
+		"package yaml // import \"gopkg.in/yaml.v2\"\n":                "gopkg.in/yaml.v2",
+		"// Package api\npackage api   //import \"example.com/api\"\n": "example.com/api",
+		"package main\n\n// import \"example.com/nope\"\n":             "",
+	}
+	for src, expected := range cases {
+		if actual := ImportComment([]byte(src)); actual != expected {
+			t.Fatalf("Expected [%s] for [%s], got [%s]", expected, src, actual)
+		}
+	}
+}
+
+func TestDeclaredImportPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-gogit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if actual := DeclaredImportPath(dir); actual != "" {
+		t.Fatalf("Found an import path in an empty directory: %s", actual)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "doc_test.go"), []byte("package api // import \"example.com/test\"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "doc.go"), []byte("package api // import \"example.com/api\"\n"), 0644)
+	if actual := DeclaredImportPath(dir); actual != "example.com/api" {
+		t.Fatalf("Unexpected import path from import comment: %s", actual)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/api/v2\n"), 0644)
+	if actual := DeclaredImportPath(dir); actual != "example.com/api" {
+		t.Fatalf("Unexpected import path from go.mod: %s", actual)
+	}
+}
+
+func TestGetCloneDirectoryImportPath(t *testing.T) {
+	gopath := os.Getenv("GOPATH")
+	os.Setenv("GOPATH", "/go")
+	defer os.Setenv("GOPATH", gopath)
+	k := &Kloner{gitServer: &modulesServer{}}
+	repo := &modulesRepo{owner: "golang", name: "tools"}
+	if actual := k.GetCloneDirectory(repo); actual != "/go/src/github.com/golang/tools" {
+		t.Fatalf("Unexpected clone directory: %s", actual)
+	}
+	k.SetImportPath("golang.org/x/tools")
+	if actual := k.GetCloneDirectory(repo); actual != "/go/src/golang.org/x/tools" {
+		t.Fatalf("Unexpected clone directory for import path: %s", actual)
+	}
+}
+
+func TestAddToGoWork(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-gowork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	gowork := filepath.Join(dir, "go.work")
+	for _, path := range []string{filepath.Join(dir, "service"), "/elsewhere/lib", filepath.Join(dir, "service")} {
+		err = addToGoWork(gowork, path)
+		if err != nil {
+			t.Fatalf("Unable to add [%s] to go.work: %v", path, err)
+		}
+	}
+	content, err := ioutil.ReadFile(gowork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "go 1.18\n\nuse ./service\n\nuse /elsewhere/lib\n"
+	if string(content) != expected {
+		t.Fatalf("Unexpected go.work:\n%s", content)
+	}
+}
@@ -1,6 +1,9 @@
 package kloners
 
-import "github.com/kris-nova/klone/pkg/provider"
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+)
 
 type Kloner interface {
 	Clone(repo provider.Repo) (string, error)
@@ -8,4 +11,43 @@ type Kloner interface {
 	AddRemote(name, url string) error
 	DeleteRemote(name string) error
 	GetCloneDirectory(repo provider.Repo) string
+	SetCloneDirectory(path string)
+	SetRecurseSubmodules(recurse bool)
+	Checkout(remote string, selector *Selector) error
+}
+
+// Relocator is a Kloner that only knows where a repository belongs once it has
+// been cloned. Relocate returns the new directory, or "" if it is fine where it is.
+type Relocator interface {
+	Relocate(repo provider.Repo, cloned string) string
+}
+
+// Importer is a Kloner that places a repository by the import path we found it with
+type Importer interface {
+	SetImportPath(importPath string)
+}
+
+// Environer is a Kloner that can describe a repository it has cloned to the commands we run in it
+type Environer interface {
+	Environ() []string
+}
+
+// Finisher is a Kloner with work to do once a klone has been moved into place. The klone
+// is kept if Finish fails, there is nothing left to roll back by then.
+type Finisher interface {
+	Finish(path string) error
+}
+
+// Selector is what a Kloner should check out once the remotes are configured
+type Selector struct {
+	Ref            string // A branch, tag, or commit
+	PullRequest    int    // A pull request number
+	PullRequestRef string // Where the git server keeps the pull request (E.G. refs/pull/42/head)
+}
+
+func (s *Selector) String() string {
+	if s.PullRequest > 0 {
+		return fmt.Sprintf("#%d", s.PullRequest)
+	}
+	return fmt.Sprintf("@%s", s.Ref)
 }
@@ -0,0 +1,158 @@
+package javascript
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klone/kloners/simple"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	NPM  = "npm"
+	Yarn = "yarn"
+	PNPM = "pnpm"
+)
+
+// Install will install the dependencies of a JavaScript repository with it's package manager
+var Install = false
+
+// lockfiles are how we know which package manager a repository uses, in order
+var lockfiles = []struct {
+	file    string
+	manager string
+}{
+	{"pnpm-lock.yaml", PNPM},
+	{"yarn.lock", Yarn},
+	{"package-lock.json", NPM},
+	{"npm-shrinkwrap.json", NPM},
+}
+
+var versionRegExp = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+)*$`)
+
+// Kloner clones a JavaScript (or TypeScript) repository like any other repository,
+// and then reasons about the package manager and Node version it needs
+type Kloner struct {
+	kloners.Kloner
+	manager     string
+	nodeVersion string
+}
+
+func (k *Kloner) Clone(repo provider.Repo) (string, error) {
+	path, err := k.Kloner.Clone(repo)
+	if err != nil {
+		return path, err
+	}
+	k.manager = PackageManager(path)
+	if k.manager != "" {
+		local.Printf("Found package manager [%s]", k.manager)
+	}
+	k.nodeVersion = NodeVersion(path)
+	if k.nodeVersion != "" {
+		local.Printf("Found Node version [%s] in .nvmrc", k.nodeVersion)
+		checkNode(k.nodeVersion)
+	}
+	return path, nil
+}
+
+// Environ will describe the package manager and Node version to the commands we run in the klone
+func (k *Kloner) Environ() []string {
+	return []string{
+		fmt.Sprintf("KLONE_PACKAGE_MANAGER=%s", k.manager),
+		fmt.Sprintf("KLONE_NODE_VERSION=%s", k.nodeVersion),
+	}
+}
+
+// Finish will install dependencies with the package manager, if we were asked to
+func (k *Kloner) Finish(path string) error {
+	if !Install || k.manager == "" {
+		return nil
+	}
+	local.Printf("Running [%s install]", k.manager)
+	cmd := exec.Command(k.manager, "install")
+	cmd.Dir = path
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("unable to install dependencies with [%s]: %v", k.manager, err)
+	}
+	return nil
+}
+
+// PackageManager is the package manager a repository uses. The packageManager in
+// package.json wins over lockfiles, and a package.json with neither uses npm.
+func PackageManager(path string) string {
+	content, err := ioutil.ReadFile(filepath.Join(path, "package.json"))
+	if err != nil {
+		return ""
+	}
+	pkg := struct {
+		PackageManager string `json:"packageManager"`
+	}{}
+	if json.Unmarshal(content, &pkg) == nil && pkg.PackageManager != "" {
+		// E.G. pnpm@8.6.0
+		manager := strings.SplitN(pkg.PackageManager, "@", 2)[0]
+		switch manager {
+		case NPM, Yarn, PNPM:
+			return manager
+		}
+	}
+	for _, lockfile := range lockfiles {
+		if _, err := os.Stat(filepath.Join(path, lockfile.file)); err == nil {
+			return lockfile.manager
+		}
+	}
+	return NPM
+}
+
+// NodeVersion is the Node version a repository asks for in it's .nvmrc
+func NodeVersion(path string) string {
+	content, err := ioutil.ReadFile(filepath.Join(path, ".nvmrc"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// checkNode will warn if the node on $PATH is not the version we need. Aliases
+// like lts/* are left to nvm.
+func checkNode(version string) {
+	if !versionRegExp.MatchString(version) {
+		return
+	}
+	out, err := exec.Command("node", "--version").Output()
+	if err != nil {
+		local.Printf("Unable to find node on $PATH, this repository needs Node [%s]", version)
+		return
+	}
+	if !MatchesVersion(strings.TrimSpace(string(out)), version) {
+		local.RecoverableErrorf("node on $PATH is [%s], this repository needs Node [%s] (nvm use)", strings.TrimSpace(string(out)), version)
+	}
+}
+
+// MatchesVersion is true if a Node version (E.G. v18.17.0) is the version we asked for (E.G. 18 or v18.17)
+func MatchesVersion(actual, wanted string) bool {
+	actual = strings.TrimPrefix(actual, "v")
+	wanted = strings.TrimPrefix(wanted, "v")
+	return actual == wanted || strings.HasPrefix(actual, wanted+".")
+}
+
+func NewKloner(srv provider.GitServer) kloners.Kloner {
+	return &Kloner{
+		Kloner: simple.NewLanguageKloner(srv, "javascript"),
+	}
+}
@@ -0,0 +1,89 @@
+package javascript
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newPackage will write the files of a JavaScript package into a new directory
+func newPackage(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "klone-javascript")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPackageManager(t *testing.T) {
+	cases := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"package.json": "{}"}, NPM},
+		{map[string]string{"package.json": "{}", "package-lock.json": "{}"}, NPM},
+		{map[string]string{"package.json": "{}", "yarn.lock": ""}, Yarn},
+		{map[string]string{"package.json": "{}", "pnpm-lock.yaml": "", "package-lock.json": "{}"}, PNPM},
+		{map[string]string{"package.json": `{"packageManager": "yarn@4.0.2"}`, "package-lock.json": "{}"}, Yarn},
+		{map[string]string{"package.json": `{"packageManager": "bun@1.0.0"}`, "pnpm-lock.yaml": ""}, PNPM},
+	}
+	for _, c := range cases {
+		dir := newPackage(t, c.files)
+		defer os.RemoveAll(dir)
+		if actual := PackageManager(dir); actual != c.expected {
+			t.Fatalf("Expected [%s] for %v, got [%s]", c.expected, c.files, actual)
+		}
+	}
+}
+
+func TestNodeVersion(t *testing.T) {
+	dir := newPackage(t, map[string]string{".nvmrc": "# The LTS we test with\nv18.17\n"})
+	defer os.RemoveAll(dir)
+	if version := NodeVersion(dir); version != "v18.17" {
+		t.Fatalf("Unexpected Node version: %s", version)
+	}
+	cases := map[[2]string]bool{
+		{"v18.17.0", "18"}:      true,
+		{"v18.17.0", "v18.17"}:  true,
+		{"v18.17.0", "18.17.0"}: true,
+		{"v18.17.0", "18.1"}:    false,
+		{"v20.1.0", "v18"}:      false,
+	}
+	for versions, expected := range cases {
+		if actual := MatchesVersion(versions[0], versions[1]); actual != expected {
+			t.Fatalf("Expected [%t] for %v", expected, versions)
+		}
+	}
+}
+
+func TestFinish(t *testing.T) {
+	dir := newPackage(t, map[string]string{"fake-manager": "#!/bin/sh\necho \"$*\" > installed\n"})
+	defer os.RemoveAll(dir)
+	os.Chmod(filepath.Join(dir, "fake-manager"), 0755)
+	k := &Kloner{manager: filepath.Join(dir, "fake-manager")}
+	err := k.Finish(dir)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "installed")); err == nil {
+		t.Fatal("Installed without --npm-install")
+	}
+	Install = true
+	defer func() { Install = false }()
+	err = k.Finish(dir)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "installed"))
+	if err != nil || string(content) != "install\n" {
+		t.Fatalf("Unexpected install: %s %v", content, err)
+	}
+}
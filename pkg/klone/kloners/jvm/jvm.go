@@ -0,0 +1,191 @@
+package jvm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klone/kloners/simple"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/paths"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	Maven  = "maven"
+	Gradle = "gradle"
+)
+
+var (
+	// Root is where we klone Java and Kotlin repositories, as $Root/$group/$name
+	Root = "~/src/jvm"
+
+	// WarmCache will download the dependencies of a build once a repository is kloned
+	WarmCache = false
+
+	// A group is a Java package name, E.G. org.example
+	groupRegExp = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)*$`)
+
+	// E.G. group = "org.example", group 'org.example', or group=org.example in gradle.properties
+	gradleGroupRegExp = regexp.MustCompile(`(?m)^[ \t]*group[ \t]*(?:=[ \t]*["']?([\w.-]+)["']?|["']([\w.-]+)["'])[ \t]*$`)
+)
+
+// Build is the build tool a repository uses
+type Build struct {
+	Tool    string // maven or gradle
+	Command string // The wrapper (E.G. ./gradlew) if the repository has one, or the tool on $PATH
+}
+
+// warmArgs are the arguments that will download every dependency of a build
+var warmArgs = map[string][]string{
+	Maven:  {"--batch-mode", "--quiet", "dependency:go-offline"},
+	Gradle: {"--quiet", "dependencies"},
+}
+
+// Kloner clones a Java or Kotlin repository like any other repository, all we
+// care about is where it goes and the build tool it needs once it is there
+type Kloner struct {
+	*simple.RootKloner
+	build *Build
+}
+
+func (k *Kloner) Clone(repo provider.Repo) (string, error) {
+	path, err := k.RootKloner.Clone(repo)
+	if err != nil {
+		return path, err
+	}
+	k.build = FindBuild(path)
+	if k.build != nil {
+		local.Printf("Found build [%s] run with [%s]", k.build.Tool, k.build.Command)
+	}
+	return path, nil
+}
+
+// Relocate will move a klone to the group it's build declares (E.G. $Root/org.example/service).
+// We clone a repository before we know it's group, so it starts out under it's owner.
+func (k *Kloner) Relocate(repo provider.Repo, cloned string) string {
+	if _, ok := k.Configured(repo); ok {
+		// A path template wins over the group
+		return ""
+	}
+	group := Group(cloned)
+	if group == "" {
+		return ""
+	}
+	// The group comes from the repository, so it has to be a Java package name that stays in $Root
+	if !groupRegExp.MatchString(group) {
+		local.RecoverableErrorf("Unable to use group [%s], it is not a Java package name", group)
+		return ""
+	}
+	path := k.Path(group, repo.Name())
+	if path == k.GetCloneDirectory(repo) {
+		return ""
+	}
+	if !paths.Under(local.Expand(Root), path) {
+		local.RecoverableErrorf("Unable to klone into [%s], it is outside of [%s]", path, Root)
+		return ""
+	}
+	if _, err := os.Stat(path); err == nil && !kloners.IsCheckout(path, repo) {
+		local.RecoverableErrorf("Unable to klone into [%s], it is not a klone of [%s/%s]", path, repo.Owner(), repo.Name())
+		return ""
+	}
+	return path
+}
+
+// Environ will describe the build to the commands we run in the klone
+func (k *Kloner) Environ() []string {
+	build := &Build{}
+	if k.build != nil {
+		build = k.build
+	}
+	return []string{
+		fmt.Sprintf("KLONE_BUILD_TOOL=%s", build.Tool),
+		fmt.Sprintf("KLONE_BUILD_COMMAND=%s", build.Command),
+	}
+}
+
+// Finish will warm the dependency cache of the build, if we were asked to
+func (k *Kloner) Finish(path string) error {
+	if !WarmCache || k.build == nil {
+		return nil
+	}
+	args := warmArgs[k.build.Tool]
+	local.Printf("Warming dependency cache [%s %s]", k.build.Command, strings.Join(args, " "))
+	cmd := exec.Command(k.build.Command, args...)
+	cmd.Dir = path
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("unable to warm dependency cache with [%s]: %v", k.build.Command, err)
+	}
+	return nil
+}
+
+// FindBuild will find the build tool of a repository, and prefer it's wrapper script.
+// Gradle wins if a repository has both.
+func FindBuild(path string) *Build {
+	switch {
+	case exists(path, "build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts"):
+		if exists(path, "gradlew") {
+			return &Build{Tool: Gradle, Command: "./gradlew"}
+		}
+		return &Build{Tool: Gradle, Command: "gradle"}
+	case exists(path, "pom.xml"):
+		if exists(path, "mvnw") {
+			return &Build{Tool: Maven, Command: "./mvnw"}
+		}
+		return &Build{Tool: Maven, Command: "mvn"}
+	}
+	return nil
+}
+
+// Group is the group a build declares, the groupId of a pom.xml (or it's parent)
+// or the group of a Gradle build
+func Group(path string) string {
+	if content, err := ioutil.ReadFile(filepath.Join(path, "pom.xml")); err == nil {
+		pom := struct {
+			GroupID string `xml:"groupId"`
+			Parent  struct {
+				GroupID string `xml:"groupId"`
+			} `xml:"parent"`
+		}{}
+		if xml.Unmarshal(content, &pom) == nil {
+			if group := strings.TrimSpace(pom.GroupID); group != "" {
+				return group
+			}
+			return strings.TrimSpace(pom.Parent.GroupID)
+		}
+	}
+	for _, file := range []string{"build.gradle.kts", "build.gradle", "gradle.properties"} {
+		content, err := ioutil.ReadFile(filepath.Join(path, file))
+		if err != nil {
+			continue
+		}
+		if match := gradleGroupRegExp.FindSubmatch(content); match != nil {
+			return string(match[1]) + string(match[2])
+		}
+	}
+	return ""
+}
+
+// exists is true if any of the files are in a directory
+func exists(dir string, files ...string) bool {
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func NewKloner(srv provider.GitServer) kloners.Kloner {
+	return &Kloner{
+		RootKloner: simple.NewRootKloner(srv, "java", &Root),
+	}
+}
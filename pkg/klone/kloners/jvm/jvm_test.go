@@ -0,0 +1,164 @@
+package jvm
+
+import (
+	"github.com/kris-nova/klone/pkg/klone/kloners/klonerstest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newBuild will write the files of a build into a new directory
+func newBuild(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "klone-jvm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+const pom = `<?xml version="1.0" encoding="UTF-8"?>
+<project>
+  <modelVersion>4.0.0</modelVersion>
+  <parent>
+    <groupId>org.springframework.boot</groupId>
+    <artifactId>spring-boot-starter-parent</artifactId>
+  </parent>
+  <groupId>org.example</groupId>
+  <artifactId>service</artifactId>
+  <dependencies>
+    <dependency>
+      <groupId>com.google.guava</groupId>
+    </dependency>
+  </dependencies>
+</project>
+`
+
+func TestFindBuild(t *testing.T) {
+	cases := []struct {
+		files   map[string]string
+		tool    string
+		command string
+	}{
+		{map[string]string{"pom.xml": pom}, Maven, "mvn"},
+		{map[string]string{"pom.xml": pom, "mvnw": ""}, Maven, "./mvnw"},
+		{map[string]string{"build.gradle": ""}, Gradle, "gradle"},
+		{map[string]string{"build.gradle.kts": "", "gradlew": ""}, Gradle, "./gradlew"},
+		{map[string]string{"settings.gradle.kts": "", "pom.xml": pom, "gradlew": ""}, Gradle, "./gradlew"},
+	}
+	for _, c := range cases {
+		dir := newBuild(t, c.files)
+		defer os.RemoveAll(dir)
+		build := FindBuild(dir)
+		if build == nil || build.Tool != c.tool || build.Command != c.command {
+			t.Fatalf("Unexpected build for %v: %+v", c.files, build)
+		}
+	}
+	dir := newBuild(t, map[string]string{"Makefile": ""})
+	defer os.RemoveAll(dir)
+	if build := FindBuild(dir); build != nil {
+		t.Fatalf("Found a build without a build file: %+v", build)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	cases := []struct {
+		files    map[string]string
+		expected string
+	}{
+		{map[string]string{"pom.xml": pom}, "org.example"},
+		{map[string]string{"pom.xml": "<project><parent><groupId>org.parent</groupId></parent></project>"}, "org.parent"},
+		{map[string]string{"build.gradle.kts": "plugins {\n    kotlin(\"jvm\")\n}\n\ngroup = \"io.example\"\nversion = \"1.0\"\n"}, "io.example"},
+		{map[string]string{"build.gradle": "apply plugin: 'java'\ngroup 'org.groovy'\n"}, "org.groovy"},
+		{map[string]string{"build.gradle": "", "gradle.properties": "group=com.example\n"}, "com.example"},
+		{map[string]string{"build.gradle": ""}, ""},
+	}
+	for _, c := range cases {
+		dir := newBuild(t, c.files)
+		defer os.RemoveAll(dir)
+		if actual := Group(dir); actual != c.expected {
+			t.Fatalf("Expected [%s] for %v, got [%s]", c.expected, c.files, actual)
+		}
+	}
+}
+
+func TestRelocate(t *testing.T) {
+	defer func(root string) { Root = root }(Root)
+	Root = "/src/jvm"
+	k := NewKloner(&klonerstest.Server{}).(*Kloner)
+	repo := klonerstest.NewRepo("team", "service")
+	if actual := k.GetCloneDirectory(repo); actual != "/src/jvm/team/service" {
+		t.Fatalf("Unexpected clone directory: %s", actual)
+	}
+	dir := newBuild(t, map[string]string{"pom.xml": pom})
+	defer os.RemoveAll(dir)
+	if actual := k.Relocate(repo, dir); actual != "/src/jvm/org.example/service" {
+		t.Fatalf("Unexpected relocation: %s", actual)
+	}
+	ungrouped := newBuild(t, map[string]string{"build.gradle": ""})
+	defer os.RemoveAll(ungrouped)
+	if actual := k.Relocate(repo, ungrouped); actual != "" {
+		t.Fatalf("Relocated a build without a group: %s", actual)
+	}
+}
+
+func TestRelocateHostileGroup(t *testing.T) {
+	defer func(root string) { Root = root }(Root)
+	root, err := ioutil.TempDir("", "klone-jvm-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	Root = root
+	k := NewKloner(&klonerstest.Server{}).(*Kloner)
+	repo := klonerstest.NewRepo("team", "service")
+	for _, files := range []map[string]string{
+		{"pom.xml": "<project><groupId>../../../../.ssh</groupId></project>"},
+		{"pom.xml": "<project><groupId>/etc</groupId></project>"},
+		{"build.gradle": "group = '..'\n"},
+		{"build.gradle": "group = 'org..example'\n"},
+	} {
+		dir := newBuild(t, files)
+		defer os.RemoveAll(dir)
+		if actual := k.Relocate(repo, dir); actual != "" {
+			t.Fatalf("Relocated %v to [%s]", files, actual)
+		}
+	}
+
+	// A directory we did not klone is left alone
+	os.MkdirAll(filepath.Join(root, "org.example", "service"), 0755)
+	dir := newBuild(t, map[string]string{"pom.xml": pom})
+	defer os.RemoveAll(dir)
+	if actual := k.Relocate(repo, dir); actual != "" {
+		t.Fatalf("Relocated on top of a directory that is not a klone: %s", actual)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	dir := newBuild(t, map[string]string{"build.gradle": "", "gradlew": "#!/bin/sh\necho \"$*\" > warmed\n"})
+	defer os.RemoveAll(dir)
+	k := &Kloner{build: FindBuild(dir)}
+	err := k.Finish(dir)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "warmed")); err == nil {
+		t.Fatal("Warmed the cache without --jvm-warm-cache")
+	}
+	WarmCache = true
+	defer func() { WarmCache = false }()
+	err = k.Finish(dir)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "warmed"))
+	if err != nil || string(content) != "--quiet dependencies\n" {
+		t.Fatalf("Unexpected cache warming: %s %v", content, err)
+	}
+}
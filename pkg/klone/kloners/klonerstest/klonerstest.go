@@ -0,0 +1,28 @@
+// Package klonerstest has the fake repositories and git servers the kloner tests share
+package klonerstest
+
+import (
+	"github.com/kris-nova/klone/pkg/provider"
+)
+
+// Repo is a repository that only knows it's owner and name
+type Repo struct {
+	provider.Repo
+	owner, name string
+}
+
+// NewRepo will fake a repository for an owner and name
+func NewRepo(owner, name string) *Repo {
+	return &Repo{owner: owner, name: name}
+}
+
+func (r *Repo) Owner() string    { return r.owner }
+func (r *Repo) Name() string     { return r.name }
+func (r *Repo) Language() string { return "" }
+
+// Server is a git server that only knows it's name
+type Server struct {
+	provider.GitServer
+}
+
+func (s *Server) GetServerString() string { return "github.com" }
@@ -0,0 +1,114 @@
+package python
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klone/kloners/simple"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// VenvDirectory is the virtual environment we create in a klone
+const VenvDirectory = ".venv"
+
+var (
+	// Root is where we klone Python repositories, as $Root/$owner/$name
+	Root = "~/src/python"
+
+	// Venv will create a virtual environment once a Python repository is kloned
+	Venv = true
+
+	// Install will install the dependencies of a Python repository into it's virtual environment
+	Install = false
+
+	// interpreters are the interpreters we look for on $PATH, in order
+	interpreters = []string{"python3", "python"}
+)
+
+// Kloner clones a Python repository like any other repository, all we care
+// about is where it goes and the virtual environment it needs once it is there
+type Kloner struct {
+	*simple.RootKloner
+}
+
+// Finish will create a virtual environment in the klone, and install dependencies if we were asked to
+func (k *Kloner) Finish(path string) error {
+	if !Venv {
+		return nil
+	}
+	venv := filepath.Join(path, VenvDirectory)
+	if _, err := os.Stat(venv); err == nil {
+		local.Printf("Found virtual environment [%s]", venv)
+	} else {
+		interpreter, err := findInterpreter()
+		if err != nil {
+			local.RecoverableErrorf("unable to create virtual environment: %v", err)
+			return nil
+		}
+		local.Printf("Creating virtual environment [%s] with [%s]", venv, interpreter)
+		err = run(path, interpreter, "-m", "venv", VenvDirectory)
+		if err != nil {
+			return fmt.Errorf("unable to create virtual environment: %v", err)
+		}
+	}
+	if !Install {
+		return nil
+	}
+	python := filepath.Join(venv, "bin", "python")
+	for _, install := range Installs(path) {
+		local.Printf("Installing [%s]", strings.Join(install, " "))
+		args := append([]string{"-m", "pip", "install"}, install...)
+		err := run(path, python, args...)
+		if err != nil {
+			return fmt.Errorf("unable to install dependencies: %v", err)
+		}
+	}
+	return nil
+}
+
+// Installs are the pip install arguments for the dependencies we find in a repository.
+// Requirements come first, and a project is installed in editable mode.
+func Installs(path string) [][]string {
+	var installs [][]string
+	if exists(filepath.Join(path, "requirements.txt")) {
+		installs = append(installs, []string{"-r", "requirements.txt"})
+	}
+	if exists(filepath.Join(path, "pyproject.toml")) || exists(filepath.Join(path, "setup.py")) {
+		installs = append(installs, []string{"-e", "."})
+	}
+	return installs
+}
+
+// findInterpreter will find the first interpreter on $PATH
+func findInterpreter() (string, error) {
+	for _, interpreter := range interpreters {
+		path, err := exec.LookPath(interpreter)
+		if err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no python interpreter on $PATH %v", interpreters)
+}
+
+func run(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func NewKloner(srv provider.GitServer) kloners.Kloner {
+	return &Kloner{
+		RootKloner: simple.NewRootKloner(srv, "python", &Root),
+	}
+}
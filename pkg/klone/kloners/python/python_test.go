@@ -0,0 +1,90 @@
+package python
+
+import (
+	"github.com/kris-nova/klone/pkg/klone/kloners/klonerstest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetCloneDirectory(t *testing.T) {
+	defer func(root string) { Root = root }(Root)
+	Root = "/src/python"
+	k := NewKloner(&klonerstest.Server{})
+	if actual := k.GetCloneDirectory(klonerstest.NewRepo("team", "service")); actual != "/src/python/team/service" {
+		t.Fatalf("Unexpected clone directory: %s", actual)
+	}
+}
+
+func TestInstalls(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if installs := Installs(dir); len(installs) != 0 {
+		t.Fatalf("Unexpected installs: %v", installs)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "pyproject.toml"), []byte("[project]\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("requests\n"), 0644)
+	expected := [][]string{{"-r", "requirements.txt"}, {"-e", "."}}
+	if installs := Installs(dir); !reflect.DeepEqual(installs, expected) {
+		t.Fatalf("Unexpected installs: %v", installs)
+	}
+}
+
+// fakeInterpreter is a python that creates a virtual environment with a python
+// that logs how it was called
+const fakeInterpreter = `#!/bin/sh
+mkdir -p .venv/bin
+echo "venv $*" >> calls
+printf '#!/bin/sh\necho "pip $*" >> calls\n' > .venv/bin/python
+chmod +x .venv/bin/python
+`
+
+func TestFinish(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	interpreter := filepath.Join(dir, "fake-python")
+	ioutil.WriteFile(interpreter, []byte(fakeInterpreter), 0755)
+	defer func(i []string) { interpreters = i }(interpreters)
+	interpreters = []string{"klone-missing-python", interpreter}
+	path := filepath.Join(dir, "service")
+	os.MkdirAll(path, 0755)
+	ioutil.WriteFile(filepath.Join(path, "pyproject.toml"), []byte("[project]\n"), 0644)
+
+	k := &Kloner{}
+	err = k.Finish(path)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	Install = true
+	defer func() { Install = false }()
+	err = k.Finish(path)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	calls, err := ioutil.ReadFile(filepath.Join(path, "calls"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "venv -m venv .venv\npip -m pip install -e .\n"
+	if string(calls) != expected {
+		t.Fatalf("Unexpected calls:\n%s", calls)
+	}
+
+	interpreters = []string{"klone-missing-python"}
+	os.RemoveAll(filepath.Join(path, VenvDirectory))
+	err = k.Finish(path)
+	if err != nil {
+		t.Fatalf("Failed without an interpreter: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, VenvDirectory)); err == nil {
+		t.Fatal("Created a virtual environment without an interpreter")
+	}
+}
@@ -0,0 +1,156 @@
+package rust
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klone/kloners/simple"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Root is where we klone Rust repositories, as $Root/$owner/$name
+	Root = "~/src/rust"
+
+	// Fetch will run `cargo fetch` once a Rust repository is kloned
+	Fetch = false
+
+	fetchCommand = []string{"cargo", "fetch"}
+)
+
+// Kloner clones a Rust repository like any other repository, all we care
+// about is where it goes and what Cargo needs once it is there
+type Kloner struct {
+	*simple.RootKloner
+}
+
+// Finish will find the crates in a Cargo workspace, and fetch dependencies if we were asked to
+func (k *Kloner) Finish(path string) error {
+	content, err := ioutil.ReadFile(filepath.Join(path, "Cargo.toml"))
+	if err != nil {
+		return nil
+	}
+	if members, ok := WorkspaceMembers(content); ok {
+		crates := workspaceCrates(path, members)
+		local.Printf("Found Cargo workspace with %d crates %v", len(crates), crates)
+	}
+	if !Fetch {
+		return nil
+	}
+	local.Printf("Running [%s]", strings.Join(fetchCommand, " "))
+	cmd := exec.Command(fetchCommand[0], fetchCommand[1:]...)
+	cmd.Dir = path
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("unable to fetch crates: %v", err)
+	}
+	return nil
+}
+
+// WorkspaceMembers will find the members of the [workspace] in a Cargo.toml. The
+// members can be globs (E.G. crates/*). ok is false if there is no workspace.
+func WorkspaceMembers(cargoToml []byte) (members []string, ok bool) {
+	var table string
+	var list *bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(cargoToml))
+	for scanner.Scan() {
+		line := stripComment(scanner.Text())
+		if list != nil {
+			// A members array can span as many lines as it likes
+			list.WriteString(line)
+			if strings.Contains(line, "]") {
+				members = parseArray(list.String())
+				list = nil
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			table = strings.Trim(line, "[] ")
+			if table == "workspace" {
+				ok = true
+			}
+			continue
+		}
+		if table != "workspace" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "members" {
+			continue
+		}
+		list = bytes.NewBufferString(kv[1])
+		if strings.Contains(kv[1], "]") {
+			members = parseArray(list.String())
+			list = nil
+		}
+	}
+	return members, ok
+}
+
+// stripComment will remove a # comment that is not in a string
+func stripComment(line string) string {
+	quoted := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '#' && !quoted:
+			return strings.TrimSpace(line[:i])
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+// parseArray will parse a TOML array of strings, E.G. [ "a", "b/*" ]
+func parseArray(array string) []string {
+	var values []string
+	array = strings.TrimSpace(array)
+	array = strings.TrimSuffix(strings.TrimPrefix(array, "["), "]")
+	for _, value := range strings.Split(array, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, "'")
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// workspaceCrates will expand the member globs of a workspace into the crates we cloned
+func workspaceCrates(path string, members []string) []string {
+	var crates []string
+	for _, member := range members {
+		matches, err := filepath.Glob(filepath.Join(path, member, "Cargo.toml"))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			rel, err := filepath.Rel(path, filepath.Dir(match))
+			if err == nil {
+				crates = append(crates, filepath.ToSlash(rel))
+			}
+		}
+	}
+	return crates
+}
+
+func NewKloner(srv provider.GitServer) kloners.Kloner {
+	return &Kloner{
+		RootKloner: simple.NewRootKloner(srv, "rust", &Root),
+	}
+}
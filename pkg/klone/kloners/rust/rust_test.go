@@ -0,0 +1,87 @@
+package rust
+
+import (
+	"github.com/kris-nova/klone/pkg/klone/kloners/klonerstest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const workspace = `[workspace]
+# The crates we ship
+members = [
+    "cli",
+    "crates/*", # Everything else
+]
+exclude = ["scratch"]
+resolver = "2"
+
+[workspace.dependencies]
+members = "not a member"
+`
+
+func TestWorkspaceMembers(t *testing.T) {
+	members, ok := WorkspaceMembers([]byte(workspace))
+	if !ok || !reflect.DeepEqual(members, []string{"cli", "crates/*"}) {
+		t.Fatalf("Unexpected workspace members: %v %t", members, ok)
+	}
+	members, ok = WorkspaceMembers([]byte("[workspace]\nmembers = ['a', \"b\"]\n"))
+	if !ok || !reflect.DeepEqual(members, []string{"a", "b"}) {
+		t.Fatalf("Unexpected workspace members: %v %t", members, ok)
+	}
+	_, ok = WorkspaceMembers([]byte("[package]\nname = \"service\"\n\n[dependencies]\nserde = \"1\"\n"))
+	if ok {
+		t.Fatal("Found a workspace in a single crate")
+	}
+}
+
+func TestGetCloneDirectory(t *testing.T) {
+	defer func(root string) { Root = root }(Root)
+	Root = "/src/rust"
+	k := NewKloner(&klonerstest.Server{})
+	if actual := k.GetCloneDirectory(klonerstest.NewRepo("team", "service")); actual != "/src/rust/team/service" {
+		t.Fatalf("Unexpected clone directory: %s", actual)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, crate := range []string{"", "cli", "crates/parser", "crates/server", "scratch"} {
+		os.MkdirAll(filepath.Join(dir, crate), 0755)
+		ioutil.WriteFile(filepath.Join(dir, crate, "Cargo.toml"), []byte("[package]\n"), 0644)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(workspace), 0644)
+	if crates := workspaceCrates(dir, []string{"cli", "crates/*"}); !reflect.DeepEqual(crates, []string{"cli", "crates/parser", "crates/server"}) {
+		t.Fatalf("Unexpected workspace crates: %v", crates)
+	}
+
+	defer func(command []string) { fetchCommand = command }(fetchCommand)
+	fetchCommand = []string{"touch", "fetched"}
+	k := &Kloner{}
+	err = k.Finish(dir)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fetched")); err == nil {
+		t.Fatal("Fetched without --cargo-fetch")
+	}
+	Fetch = true
+	defer func() { Fetch = false }()
+	err = k.Finish(dir)
+	if err != nil {
+		t.Fatalf("Unable to finish: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fetched")); err != nil {
+		t.Fatal("Did not fetch with --cargo-fetch")
+	}
+	fetchCommand = []string{"false"}
+	if err = k.Finish(dir); err == nil {
+		t.Fatal("Able to finish with a failed fetch")
+	}
+}
@@ -5,16 +5,19 @@ import (
 	"github.com/kris-nova/klone/pkg/auth"
 	"github.com/kris-nova/klone/pkg/klone/kloners"
 	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/paths"
 	"github.com/kris-nova/klone/pkg/provider"
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
-	"os"
 	"strings"
 )
 
 type Kloner struct {
-	gitServer provider.GitServer
-	r         *git.Repository
+	gitServer    provider.GitServer
+	r            *git.Repository
+	cloneDir     string
+	noSubmodules bool
+	language     string
 }
 
 func (k *Kloner) Clone(repo provider.Repo) (string, error) {
@@ -23,7 +26,13 @@ func (k *Kloner) Clone(repo provider.Repo) (string, error) {
 		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 		//Progress:          os.Stdout,
 	}
+	if k.noSubmodules {
+		o.RecurseSubmodules = git.NoRecurseSubmodules
+	}
 	path := k.GetCloneDirectory(repo)
+	if k.cloneDir != "" {
+		path = k.cloneDir
+	}
 	local.Printf("Cloning into [%s]", path)
 	r, err := git.PlainClone(path, false, o)
 	if err != nil {
@@ -56,6 +65,21 @@ func (k *Kloner) Clone(repo provider.Repo) (string, error) {
 	return path, nil
 }
 
+// SetCloneDirectory will override the directory Clone() clones into
+func (k *Kloner) SetCloneDirectory(path string) {
+	k.cloneDir = path
+}
+
+// SetRecurseSubmodules will decide if Clone() clones submodules (it does by default)
+func (k *Kloner) SetRecurseSubmodules(recurse bool) {
+	k.noSubmodules = !recurse
+}
+
+// Checkout will check out a branch, tag, commit, or pull request from a remote
+func (k *Kloner) Checkout(remote string, selector *kloners.Selector) error {
+	return kloners.Checkout(k.r, remote, selector)
+}
+
 func (k *Kloner) DeleteRemote(name string) error {
 	err := k.r.DeleteRemote(name)
 	if err != nil {
@@ -80,7 +104,7 @@ func (k *Kloner) AddRemote(name, url string) error {
 		}
 	}
 	local.Printf("Fetching remote [%s]", url)
-	pk, err := auth.GetTransport()
+	pk, err := auth.GetTransportForUrl(url)
 	if err != nil {
 		return err
 	}
@@ -101,7 +125,11 @@ func (k *Kloner) AddRemote(name, url string) error {
 }
 
 func (k *Kloner) Pull(name string) error {
-	pk, err := auth.GetTransport()
+	remote, err := k.r.Remote(name)
+	if err != nil {
+		return err
+	}
+	pk, err := auth.GetTransportForUrl(remote.Config().URL)
 	if err != nil {
 		return err
 	}
@@ -122,17 +150,13 @@ func (k *Kloner) Pull(name string) error {
 	return nil
 }
 
+// GetCloneDirectory is the path template we have for a repository, or $KLONE_WORKSPACE (or
+// the current directory) if we have none
 func (k *Kloner) GetCloneDirectory(repo provider.Repo) string {
-	ws := os.Getenv("KLONE_WORKSPACE")
-	if ws != "" {
-		return fmt.Sprintf("%s/%s", ws, repo.Name())
+	if path, ok := paths.Configured(k.gitServer, repo, k.language); ok {
+		return path
 	}
-	wd, err := os.Getwd()
-	if err != nil {
-		local.RecoverableErrorf("unable to determine current directory")
-		return fmt.Sprintf("%s/%s", local.Home(), repo.Name())
-	}
-	return fmt.Sprintf("%s/%s", wd, repo.Name())
+	return fmt.Sprintf("%s/%s", paths.Workspace(), repo.Name())
 }
 
 func NewKloner(srv provider.GitServer) kloners.Kloner {
@@ -140,3 +164,64 @@ func NewKloner(srv provider.GitServer) kloners.Kloner {
 		gitServer: srv,
 	}
 }
+
+// NewLanguageKloner is a simple kloner for a language, that will use the path template for the language
+func NewLanguageKloner(srv provider.GitServer, language string) kloners.Kloner {
+	return &Kloner{
+		gitServer: srv,
+		language:  language,
+	}
+}
+
+// RootKloner clones a repository for a language into $root/$owner/$name, unless we have a path
+// template for the language. The languages embed it, and only add what they need once a
+// repository is cloned.
+type RootKloner struct {
+	kloners.Kloner
+	gitServer provider.GitServer
+	language  string
+	root      *string
+	cloneDir  string
+}
+
+func (k *RootKloner) Clone(repo provider.Repo) (string, error) {
+	if k.cloneDir == "" {
+		k.Kloner.SetCloneDirectory(k.GetCloneDirectory(repo))
+	}
+	return k.Kloner.Clone(repo)
+}
+
+// SetCloneDirectory will override the directory Clone() clones into
+func (k *RootKloner) SetCloneDirectory(path string) {
+	k.cloneDir = path
+	k.Kloner.SetCloneDirectory(path)
+}
+
+// GetCloneDirectory is the path template we have for a repository, or $root/$owner/$name
+func (k *RootKloner) GetCloneDirectory(repo provider.Repo) string {
+	if path, ok := k.Configured(repo); ok {
+		return path
+	}
+	return k.Path(repo.Owner(), repo.Name())
+}
+
+// Configured is the path template we have for a repository in this language, if we have one
+func (k *RootKloner) Configured(repo provider.Repo) (string, bool) {
+	return paths.Configured(k.gitServer, repo, k.language)
+}
+
+// Path is where a repository goes under the root, E.G. $root/$owner/$name
+func (k *RootKloner) Path(owner, name string) string {
+	return fmt.Sprintf("%s/%s/%s", local.Expand(*k.root), owner, name)
+}
+
+// NewRootKloner is a kloner for a language that clones into a root directory. We read the
+// root when we clone, so it can be a flag.
+func NewRootKloner(srv provider.GitServer, language string, root *string) *RootKloner {
+	return &RootKloner{
+		Kloner:    NewLanguageKloner(srv, language),
+		gitServer: srv,
+		language:  language,
+		root:      root,
+	}
+}
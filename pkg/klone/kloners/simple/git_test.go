@@ -0,0 +1,120 @@
+package simple
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klone/kloners/klonerstest"
+	"github.com/kris-nova/klone/pkg/provider/plaingit"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// runGit will run a git command for a test fixture and return it's output
+func runGit(t *testing.T, args ...string) string {
+	cmd := exec.Command("git", args...)
+	// A fixed date gives us the same hashes on every run. With these hashes upload-pack ACKs
+	// more than one have when we fetch a pull request (see patches/go-git-duplicate-ack.patch)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=klone", "GIT_AUTHOR_EMAIL=klone@example.com",
+		"GIT_COMMITTER_NAME=klone", "GIT_COMMITTER_EMAIL=klone@example.com",
+		"GIT_AUTHOR_DATE=2017-06-02T00:00:00Z", "GIT_COMMITTER_DATE=2017-06-02T00:00:00Z")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unable to run git %v: %v %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCheckout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-simple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	work := fmt.Sprintf("%s/work", dir)
+	bare := fmt.Sprintf("%s/srv/team/service.git", dir)
+	runGit(t, "init", "-q", work)
+	runGit(t, "-C", work, "commit", "-q", "--allow-empty", "-m", "init")
+	runGit(t, "-C", work, "tag", "-a", "v1", "-m", "v1")
+	runGit(t, "-C", work, "checkout", "-q", "-b", "release-1")
+	runGit(t, "-C", work, "commit", "-q", "--allow-empty", "-m", "release")
+	release := runGit(t, "-C", work, "rev-parse", "HEAD")
+	runGit(t, "-C", work, "checkout", "-q", "-b", "contributor", "master")
+	runGit(t, "-C", work, "commit", "-q", "--allow-empty", "-m", "pull request")
+	pr := runGit(t, "-C", work, "rev-parse", "HEAD")
+	runGit(t, "-C", work, "checkout", "-q", "master")
+	tag := runGit(t, "-C", work, "rev-parse", "v1^{commit}")
+	runGit(t, "clone", "-q", "--bare", work, bare)
+	runGit(t, "-C", bare, "branch", "-q", "-D", "contributor")
+	runGit(t, "-C", bare, "update-ref", "refs/pull/7/head", pr)
+
+	s, err := (&plaingit.KloneProvider{Prefix: fmt.Sprintf("file://%s/srv/", dir)}).NewGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := s.GetRepoByOwner("team", "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("KLONE_WORKSPACE", dir)
+	defer os.Unsetenv("KLONE_WORKSPACE")
+	k := NewKloner(s)
+	path, err := k.Clone(repo)
+	if err != nil {
+		t.Fatalf("Unable to clone: %v", err)
+	}
+	err = k.DeleteRemote("origin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = k.AddRemote("upstream", repo.GitRemoteUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		selector *kloners.Selector
+		branch   string
+		hash     string
+	}{
+		{&kloners.Selector{Ref: "release-1"}, "release-1", release},
+		{&kloners.Selector{PullRequest: 7, PullRequestRef: "refs/pull/7/head"}, "pr-7", pr},
+		{&kloners.Selector{Ref: "v1"}, "HEAD", tag},
+		{&kloners.Selector{Ref: release[:10]}, "HEAD", release},
+	}
+	for _, c := range cases {
+		err = k.Checkout("upstream", c.selector)
+		if err != nil {
+			t.Fatalf("Unable to checkout [%s]: %v", c.selector, err)
+		}
+		branch := runGit(t, "-C", path, "rev-parse", "--abbrev-ref", "HEAD")
+		hash := runGit(t, "-C", path, "rev-parse", "HEAD")
+		if branch != c.branch || hash != c.hash {
+			t.Fatalf("Unexpected checkout of [%s]: [%s] at [%s]", c.selector, branch, hash)
+		}
+	}
+	if merge := runGit(t, "-C", path, "config", "branch.pr-7.merge"); merge != "refs/pull/7/head" {
+		t.Fatalf("Unexpected tracking ref for pull request: %s", merge)
+	}
+	if err = k.Checkout("upstream", &kloners.Selector{Ref: "missing"}); err == nil {
+		t.Fatal("Able to checkout missing ref")
+	}
+}
+
+func TestRootKloner(t *testing.T) {
+	root := "/src/rust"
+	k := NewRootKloner(&klonerstest.Server{}, "rust", &root)
+	repo := klonerstest.NewRepo("team", "service")
+	if actual := k.GetCloneDirectory(repo); actual != "/src/rust/team/service" {
+		t.Fatalf("Unexpected clone directory: %s", actual)
+	}
+	root = "/elsewhere"
+	if actual := k.GetCloneDirectory(repo); actual != "/elsewhere/team/service" {
+		t.Fatalf("Unexpected clone directory once the root changed: %s", actual)
+	}
+	if _, ok := k.Configured(repo); ok {
+		t.Fatal("Found a path template without one")
+	}
+}
@@ -1,8 +1,12 @@
 package klone
 
 import (
+	"github.com/kris-nova/klone/pkg/klone/kloners"
 	"github.com/kris-nova/klone/pkg/local"
 	"github.com/kris-nova/klone/pkg/provider"
+	"github.com/kris-nova/klone/pkg/provider/plaingit"
+	"github.com/kris-nova/klone/pkg/vanity"
+	"strconv"
 	"strings"
 )
 
@@ -12,15 +16,59 @@ type QueryInformation struct {
 	repo          provider.Repo
 	repoName      string
 	repoOwner     string
+	selector      *kloners.Selector
+	importPath    string // The vanity import path we resolved the repository from
 }
 
 // ParseQuery will take an arbitrary string and attempt to reason
 // about what the user would like to happen for a klone. ParseQuery
 // will return QueryInformation which is the information we could
-// abstract out of their query and a few hits to the Git server API
+// abstract out of their query and a few hits to the Git server API.
+// A query can end with a @ref (branch, tag, or commit) or #N (pull request)
 func ParseQuery(query string) (bool, *QueryInformation) {
+	query, selector := splitSelector(query)
+	found, q := parseRepoQuery(query)
+	if found {
+		q.selector = selector
+	}
+	return found, q
+}
+
+// splitSelector will split a @ref or #N selector off the end of a query
+// E.G. kubernetes/kubernetes@release-1.28 or org/repo#4512
+func splitSelector(query string) (string, *kloners.Selector) {
+	if i := strings.LastIndex(query, "#"); i > 0 {
+		n, err := strconv.Atoi(query[i+1:])
+		if err == nil && n > 0 {
+			return query[:i], &kloners.Selector{PullRequest: n}
+		}
+	}
+	if i := strings.LastIndex(query, "@"); i > 0 && i < len(query)-1 {
+		// The @ in user@host:path and https://user@host/path is not a selector
+		if plaingit.IsURL(query) {
+			if _, _, _, err := plaingit.SplitURL(query[:i]); err != nil {
+				return query, nil
+			}
+		}
+		return query[:i], &kloners.Selector{Ref: query[i+1:]}
+	}
+	return query, nil
+}
+
+// parseRepoQuery will find the repository for a query without a selector
+func parseRepoQuery(query string) (bool, *QueryInformation) {
 	var q *QueryInformation
 	found := false
+	// Check for clone and browser urls
+	if plaingit.IsURL(query) {
+		return tryURL(query)
+	}
+	// Check for vanity import paths (E.G. golang.org/x/tools) on servers we have no provider for
+	if vanity.IsImportPath(query) && serverProvider(strings.Split(query, "/")[0]) == nil {
+		if b, pqi := tryImportPath(query); b {
+			return true, pqi
+		}
+	}
 	// Check for /'s
 	if strings.Contains(query, "/") {
 		slashSplit := strings.Split(query, "/")
@@ -43,6 +91,14 @@ func ParseQuery(query string) (bool, *QueryInformation) {
 				q = pqi
 				found = true
 			}
+		} else if lenSlashSplit > 3 {
+			// Everything between the server and the name is the owner (E.G. git.kernel.org/pub/scm/git/git)
+			owner := strings.Join(slashSplit[1:lenSlashSplit-1], "/")
+			b, pqi := tryServerOwnerName(slashSplit[0], owner, slashSplit[lenSlashSplit-1])
+			if b {
+				q = pqi
+				found = true
+			}
 		}
 	} else {
 		b, pqi := tryUnknown(query)
@@ -61,15 +117,16 @@ func ParseQuery(query string) (bool, *QueryInformation) {
 // make many assumptions, to see if we can't happen upon the repo
 // the user is talking about
 func tryUnknown(unknown string) (bool, *QueryInformation) {
-
-	// First pattern is $server/$you/$repo
-	ok, q := tryServerName("github.com", unknown)
-	if ok {
-		return true, q
+	// First pattern is $server/$you/$repo for every server that will guess names
+	for _, server := range provider.GuessNameServers() {
+		ok, sq := tryServerName(server, unknown)
+		if ok {
+			return true, sq
+		}
 	}
 
 	// Second pattern is $name/$name like git/git or kubernetes/kubernetes
-	ok, q = tryOwnerName(unknown, unknown)
+	ok, q := tryOwnerName(unknown, unknown)
 	if ok {
 		return true, q
 	}
@@ -79,17 +136,15 @@ func tryUnknown(unknown string) (bool, *QueryInformation) {
 
 // tryOwnerRepo is the 2nd most granular of the the 3 try functions. This
 // will try to verify a repo based on it's owner, and name. This will try
-// to "guess" servers in the order they are defined in the function.
-// Note: All new server implementations need to be defined here
+// to "guess" servers in the order of their priority in the provider registry.
 func tryOwnerName(owner, name string) (bool, *QueryInformation) {
-	// Try GitHub first
-	ok, q := tryServerOwnerName("github.com", owner, name)
-	if ok {
-		return true, q
+	q := &QueryInformation{}
+	for _, server := range provider.GuessOwnerNameServers() {
+		ok, sq := tryServerOwnerName(server, owner, name)
+		if ok {
+			return true, sq
+		}
 	}
-
-	// Todo (@kris-nova) Define other implementations here
-
 	return false, q
 }
 
@@ -99,61 +154,103 @@ func tryOwnerName(owner, name string) (bool, *QueryInformation) {
 // this is a fairly common occurrence.
 func tryServerName(server, name string) (bool, *QueryInformation) {
 	q := &QueryInformation{}
-	switch server {
-	case "github.com":
-		ghp := NewGithubProvider()
-		s, err := ghp.NewGitServer()
-		if err != nil {
-			local.PrintExclaimf("Unable to create new git server: %v", err)
-			return false, q
-		}
-		repo, err := s.GetRepo(name)
-		if err != nil {
-			return false, q
-		}
-		if repo != nil {
-			q.kloneProvider = ghp
-			q.repo = repo
-			q.repoOwner = repo.Owner()
-			q.repoName = name
-			q.gitServer = s
-			return true, q
-		}
-	default:
+	kp := serverProvider(server)
+	if kp == nil {
+		return false, q
+	}
+	s, err := kp.NewGitServer()
+	if err != nil {
+		local.PrintExclaimf("Unable to create new git server: %v", err)
+		return false, q
+	}
+	repo, err := s.GetRepo(name)
+	if err != nil {
 		return false, q
 	}
+	if repo != nil {
+		q.kloneProvider = kp
+		q.repo = repo
+		q.repoOwner = repo.Owner()
+		q.repoName = name
+		q.gitServer = s
+		return true, q
+	}
 	return false, q
 }
 
 // tryServerOwnerRepo is the most granular of the the 3 try functions. This
 // will try to verify a repo based on it's server, owner, and name. Usually
 // the other functions will do a guess and check with this function.
-// Note: All new server implementations need to be defined here
+// Servers are looked up in the provider registry.
 func tryServerOwnerName(server, owner, name string) (bool, *QueryInformation) {
+	kp := serverProvider(server)
+	if kp == nil {
+		return false, &QueryInformation{}
+	}
+	return tryProviderOwnerName(kp, owner, name)
+}
+
+// tryURL will klone a pasted clone or browser url. If we have a provider registered
+// for the host we use it, otherwise we klone the exact remote with plain git.
+func tryURL(query string) (bool, *QueryInformation) {
+	prefix, server, owner, name, err := splitURL(query)
+	if err != nil {
+		local.PrintExclaimf("Unable to parse url: %v", err)
+		return false, &QueryInformation{}
+	}
+	if serverProvider(server) != nil {
+		return tryServerOwnerName(server, owner, name)
+	}
+	return tryProviderOwnerName(NewPlainGitProvider(prefix), owner, name)
+}
+
+// tryImportPath will fetch the go-get meta tags of an import path, and klone
+// the repository they point to
+func tryImportPath(importPath string) (bool, *QueryInformation) {
+	imp, err := vanity.Resolve(importPath)
+	if err != nil {
+		local.Printf("Unable to resolve import path: %v", err)
+		return false, &QueryInformation{}
+	}
+	local.Printf("Found import path [%s] in [%s]", imp.Prefix, imp.RepoURL)
+	b, q := tryURL(imp.RepoURL)
+	if b {
+		q.importPath = imp.Prefix
+	}
+	return b, q
+}
+
+// splitURL will split a pasted url into a remote url prefix, server, owner, and
+// name. This never talks to a git server.
+// E.G. https://github.com/org/repo/tree/main/pkg is [https://github.com/] [github.com] [org] [repo]
+func splitURL(query string) (prefix, server, owner, name string, err error) {
+	prefix, owner, name, err = plaingit.SplitURL(query)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	return prefix, plaingit.PrefixHost(prefix), owner, name, nil
+}
+
+// tryProviderOwnerName will verify a repo based on it's owner and name with
+// a KloneProvider we have already decided on.
+func tryProviderOwnerName(kp provider.KloneProvider, owner, name string) (bool, *QueryInformation) {
 	q := &QueryInformation{}
-	switch server {
-	case "github.com":
-		ghp := NewGithubProvider()
-		s, err := ghp.NewGitServer()
-		if err != nil {
-			local.PrintExclaimf("Unable to create new git server: %v", err)
-			return false, q
-		}
-		repo, err := s.GetRepoByOwner(owner, name)
-		if err != nil {
-			return false, q
-		}
-		if repo != nil {
-			q.kloneProvider = ghp
-			q.repo = repo
-			q.repoOwner = owner
-			q.repoName = name
-			q.gitServer = s
-			return true, q
-		}
-	default:
+	s, err := kp.NewGitServer()
+	if err != nil {
+		local.PrintExclaimf("Unable to create new git server: %v", err)
 		return false, q
 	}
-
+	repo, err := s.GetRepoByOwner(owner, name)
+	if err != nil {
+		return false, q
+	}
+	if repo != nil {
+		q.kloneProvider = kp
+		q.repo = repo
+		q.repoOwner = owner
+		q.repoName = name
+		q.gitServer = s
+		return true, q
+	}
 	return false, q
 }
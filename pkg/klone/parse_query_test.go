@@ -1,9 +1,18 @@
 package klone
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"github.com/kris-nova/klone/pkg/provider"
+	"github.com/kris-nova/klone/pkg/vanity"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -16,8 +25,12 @@ import (
 var GitServer provider.GitServer
 
 // TestMain will setup the e2e testing suite by creating a new (and concurrent) connection
-// to the Git provider
+// to the Git provider. Running with -short will skip anything that needs the network.
 func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		os.Exit(m.Run())
+	}
 	provider := NewGithubProvider()
 	gitServer, err := provider.NewGitServer()
 	if err != nil {
@@ -50,6 +63,7 @@ func TestMain(m *testing.M) {
 // TestParseQueryTripleGithub will test a well known org repo that is not
 // owned by the user
 func TestWellKnownOrgRepo(t *testing.T) {
+	skipShort(t)
 	b, q := ParseQuery("Nivenly/klone-e2e-go")
 	if !b {
 		t.Fatal("Unable to parse well known org query")
@@ -68,6 +82,7 @@ func TestWellKnownOrgRepo(t *testing.T) {
 // TestParseQuerySingleGithub will test that the single query "klone-e2e-query" will return
 // the proper QueryInformation
 func TestParseQuerySingleGithub(t *testing.T) {
+	skipShort(t)
 	b, q := ParseQuery("klone-e2e-query")
 	if !b {
 		t.Fatal("Unable to parse single query")
@@ -86,6 +101,7 @@ func TestParseQuerySingleGithub(t *testing.T) {
 // TestParseQueryDoubleGithub will test that the single query "klone-e2e-query" will return
 // the proper QueryInformation
 func TestParseQueryDoubleGithub(t *testing.T) {
+	skipShort(t)
 	b, q := ParseQuery("kris-nova/klone-e2e-query")
 	if !b {
 		t.Fatal("Unable to parse double query")
@@ -104,6 +120,7 @@ func TestParseQueryDoubleGithub(t *testing.T) {
 // TestParseQueryTripleGithub will test that the single query "klone-e2e-query" will return
 // the proper QueryInformation
 func TestParseQueryTripleGithub(t *testing.T) {
+	skipShort(t)
 	b, q := ParseQuery("github.com/kris-nova/klone-e2e-query")
 	if !b {
 		t.Fatal("Unable to parse triple query")
@@ -118,3 +135,164 @@ func TestParseQueryTripleGithub(t *testing.T) {
 		t.Fatal("Unable to match owner for triple query")
 	}
 }
+
+// skipShort will skip tests that need a GitHub connection when running with -short
+func skipShort(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping GitHub test in short mode")
+	}
+}
+
+// TestSplitURL will test that pasted clone and browser urls are split without the network
+func TestSplitURL(t *testing.T) {
+	cases := []struct {
+		query, prefix, server, owner, name string
+	}{
+		{"https://github.com/kubernetes/kubernetes", "https://github.com/", "github.com", "kubernetes", "kubernetes"},
+		{"https://github.com/kubernetes/kubernetes.git", "https://github.com/", "github.com", "kubernetes", "kubernetes"},
+		{"git@github.com:kubernetes/kubernetes.git", "git@github.com:", "github.com", "kubernetes", "kubernetes"},
+		{"ssh://git@github.com/kubernetes/kubernetes.git", "ssh://git@github.com/", "github.com", "kubernetes", "kubernetes"},
+		{"git://github.com/kubernetes/kubernetes.git", "git://github.com/", "github.com", "kubernetes", "kubernetes"},
+		{"https://github.com/org/repo/tree/main/pkg", "https://github.com/", "github.com", "org", "repo"},
+		{"https://github.com/org/repo/blob/main/README.md#L10", "https://github.com/", "github.com", "org", "repo"},
+		{"https://github.com/org/repo/pull/42", "https://github.com/", "github.com", "org", "repo"},
+		{"https://github.com/org/repo/pull/42/files", "https://github.com/", "github.com", "org", "repo"},
+		{"https://github.com/org/repo/", "https://github.com/", "github.com", "org", "repo"},
+		{"https://github.com/org/repo?tab=readme", "https://github.com/", "github.com", "org", "repo"},
+		{"https://gitlab.com/group/subgroup/repo/-/tree/main", "https://gitlab.com/", "gitlab.com", "group/subgroup", "repo"},
+		{"https://gitlab.com/group/subgroup/repo/-/merge_requests/7", "https://gitlab.com/", "gitlab.com", "group/subgroup", "repo"},
+		{"https://bitbucket.org/workspace/repo/src/master/", "https://bitbucket.org/", "bitbucket.org", "workspace", "repo"},
+		{"ssh://git@buildbox:2222/srv/git/service.git", "ssh://git@buildbox:2222/", "buildbox", "srv/git", "service"},
+		{"https://git.kernel.org/pub/scm/git/git.git/tree/Documentation", "https://git.kernel.org/", "git.kernel.org", "pub/scm/git", "git"},
+		{"file:///srv/git/service.git", "file:///", "localhost", "srv/git", "service"},
+		{"git@buildbox:home/src/service.git", "git@buildbox:", "buildbox", "home/src", "service"},
+	}
+	for _, c := range cases {
+		prefix, server, owner, name, err := splitURL(c.query)
+		if err != nil {
+			t.Errorf("Unable to split [%s]: %v", c.query, err)
+			continue
+		}
+		if prefix != c.prefix || server != c.server || owner != c.owner || name != c.name {
+			t.Errorf("Unexpected split of [%s]: [%s] [%s] [%s] [%s]", c.query, prefix, server, owner, name)
+		}
+	}
+	for _, query := range []string{"kubernetes", "kris-nova/klone", "github.com/kris-nova/klone", "https://github.com/"} {
+		if _, _, _, _, err := splitURL(query); err == nil {
+			t.Errorf("Able to split [%s] as a url", query)
+		}
+	}
+}
+
+// TestParseQueryFileURL will test a file:// url is kloned with plain git, which needs no network
+func TestParseQueryFileURL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-parse-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bare := fmt.Sprintf("%s/team/service.git", dir)
+	out, err := exec.Command("git", "init", "-q", "--bare", bare).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unable to create bare repository: %v %s", err, out)
+	}
+	b, q := ParseQuery(fmt.Sprintf("file://%s", bare))
+	if !b {
+		t.Fatal("Unable to parse file url query")
+	}
+	if q.gitServer.GetServerString() != "localhost" {
+		t.Fatalf("Unexpected server for file url query: %s", q.gitServer.GetServerString())
+	}
+	if q.repoName != "service" || q.repoOwner != strings.TrimPrefix(fmt.Sprintf("%s/team", dir), "/") {
+		t.Fatalf("Unexpected repo for file url query: %s/%s", q.repoOwner, q.repoName)
+	}
+}
+
+// TestParseQueryImportPath will test a vanity import path is resolved with it's go-get
+// meta tags, and a Go klone is placed by the import path
+func TestParseQueryImportPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-parse-query")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bare := fmt.Sprintf("%s/team/service.git", dir)
+	out, err := exec.Command("git", "init", "-q", "--bare", bare).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Unable to create bare repository: %v %s", err, out)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.com" || r.URL.Query().Get("go-get") != "1" {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<html><head><meta name="go-import" content="example.com/service git file://%s"></head></html>`, bare)
+	}))
+	defer ts.Close()
+	scheme, client := vanity.Scheme, vanity.Client
+	defer func() { vanity.Scheme, vanity.Client = scheme, client }()
+	vanity.Scheme = "http"
+	vanity.Client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, ts.Listener.Addr().String())
+		},
+	}}
+
+	b, q := ParseQuery("example.com/service/pkg/api")
+	if !b {
+		t.Fatal("Unable to parse import path query")
+	}
+	if q.importPath != "example.com/service" || q.repoName != "service" || q.gitServer.GetServerString() != "localhost" {
+		t.Fatalf("Unexpected repo for import path query: %s %s/%s", q.importPath, q.repoOwner, q.repoName)
+	}
+	if b, _ := ParseQuery("example.com/missing"); b {
+		t.Fatal("Able to parse an import path with no meta tags")
+	}
+
+	gopath := os.Getenv("GOPATH")
+	os.Setenv("GOPATH", filepath.Join(dir, "go"))
+	defer os.Setenv("GOPATH", gopath)
+	ForceKloner = "gogit"
+	defer func() { ForceKloner = "" }()
+	plan, err := Plan("example.com/service")
+	if err != nil {
+		t.Fatalf("Unable to plan import path query: %v", err)
+	}
+	if expected := filepath.Join(dir, "go", "src", "example.com", "service"); plan.Path != expected {
+		t.Fatalf("Expected klone in [%s], got [%s]", expected, plan.Path)
+	}
+}
+
+// TestSplitSelector will test @ref and #N selectors are split off of a query
+func TestSplitSelector(t *testing.T) {
+	cases := []struct {
+		query, rest, ref string
+		pr               int
+	}{
+		{"kubernetes/kubernetes@release-1.28", "kubernetes/kubernetes", "release-1.28", 0},
+		{"org/repo#4512", "org/repo", "", 4512},
+		{"org/repo@0a1b2c3d", "org/repo", "0a1b2c3d", 0},
+		{"klone@feature/branch", "klone", "feature/branch", 0},
+		{"git@github.com:org/repo.git@v1.0.0", "git@github.com:org/repo.git", "v1.0.0", 0},
+		{"https://github.com/org/repo#12", "https://github.com/org/repo", "", 12},
+		{"git@github.com:org/repo.git", "git@github.com:org/repo.git", "", 0},
+		{"https://user@example.com/org/repo", "https://user@example.com/org/repo", "", 0},
+		{"https://github.com/org/repo/blob/main/README.md#L10", "https://github.com/org/repo/blob/main/README.md#L10", "", 0},
+		{"org/repo", "org/repo", "", 0},
+	}
+	for _, c := range cases {
+		rest, selector := splitSelector(c.query)
+		if rest != c.rest {
+			t.Errorf("Unexpected query for [%s]: %s", c.query, rest)
+		}
+		if c.ref == "" && c.pr == 0 {
+			if selector != nil {
+				t.Errorf("Unexpected selector for [%s]: %s", c.query, selector)
+			}
+			continue
+		}
+		if selector == nil || selector.Ref != c.ref || selector.PullRequest != c.pr {
+			t.Errorf("Unexpected selector for [%s]: %v", c.query, selector)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// plan.go is what a klone would do, for a --dry-run
+
+package klone
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/provider"
+)
+
+// KlonePlan is everything a klone would do, without doing any of it
+type KlonePlan struct {
+	Query      string         `json:"query"`
+	Server     string         `json:"server"`
+	Repository string         `json:"repository"`
+	Style      string         `json:"style"`
+	ForkTarget string         `json:"forkTarget,omitempty"`
+	Kloner     string         `json:"kloner"`
+	CloneURL   string         `json:"cloneUrl"`
+	Path       string         `json:"path"`
+	Remotes    []*PlanRemote  `json:"remotes"`
+	Checkout   *PlanCheckout  `json:"checkout,omitempty"`
+	Klonefile  *PlanKlonefile `json:"klonefile,omitempty"`
+}
+
+// PlanRemote is a remote a klone would register. Fork is true if the url
+// belongs to a fork the klone would create, and is not known yet.
+type PlanRemote struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Fork bool   `json:"fork,omitempty"`
+}
+
+// PlanCheckout is the branch, tag, commit, or pull request a klone would check out
+type PlanCheckout struct {
+	Ref            string `json:"ref,omitempty"`
+	PullRequest    int    `json:"pullRequest,omitempty"`
+	PullRequestRef string `json:"pullRequestRef,omitempty"`
+	Remote         string `json:"remote"`
+}
+
+// PlanKlonefile is what a klone would do because of the repository's Klonefile
+type PlanKlonefile struct {
+	Submodules string   `json:"submodules"`
+	Container  string   `json:"container,omitempty"`
+	Commands   []string `json:"commands,omitempty"`
+	Trusted    bool     `json:"trusted"`
+}
+
+// plan will describe a klone following the same patterns as klone_patterns.go
+func (k *Kloneable) plan(query string) *KlonePlan {
+	p := &KlonePlan{
+		Query:      query,
+		Server:     k.gitServer.GetServerString(),
+		Repository: fullName(k.repo),
+		Style:      k.style.String(),
+		Kloner:     k.klonerName,
+	}
+	switch k.style {
+	case StyleOwner:
+		p.addRemote(k.remoteName("origin"), k.repo.GitRemoteUrl())
+	case StyleAlreadyForked:
+		p.addRemote(k.remoteName("origin"), k.repo.GitRemoteUrl())
+		p.addRemote(k.remoteName("upstream"), k.repo.ForkedFrom().GitRemoteUrl())
+	case StyleNeedsFork, StyleTryingFork:
+		p.ForkTarget = fmt.Sprintf("%s/%s", k.gitServer.OwnerName(), k.repo.Name())
+		p.Remotes = append(p.Remotes, &PlanRemote{Name: k.remoteName("origin"), Fork: true})
+		p.addRemote(k.remoteName("upstream"), k.repo.GitRemoteUrl())
+	case StyleNoFork:
+		p.addRemote(k.remoteName(NoForkRemote), k.repo.GitRemoteUrl())
+	}
+	cloned := k.clonedRepo()
+	p.CloneURL = cloned.GitCloneUrl()
+	p.Path = k.cloneDirectory(cloned)
+	if k.selector != nil {
+		p.Checkout = &PlanCheckout{
+			Ref:            k.selector.Ref,
+			PullRequest:    k.selector.PullRequest,
+			PullRequestRef: k.selector.PullRequestRef,
+			Remote:         k.selectorRemote(),
+		}
+	}
+	if k.klonefile != nil {
+		p.Klonefile = &PlanKlonefile{
+			Submodules: klonefile.SubmodulesRecursive,
+			Container:  k.klonefile.Container,
+			Commands:   k.klonefile.Commands,
+			Trusted:    TrustKlonefile,
+		}
+		if !k.klonefile.RecurseSubmodules() {
+			p.Klonefile.Submodules = klonefile.SubmodulesNone
+		}
+	}
+	return p
+}
+
+func (p *KlonePlan) addRemote(name, url string) {
+	p.Remotes = append(p.Remotes, &PlanRemote{Name: name, URL: url})
+}
+
+// String is the plan as we print it for a human
+func (p *KlonePlan) String() string {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "Query:       %s\n", p.Query)
+	fmt.Fprintf(b, "Server:      %s\n", p.Server)
+	fmt.Fprintf(b, "Repository:  %s\n", p.Repository)
+	fmt.Fprintf(b, "Style:       %s\n", p.Style)
+	if p.ForkTarget != "" {
+		fmt.Fprintf(b, "Fork:        %s will be created from %s\n", p.ForkTarget, p.Repository)
+	}
+	fmt.Fprintf(b, "Kloner:      %s\n", p.Kloner)
+	fmt.Fprintf(b, "Clone URL:   %s\n", p.CloneURL)
+	fmt.Fprintf(b, "Path:        %s\n", p.Path)
+	for _, r := range p.Remotes {
+		url := r.URL
+		if r.Fork {
+			url = fmt.Sprintf("(url of %s)", p.ForkTarget)
+		}
+		fmt.Fprintf(b, "Remote:      %s %s\n", r.Name, url)
+	}
+	if p.Checkout != nil {
+		if p.Checkout.PullRequest > 0 {
+			fmt.Fprintf(b, "Checkout:    pull request #%d (%s) from %s\n", p.Checkout.PullRequest, p.Checkout.PullRequestRef, p.Checkout.Remote)
+		} else {
+			fmt.Fprintf(b, "Checkout:    %s from %s\n", p.Checkout.Ref, p.Checkout.Remote)
+		}
+	}
+	if p.Klonefile != nil {
+		fmt.Fprintf(b, "Submodules:  %s\n", p.Klonefile.Submodules)
+		if p.Klonefile.Container != "" {
+			fmt.Fprintf(b, "Container:   %s\n", p.Klonefile.Container)
+		}
+		for _, c := range p.Klonefile.Commands {
+			if p.Klonefile.Trusted {
+				fmt.Fprintf(b, "Command:     %s\n", c)
+			} else {
+				fmt.Fprintf(b, "Command:     %s (skipped without --trust-klonefile)\n", c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// fullName is owner/name for a repository
+func fullName(repo provider.Repo) string {
+	return fmt.Sprintf("%s/%s", repo.Owner(), repo.Name())
+}
@@ -0,0 +1,223 @@
+package klone
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/paths"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// planRepo is a repository that never talks to a git server
+type planRepo struct {
+	owner, name string
+	parent      provider.Repo
+}
+
+func (r *planRepo) GitCloneUrl() string {
+	return fmt.Sprintf("https://git.example.com/%s/%s.git", r.owner, r.name)
+}
+func (r *planRepo) GitRemoteUrl() string {
+	return fmt.Sprintf("git@git.example.com:%s/%s.git", r.owner, r.name)
+}
+func (r *planRepo) HttpsCloneUrl() string           { return r.GitCloneUrl() }
+func (r *planRepo) Language() string                { return "" }
+func (r *planRepo) Owner() string                   { return r.owner }
+func (r *planRepo) Name() string                    { return r.name }
+func (r *planRepo) Description() string             { return "" }
+func (r *planRepo) ForkedFrom() provider.Repo       { return r.parent }
+func (r *planRepo) GetKlonefile() []byte            { return []byte("") }
+func (r *planRepo) SetImplementation(i interface{}) {}
+
+// planServer is a git server that only knows it's name and our user
+type planServer struct {
+	provider.GitServer
+}
+
+func (s *planServer) GetServerString() string { return "git.example.com" }
+func (s *planServer) OwnerName() string       { return "me" }
+
+// TestPlanNeedsFork will test the plan for a repository we need to fork
+func TestPlanNeedsFork(t *testing.T) {
+	os.Setenv("KLONE_WORKSPACE", "/workspace")
+	defer os.Unsetenv("KLONE_WORKSPACE")
+	k := &Kloneable{
+		gitServer: &planServer{},
+		repo:      &planRepo{owner: "team", name: "service"},
+		style:     StyleNeedsFork,
+		selector:  &kloners.Selector{PullRequest: 42, PullRequestRef: "refs/pull/42/head"},
+	}
+	err := k.findKloner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := k.plan("team/service#42")
+	b, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"query":"team/service#42","server":"git.example.com","repository":"team/service","style":"needs-fork",` +
+		`"forkTarget":"me/service","kloner":"simple","cloneUrl":"https://git.example.com/team/service.git","path":"/workspace/service",` +
+		`"remotes":[{"name":"origin","url":"","fork":true},{"name":"upstream","url":"git@git.example.com:team/service.git"}],` +
+		`"checkout":{"pullRequest":42,"pullRequestRef":"refs/pull/42/head","remote":"upstream"}}`
+	if string(b) != expected {
+		t.Fatalf("Unexpected plan: %s", b)
+	}
+}
+
+// TestPlanAlreadyForked will test the plan clones the parent of our fork
+func TestPlanAlreadyForked(t *testing.T) {
+	parent := &planRepo{owner: "team", name: "service"}
+	k := &Kloneable{
+		gitServer: &planServer{},
+		repo:      &planRepo{owner: "me", name: "service", parent: parent},
+		style:     StyleAlreadyForked,
+	}
+	err := k.findKloner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := k.plan("me/service")
+	if plan.CloneURL != parent.GitCloneUrl() || plan.ForkTarget != "" || plan.Checkout != nil {
+		t.Fatalf("Unexpected plan: %s", plan)
+	}
+	if len(plan.Remotes) != 2 || plan.Remotes[0].URL != "git@git.example.com:me/service.git" || plan.Remotes[1].URL != parent.GitRemoteUrl() {
+		t.Fatalf("Unexpected remotes: %s", plan)
+	}
+}
+
+// TestPlanFileURL will test planning a klone does not touch the disk
+func TestPlanFileURL(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	plan, err := Plan(k.repo.GitCloneUrl())
+	if err != nil {
+		t.Fatalf("Unable to plan klone: %v", err)
+	}
+	if plan.Style != "no-fork" || plan.Path != path || len(plan.Remotes) != 1 || plan.Remotes[0].Name != "origin" {
+		t.Fatalf("Unexpected plan: %s", plan)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+		t.Fatalf("Planning a klone created the workspace: %v", err)
+	}
+}
+
+// TestPlanKlonefile will test the kloner and remotes of a Klonefile win
+func TestPlanKlonefile(t *testing.T) {
+	k := &Kloneable{
+		gitServer: &planServer{},
+		repo: &klonefileRepo{
+			Repo:      &planRepo{owner: "me", name: "service"},
+			klonefile: "kloner: golang\npath: /src/service\nremotes:\n  origin: mine\ncontainer: golang:1.9\ncommands:\n  - make\n",
+		},
+		style: StyleOwner,
+	}
+	TrustKlonefile = true
+	defer func() { TrustKlonefile = false }()
+	err := k.loadKlonefile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = k.findKloner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := k.plan("me/service")
+	b, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"query":"me/service","server":"git.example.com","repository":"me/service","style":"owner",` +
+		`"kloner":"golang","cloneUrl":"https://git.example.com/me/service.git","path":"/src/service",` +
+		`"remotes":[{"name":"mine","url":"git@git.example.com:me/service.git"}],` +
+		`"klonefile":{"submodules":"recursive","container":"golang:1.9","commands":["make"],"trusted":true}}`
+	if string(b) != expected {
+		t.Fatalf("Unexpected plan: %s", b)
+	}
+	ForceKloner = "simple"
+	defer func() { ForceKloner = "" }()
+	err = k.findKloner()
+	if err != nil || k.klonerName != "simple" {
+		t.Fatalf("Klonefile won over a forced kloner: %s %v", k.klonerName, err)
+	}
+}
+
+// TestPlanRemoteNames will test our own remote names, and a Klonefile winning over them
+func TestPlanRemoteNames(t *testing.T) {
+	OriginRemote, UpstreamRemote = "mine", "theirs"
+	defer func() { OriginRemote, UpstreamRemote = "origin", "upstream" }()
+	parent := &planRepo{owner: "team", name: "service"}
+	k := &Kloneable{
+		gitServer: &planServer{},
+		repo:      &planRepo{owner: "me", name: "service", parent: parent},
+		style:     StyleAlreadyForked,
+	}
+	err := k.findKloner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := k.plan("me/service")
+	if len(plan.Remotes) != 2 || plan.Remotes[0].Name != "mine" || plan.Remotes[1].Name != "theirs" {
+		t.Fatalf("Unexpected remotes: %s", plan)
+	}
+	k.klonefile = &klonefile.Klonefile{Remotes: klonefile.Remotes{Upstream: "parent"}}
+	plan = k.plan("me/service")
+	if len(plan.Remotes) != 2 || plan.Remotes[0].Name != "mine" || plan.Remotes[1].Name != "parent" {
+		t.Fatalf("Unexpected remotes with a Klonefile: %s", plan)
+	}
+}
+
+// TestPlanPathTemplates will test a path template wins over where the kloner would
+// clone, and a Klonefile wins over a path template
+func TestPlanPathTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := paths.File
+	defer func() {
+		paths.File = file
+		paths.Load(paths.File)
+	}()
+	paths.File = filepath.Join(dir, "paths")
+	err = ioutil.WriteFile(paths.File, []byte("default: \"/src/{{ .Server }}/{{ .Owner }}/{{ .Name }}\"\nlanguages:\n  go: \"/go/{{ .Name }}\"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = paths.Load(paths.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := &Kloneable{
+		gitServer: &planServer{},
+		repo:      &planRepo{owner: "me", name: "service"},
+		style:     StyleOwner,
+	}
+	cases := map[string]string{
+		"simple": "/src/git.example.com/me/service",
+		"gogit":  "/go/service",
+		"rust":   "/src/git.example.com/me/service",
+	}
+	for kloner, expected := range cases {
+		k.useKloner(kloner)
+		if plan := k.plan("me/service"); plan.Path != expected {
+			t.Fatalf("Expected [%s] with kloner [%s], got [%s]", expected, kloner, plan.Path)
+		}
+	}
+	k.repo = &klonefileRepo{Repo: k.repo, klonefile: "path: /klonefile/service\n"}
+	TrustKlonefile = true
+	defer func() { TrustKlonefile = false }()
+	err = k.loadKlonefile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := k.plan("me/service"); plan.Path != "/klonefile/service" {
+		t.Fatalf("Path template won over a Klonefile: %s", plan.Path)
+	}
+}
@@ -25,7 +25,11 @@ package klone
 
 import (
 	"github.com/kris-nova/klone/pkg/provider"
+	"github.com/kris-nova/klone/pkg/provider/bitbucket"
+	"github.com/kris-nova/klone/pkg/provider/gitea"
 	"github.com/kris-nova/klone/pkg/provider/github"
+	"github.com/kris-nova/klone/pkg/provider/gitlab"
+	"github.com/kris-nova/klone/pkg/provider/plaingit"
 )
 
 var RefreshCredentials = false
@@ -35,3 +39,96 @@ func NewGithubProvider() provider.KloneProvider {
 	github.RefreshCredentials = RefreshCredentials
 	return kloner
 }
+
+func NewGithubEnterpriseProvider(host *github.Host) provider.KloneProvider {
+	github.RefreshCredentials = RefreshCredentials
+	return &github.KloneProvider{Host: host}
+}
+
+func NewGitlabProvider(baseURL string) provider.KloneProvider {
+	gitlab.RefreshCredentials = RefreshCredentials
+	return &gitlab.KloneProvider{BaseURL: baseURL}
+}
+
+func NewBitbucketProvider(baseURL string) provider.KloneProvider {
+	bitbucket.RefreshCredentials = RefreshCredentials
+	return &bitbucket.KloneProvider{BaseURL: baseURL}
+}
+
+func NewGiteaProvider(baseURL string) provider.KloneProvider {
+	gitea.RefreshCredentials = RefreshCredentials
+	return &gitea.KloneProvider{BaseURL: baseURL}
+}
+
+func NewPlainGitProvider(prefix string) provider.KloneProvider {
+	return &plaingit.KloneProvider{Prefix: prefix}
+}
+
+// init registers the providers klone ships with. Other providers can
+// be added by calling provider.Register() from their own package.
+func init() {
+	provider.Register(&provider.Registration{
+		Name:           "github",
+		Hosts:          func() []string { return []string{github.PublicHost} },
+		Priority:       0,
+		GuessName:      true,
+		GuessOwnerName: true,
+		New: func(server string) provider.KloneProvider {
+			return NewGithubProvider()
+		},
+	})
+	provider.Register(&provider.Registration{
+		Name:     "github-enterprise",
+		Hosts:    github.EnterpriseHosts,
+		Priority: 10,
+		New: func(server string) provider.KloneProvider {
+			host, _ := github.LookupHost(server)
+			return NewGithubEnterpriseProvider(host)
+		},
+	})
+	provider.Register(&provider.Registration{
+		Name:     "gitlab",
+		Hosts:    gitlab.Hosts,
+		Priority: 20,
+		New: func(server string) provider.KloneProvider {
+			baseURL, _ := gitlab.ServerBaseURL(server)
+			return NewGitlabProvider(baseURL)
+		},
+	})
+	provider.Register(&provider.Registration{
+		Name:     "bitbucket",
+		Hosts:    bitbucket.Hosts,
+		Priority: 30,
+		New: func(server string) provider.KloneProvider {
+			baseURL, _ := bitbucket.ServerBaseURL(server)
+			return NewBitbucketProvider(baseURL)
+		},
+	})
+	provider.Register(&provider.Registration{
+		Name:     "gitea",
+		Hosts:    gitea.Hosts,
+		Priority: 40,
+		New: func(server string) provider.KloneProvider {
+			baseURL, _ := gitea.ServerBaseURL(server)
+			return NewGiteaProvider(baseURL)
+		},
+	})
+	provider.Register(&provider.Registration{
+		Name:     "git",
+		Hosts:    plaingit.Hosts,
+		Priority: 100,
+		New: func(server string) provider.KloneProvider {
+			return NewPlainGitProvider(plaingit.ServerPrefix(server))
+		},
+	})
+}
+
+// serverProvider will return the KloneProvider registered for a server
+// string or nil if we do not know how to talk to the server.
+func serverProvider(server string) provider.KloneProvider {
+	r, ok := provider.Lookup(server)
+	if !ok {
+		return nil
+	}
+	return r.New(server)
+}
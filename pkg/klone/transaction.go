@@ -0,0 +1,155 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// transaction.go records the side effects of a klone, so a klone that fails
+// can be undone instead of leaving incomplete work behind
+
+package klone
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// KeepPartial will keep the work of a klone that fails instead of rolling it back
+var KeepPartial = false
+
+// transaction is everything a klone has done that we might need to undo. New
+// repositories are cloned into a staging directory next to their final path, and
+// renamed into place on commit. Existing repositories are worked on in place, so
+// we remember their git config (and with it their remotes) to put back.
+type transaction struct {
+	path    string          // Where the repository will end up
+	staging string          // Where we are cloning to, empty if the repository already existed
+	config  []byte          // The .git/config of an existing repository before we touched it
+	forks   []provider.Repo // Forks we created on the git server
+}
+
+// begin is called with the final path of a repository, and will return the
+// directory we should clone into
+func (t *transaction) begin(path string) (string, error) {
+	t.path = path
+	if _, err := os.Stat(path); err == nil {
+		t.config, err = ioutil.ReadFile(t.gitConfig())
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		return path, nil
+	}
+	parent, name := filepath.Split(path)
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return "", fmt.Errorf("unable to create directory [%s]: %v", parent, err)
+	}
+	t.staging, err = ioutil.TempDir(parent, fmt.Sprintf(".%s.klone-", name))
+	if err != nil {
+		return "", fmt.Errorf("unable to create staging directory: %v", err)
+	}
+	return t.staging, nil
+}
+
+// relocate will change where a staged klone is moved on commit. If a klone is
+// already there, we drop our staging directory and work on it in place instead.
+func (t *transaction) relocate(path string) (string, error) {
+	if t.staging == "" {
+		return t.path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		err = os.RemoveAll(t.staging)
+		if err != nil {
+			return "", fmt.Errorf("unable to remove staging directory: %v", err)
+		}
+		t.staging = ""
+		return t.begin(path)
+	}
+	t.path = path
+	return t.staging, nil
+}
+
+// recordFork will remember a fork we created, so we can delete it on rollback
+func (t *transaction) recordFork(repo provider.Repo) {
+	t.forks = append(t.forks, repo)
+}
+
+// commit will move a staged repository into place, and return the final path
+func (t *transaction) commit() (string, error) {
+	if t.staging == "" {
+		return t.path, nil
+	}
+	if _, err := os.Stat(t.path); err == nil {
+		return t.staging, fmt.Errorf("unable to move klone into place, [%s] already exists. Klone is in [%s]", t.path, t.staging)
+	}
+	// A relocated klone might not have a parent directory yet
+	err := os.MkdirAll(filepath.Dir(t.path), 0755)
+	if err != nil {
+		return t.staging, fmt.Errorf("unable to create directory [%s]: %v", filepath.Dir(t.path), err)
+	}
+	err = os.Rename(t.staging, t.path)
+	if err != nil {
+		return t.staging, fmt.Errorf("unable to move klone into place: %v", err)
+	}
+	t.staging = ""
+	return t.path, nil
+}
+
+// rollback will undo everything we recorded. With KeepPartial we only move a staged
+// repository into place, so it can be inspected where the klone would have been.
+func (t *transaction) rollback(gitServer provider.GitServer) {
+	if KeepPartial {
+		if path, err := t.commit(); err != nil {
+			local.RecoverableErrorf("%v", err)
+		} else if path != "" {
+			local.Printf("Keeping partial klone [%s]", path)
+		}
+		for _, fork := range t.forks {
+			local.Printf("Keeping fork [%s/%s]", fork.Owner(), fork.Name())
+		}
+		return
+	}
+	if t.staging != "" {
+		local.Printf("Removing partial klone [%s]", t.staging)
+		err := os.RemoveAll(t.staging)
+		if err != nil {
+			local.RecoverableErrorf("Unable to remove partial klone: %v", err)
+		}
+	} else if t.config != nil {
+		local.Printf("Restoring git config [%s]", t.gitConfig())
+		err := ioutil.WriteFile(t.gitConfig(), t.config, 0644)
+		if err != nil {
+			local.RecoverableErrorf("Unable to restore git config: %v", err)
+		}
+	}
+	for _, fork := range t.forks {
+		local.Printf("Deleting fork [%s/%s]", fork.Owner(), fork.Name())
+		_, err := gitServer.DeleteRepoByOwner(fork.Owner(), fork.Name())
+		if err != nil {
+			local.RecoverableErrorf("Unable to delete fork: %v", err)
+		}
+	}
+}
+
+// gitConfig is the git config of the repository at the final path
+func (t *transaction) gitConfig() string {
+	return filepath.Join(t.path, ".git", "config")
+}
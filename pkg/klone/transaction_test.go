@@ -0,0 +1,360 @@
+package klone
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klone/kloners"
+	"github.com/kris-nova/klone/pkg/klone/kloners/javascript"
+	"github.com/kris-nova/klone/pkg/provider"
+	"github.com/kris-nova/klone/pkg/provider/github"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// deleteServer is a git server that only remembers what it was asked to delete
+type deleteServer struct {
+	provider.GitServer
+	deleted []string
+}
+
+func (s *deleteServer) DeleteRepoByOwner(owner, name string) (bool, error) {
+	s.deleted = append(s.deleted, fmt.Sprintf("%s/%s", owner, name))
+	return true, nil
+}
+
+// forkServer is a git server that hands back a fork we might already have, and answers
+// the lookup for it with lookupErr
+type forkServer struct {
+	provider.GitServer
+	lookupErr error
+	deleted   []string
+}
+
+// forkRepo is a repository that claims to be owned by us
+type forkRepo struct {
+	provider.Repo
+}
+
+func (r *forkRepo) Owner() string {
+	return "me"
+}
+
+func (s *forkServer) OwnerName() string {
+	return "me"
+}
+
+func (s *forkServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
+	if owner == s.OwnerName() {
+		return nil, s.lookupErr
+	}
+	return s.GitServer.GetRepoByOwner(owner, name)
+}
+
+func (s *forkServer) Fork(parent provider.Repo, newOwner string) (provider.Repo, error) {
+	return &forkRepo{Repo: parent}, nil
+}
+
+func (s *forkServer) DeleteRepoByOwner(owner, name string) (bool, error) {
+	s.deleted = append(s.deleted, fmt.Sprintf("%s/%s", owner, name))
+	return true, nil
+}
+
+// newTransactionKloneable will create a bare repository and a Kloneable that will
+// klone it into a workspace with plain git
+func newTransactionKloneable(t *testing.T) (*Kloneable, string, func()) {
+	dir, err := ioutil.TempDir("", "klone-transaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	work := filepath.Join(dir, "work")
+	for _, args := range [][]string{
+		{"init", "-q", work},
+		{"-C", work, "-c", "user.name=klone", "-c", "user.email=klone@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"clone", "-q", "--bare", work, filepath.Join(dir, "srv", "team", "service.git")},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unable to run git %v: %v %s", args, err, out)
+		}
+	}
+	s, err := NewPlainGitProvider(fmt.Sprintf("file://%s/srv/", dir)).NewGitServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := s.GetRepoByOwner("team", "service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := filepath.Join(dir, "ws")
+	os.Setenv("KLONE_WORKSPACE", ws)
+	HooksFile = filepath.Join(dir, "hooks")
+	k := &Kloneable{gitServer: s, repo: repo, style: StyleNoFork}
+	return k, filepath.Join(ws, "service"), func() {
+		os.Unsetenv("KLONE_WORKSPACE")
+		os.RemoveAll(dir)
+	}
+}
+
+// TestTransactionCommit will test a successful klone is moved into place
+func TestTransactionCommit(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	kloned, err := k.Klone()
+	if err != nil {
+		t.Fatalf("Unable to klone: %v", err)
+	}
+	if kloned != path {
+		t.Fatalf("Unexpected klone path: %s", kloned)
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Fatalf("Unable to find klone: %v", err)
+	}
+	entries, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("Staging directory left behind: %d entries in workspace", len(entries))
+	}
+}
+
+// TestTransactionFinishFailure will test a kloner failing to finish keeps the klone, it is
+// already in place by then
+func TestTransactionFinishFailure(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	dir := filepath.Dir(HooksFile)
+	work := filepath.Join(dir, "work")
+	err := ioutil.WriteFile(filepath.Join(work, "package.json"), []byte("{}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-C", work, "add", "."},
+		{"-C", work, "-c", "user.name=klone", "-c", "user.email=klone@example.com", "commit", "-q", "-m", "npm"},
+		{"-C", work, "push", "-q", filepath.Join(dir, "srv", "team", "service.git"), "HEAD"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unable to run git %v: %v %s", args, err, out)
+		}
+	}
+	// An npm that always fails
+	bin := filepath.Join(dir, "bin")
+	os.MkdirAll(bin, 0755)
+	err = ioutil.WriteFile(filepath.Join(bin, "npm"), []byte("#!/bin/sh\nexit 1\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", fmt.Sprintf("%s%c%s", bin, os.PathListSeparator, os.Getenv("PATH")))
+	ForceKloner = "javascript"
+	defer func() { ForceKloner = "" }()
+	javascript.Install = true
+	defer func() { javascript.Install = false }()
+	kloned, err := k.Klone()
+	if _, ok := err.(*finishError); !ok {
+		t.Fatalf("Expected a finish error, got: %v", err)
+	}
+	if kloned != path {
+		t.Fatalf("Unexpected klone path: %s", kloned)
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Fatalf("Klone was not kept: %v", err)
+	}
+}
+
+// TestTransactionRollback will test a failed klone leaves nothing behind
+func TestTransactionRollback(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	k.selector = &kloners.Selector{Ref: "missing"}
+	_, err := k.Klone()
+	if err == nil {
+		t.Fatal("Able to klone a missing ref")
+	}
+	entries, _ := ioutil.ReadDir(filepath.Dir(path))
+	if len(entries) != 0 {
+		t.Fatalf("Partial klone left behind: %d entries in workspace", len(entries))
+	}
+}
+
+// TestTransactionKeepPartial will test a failed klone is kept with KeepPartial
+func TestTransactionKeepPartial(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	KeepPartial = true
+	defer func() { KeepPartial = false }()
+	k.selector = &kloners.Selector{Ref: "missing"}
+	_, err := k.Klone()
+	if err == nil {
+		t.Fatal("Able to klone a missing ref")
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+		t.Fatalf("Unable to find partial klone: %v", err)
+	}
+}
+
+// TestTransactionRestoreConfig will test a failed klone into an existing
+// repository puts it's git config (and remotes) back
+func TestTransactionRestoreConfig(t *testing.T) {
+	k, path, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	_, err := k.Klone()
+	if err != nil {
+		t.Fatalf("Unable to klone: %v", err)
+	}
+	config := filepath.Join(path, ".git", "config")
+	before := "[core]\n\tbare = false\n[remote \"origin\"]\n\turl = git@example.com:me/service.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n"
+	err = ioutil.WriteFile(config, []byte(before), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.selector = &kloners.Selector{Ref: "missing"}
+	_, err = k.Klone()
+	if err == nil {
+		t.Fatal("Able to klone a missing ref")
+	}
+	after, err := ioutil.ReadFile(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != before {
+		t.Fatalf("Git config was not restored: %s", after)
+	}
+}
+
+// TestTransactionDeleteFork will test forks we created are deleted on rollback
+func TestTransactionDeleteFork(t *testing.T) {
+	k, _, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	s := &deleteServer{}
+	tx := &transaction{}
+	tx.recordFork(k.repo)
+	tx.rollback(s)
+	if len(s.deleted) != 1 || s.deleted[0] != "team/service" {
+		t.Fatalf("Unexpected deleted forks: %v", s.deleted)
+	}
+}
+
+// TestTransactionKeepUnknownFork will test a fork is only deleted on rollback when
+// the git server told us it did not exist before we forked
+func TestTransactionKeepUnknownFork(t *testing.T) {
+	for _, test := range []struct {
+		lookupErr error
+		deleted   int
+	}{
+		{lookupErr: fmt.Errorf("GET https://api.example.com/repos/me/service: 502 Bad Gateway"), deleted: 0},
+		{lookupErr: fmt.Errorf("dial tcp: lookup api.example.com: no such host"), deleted: 0},
+		{lookupErr: fmt.Errorf("GET https://api.example.com/repos/me/service: 404 Not Found"), deleted: 1},
+	} {
+		k, _, cleanup := newTransactionKloneable(t)
+		s := &forkServer{GitServer: k.gitServer, lookupErr: test.lookupErr}
+		k.gitServer = s
+		k.style = StyleNeedsFork
+		k.selector = &kloners.Selector{Ref: "missing"}
+		_, err := k.Klone()
+		cleanup()
+		if err == nil {
+			t.Fatal("Able to klone a missing ref")
+		}
+		if len(s.deleted) != test.deleted {
+			t.Fatalf("Lookup error [%v] deleted forks: %v", test.lookupErr, s.deleted)
+		}
+	}
+}
+
+// TestTransactionRelocate will test a Go klone is moved to it's module path, and
+// that kloning it again works on the klone we already have
+func TestTransactionRelocate(t *testing.T) {
+	k, _, cleanup := newTransactionKloneable(t)
+	defer cleanup()
+	dir := filepath.Dir(HooksFile)
+	work := filepath.Join(dir, "work")
+	err := ioutil.WriteFile(filepath.Join(work, "go.mod"), []byte("module example.com/service\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"-C", work, "add", "go.mod"},
+		{"-C", work, "-c", "user.name=klone", "-c", "user.email=klone@example.com", "commit", "-q", "-m", "go.mod"},
+		{"-C", work, "push", "-q", filepath.Join(dir, "srv", "team", "service.git"), "HEAD"},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unable to run git %v: %v %s", args, err, out)
+		}
+	}
+	gopath := os.Getenv("GOPATH")
+	os.Setenv("GOPATH", filepath.Join(dir, "go"))
+	defer os.Setenv("GOPATH", gopath)
+	ForceKloner = "gogit"
+	defer func() { ForceKloner = "" }()
+
+	expected := filepath.Join(dir, "go", "src", "example.com", "service")
+	for i := 0; i < 2; i++ {
+		kloned, err := k.Klone()
+		if err != nil {
+			t.Fatalf("Unable to klone: %v", err)
+		}
+		if kloned != expected {
+			t.Fatalf("Expected klone in [%s], got [%s]", expected, kloned)
+		}
+		if _, err := os.Stat(filepath.Join(kloned, "go.mod")); err != nil {
+			t.Fatalf("Unable to find klone: %v", err)
+		}
+		entries, _ := ioutil.ReadDir(filepath.Join(dir, "go", "src", k.gitServer.GetServerString(), "team"))
+		if len(entries) != 0 {
+			t.Fatalf("Staging directory left behind: %d entries", len(entries))
+		}
+	}
+}
+
+// TestTransactionRollbackGitHubFork will test rolling back deletes the fork we created on GitHub,
+// and nothing else
+func TestTransactionRollbackGitHubFork(t *testing.T) {
+	var deleted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v3/user":
+			fmt.Fprint(w, `{"login":"alice"}`)
+		case r.Method == "GET" && r.URL.Path == "/api/v3/repos/alice/kubernetes":
+			fmt.Fprint(w, `{"name":"kubernetes","owner":{"login":"alice"},"fork":true,"parent":{"name":"kubernetes","owner":{"login":"kubernetes"}}}`)
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "klone-transaction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(cache string) { github.Cache = cache }(github.Cache)
+	github.Cache = filepath.Join(dir, "auth")
+	defer func(keep bool) { provider.KeepCredentials = keep }(provider.KeepCredentials)
+	provider.KeepCredentials = false
+	host := &github.Host{Name: "ghe.rollback.example", BaseURL: fmt.Sprintf("%s/api/v3/", ts.URL)}
+	os.Setenv("KLONE_GITHUBCREDENTIALS", "klone")
+	defer os.Unsetenv("KLONE_GITHUBCREDENTIALS")
+	os.Setenv(host.TokenEnv(), "rollback-token")
+	defer os.Unsetenv(host.TokenEnv())
+	s, err := (&github.KloneProvider{Host: host}).NewGitServer()
+	if err != nil {
+		t.Fatalf("Unable to auth: %v", err)
+	}
+	fork, err := s.GetRepoByOwner("alice", "kubernetes")
+	if err != nil {
+		t.Fatalf("Unable to get fork: %v", err)
+	}
+	tx := &transaction{}
+	tx.recordFork(fork)
+	tx.rollback(s)
+	if len(deleted) != 1 || deleted[0] != "/api/v3/repos/alice/kubernetes" {
+		t.Fatalf("Unexpected deletes: %v", deleted)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// klonefile.go is the format of a .Klonefile, the file a repository can ship
+// to tell klone how it would like to be kloned
+
+package klonefile
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/paths"
+	"gopkg.in/yaml.v2"
+	"strings"
+	"text/template"
+)
+
+// Filename is where a repository keeps it's Klonefile
+const Filename = ".Klonefile"
+
+const (
+	SubmodulesRecursive = "recursive" // Clone submodules, and their submodules (the default)
+	SubmodulesNone      = "none"      // Never clone submodules
+)
+
+const (
+	HookPreClone   = "pre-clone"   // Before we clone, in the directory we clone into
+	HookPostClone  = "post-clone"  // After we clone, in the clone
+	HookPostRemote = "post-remote" // After our remotes are registered (and checked out), in the clone
+)
+
+// Klonefile is the per repository configuration for a klone. Everything is optional.
+//
+//	kloner: golang
+//	path: "{{ .Gopath }}/src/example.com/{{ .Name }}"
+//	remotes:
+//	  origin: mine
+//	  upstream: theirs
+//	submodules: none
+//	container: golang:1.9
+//	commands:
+//	  - make bootstrap
+//	hooks:
+//	  post-clone:
+//	    - cp .env.example .env
+type Klonefile struct {
+	Kloner     string   `yaml:"kloner"`
+	Path       string   `yaml:"path"`
+	Remotes    Remotes  `yaml:"remotes"`
+	Submodules string   `yaml:"submodules"`
+	Container  string   `yaml:"container"`
+	Commands   []string `yaml:"commands"`
+	Hooks      Hooks    `yaml:"hooks"`
+}
+
+// Hooks are the commands to run at each point of a klone. They are the same
+// in a Klonefile, and in the global hooks file.
+type Hooks struct {
+	PreClone   []string `yaml:"pre-clone"`
+	PostClone  []string `yaml:"post-clone"`
+	PostRemote []string `yaml:"post-remote"`
+}
+
+// Remotes will rename the remotes klone registers
+type Remotes struct {
+	Origin   string `yaml:"origin"`
+	Upstream string `yaml:"upstream"`
+}
+
+// Parse will parse and validate the content of a Klonefile
+func Parse(content []byte) (*Klonefile, error) {
+	k := &Klonefile{}
+	err := yaml.Unmarshal(content, k)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", Filename, err)
+	}
+	err = k.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", Filename, err)
+	}
+	return k, nil
+}
+
+func (k *Klonefile) validate() error {
+	switch k.Submodules {
+	case "", SubmodulesRecursive, SubmodulesNone:
+	default:
+		return fmt.Errorf("unknown submodule policy [%s] ( %s, %s )", k.Submodules, SubmodulesRecursive, SubmodulesNone)
+	}
+	for _, name := range []string{k.Remotes.Origin, k.Remotes.Upstream} {
+		if strings.ContainsAny(name, " \t\n/:") || strings.HasPrefix(name, "-") {
+			return fmt.Errorf("invalid remote name [%s]", name)
+		}
+	}
+	if k.Remotes.Origin != "" && k.Remotes.Origin == k.Remotes.Upstream {
+		return fmt.Errorf("origin and upstream can not both be named [%s]", k.Remotes.Origin)
+	}
+	if k.Path != "" {
+		_, err := template.New("path").Parse(k.Path)
+		if err != nil {
+			return fmt.Errorf("unable to parse path template: %v", err)
+		}
+	}
+	err := validateCommands(k.Commands)
+	if err != nil {
+		return err
+	}
+	return k.Hooks.validate()
+}
+
+// ParseHooks will parse and validate hooks on their own
+func ParseHooks(content []byte) (*Hooks, error) {
+	h := &Hooks{}
+	err := yaml.Unmarshal(content, h)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse hooks: %v", err)
+	}
+	err = h.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid hooks: %v", err)
+	}
+	return h, nil
+}
+
+func (h *Hooks) validate() error {
+	for _, hook := range []string{HookPreClone, HookPostClone, HookPostRemote} {
+		err := validateCommands(h.Commands(hook))
+		if err != nil {
+			return fmt.Errorf("%s: %v", hook, err)
+		}
+	}
+	return nil
+}
+
+// Commands are the commands to run for a hook
+func (h *Hooks) Commands(hook string) []string {
+	switch hook {
+	case HookPreClone:
+		return h.PreClone
+	case HookPostClone:
+		return h.PostClone
+	case HookPostRemote:
+		return h.PostRemote
+	}
+	return nil
+}
+
+func validateCommands(commands []string) error {
+	for _, cmd := range commands {
+		if strings.TrimSpace(cmd) == "" {
+			return fmt.Errorf("empty command")
+		}
+	}
+	return nil
+}
+
+// RecurseSubmodules is true unless the Klonefile asks us not to clone submodules
+func (k *Klonefile) RecurseSubmodules() bool {
+	return k.Submodules != SubmodulesNone
+}
+
+// ClonePath will render the path template. Relative paths are relative to the workspace.
+func (k *Klonefile) ClonePath(values *paths.Values) (string, error) {
+	return paths.Render(k.Path, values)
+}
@@ -0,0 +1,84 @@
+package klonefile
+
+import (
+	"github.com/kris-nova/klone/pkg/paths"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	k, err := Parse([]byte(`
+kloner: golang
+path: "{{ .Gopath }}/src/example.com/{{ .Name }}"
+remotes:
+  origin: mine
+  upstream: theirs
+submodules: none
+container: golang:1.9
+commands:
+  - make bootstrap
+`))
+	if err != nil {
+		t.Fatalf("Unable to parse: %v", err)
+	}
+	if k.Kloner != "golang" || k.Remotes.Origin != "mine" || k.Remotes.Upstream != "theirs" || k.Container != "golang:1.9" {
+		t.Fatalf("Unexpected Klonefile: %+v", k)
+	}
+	if k.RecurseSubmodules() || len(k.Commands) != 1 || k.Commands[0] != "make bootstrap" {
+		t.Fatalf("Unexpected Klonefile: %+v", k)
+	}
+	k, err = Parse([]byte("kloner: simple\n"))
+	if err != nil {
+		t.Fatalf("Unable to parse: %v", err)
+	}
+	if !k.RecurseSubmodules() {
+		t.Fatal("Submodules are not recursive by default")
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"kloner: [":                                  "unable to parse",
+		"submodules: sometimes":                      "unknown submodule policy",
+		"remotes:\n  origin: my fork":                "invalid remote name",
+		"remotes:\n  origin: same\n  upstream: same": "can not both be named",
+		"path: \"{{ .Name \"":                        "unable to parse path template",
+		"commands:\n  - \" \"":                       "empty command",
+		"hooks:\n  post-clone:\n    - \" \"":         "post-clone: empty command",
+	}
+	for content, expected := range cases {
+		_, err := Parse([]byte(content))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected [%s] for [%s], got: %v", expected, content, err)
+		}
+	}
+}
+
+func TestClonePath(t *testing.T) {
+	values := &paths.Values{
+		Home:      "/home/me",
+		Workspace: "/work",
+		Gopath:    "/home/me/go",
+		Server:    "github.com",
+		Owner:     "team",
+		Name:      "service",
+	}
+	cases := map[string]string{
+		"{{ .Gopath }}/src/{{ .Server }}/{{ .Owner }}/{{ .Name }}": "/home/me/go/src/github.com/team/service",
+		"~/src/{{ .Name }}":        "/home/me/src/service",
+		"{{ .Owner }}-{{ .Name }}": "/work/team-service",
+	}
+	for path, expected := range cases {
+		actual, err := (&Klonefile{Path: path}).ClonePath(values)
+		if err != nil {
+			t.Fatalf("Unable to render [%s]: %v", path, err)
+		}
+		if actual != expected {
+			t.Fatalf("Expected [%s] for [%s], got [%s]", expected, path, actual)
+		}
+	}
+	_, err := (&Klonefile{Path: "{{ .Missing }}"}).ClonePath(values)
+	if err == nil {
+		t.Fatal("Able to render a path with a missing value")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -21,6 +22,28 @@ func SPutContent(data, path string) error {
 	return nil
 }
 
+// SPutPrivateContent will write a file only we can read and write (0600). An existing
+// file is replaced, so it never keeps looser permissions.
+func SPutPrivateContent(data, path string) error {
+	path = Expand(path)
+	fo, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fo.Name())
+	_, err = io.Copy(fo, strings.NewReader(data))
+	if err == nil {
+		err = fo.Chmod(0600)
+	}
+	if cerr := fo.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(fo.Name(), path)
+}
+
 func SGetContent(path string) string {
 	path = Expand(path)
 	b, err := ioutil.ReadFile(path) // just pass the file name
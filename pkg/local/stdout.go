@@ -25,16 +25,22 @@ package local
 import (
 	"fmt"
 	"github.com/fatih/color"
+	"github.com/mattn/go-colorable"
 	"os"
 )
 
+// PrintToStderr will send all klone output to STDERR, leaving STDOUT for machine readable output
+func PrintToStderr() {
+	color.Output = colorable.NewColorableStderr()
+}
+
 func PrintPrompt(msg string) {
 	color.Blue(msg)
 }
 
 func RecoverableErrorf(msg string, a ...interface{}) {
 	b := fmt.Sprintf("[klone]: [Recoverable Error]:  %s", msg)
-	color.Cyan(b, a)
+	color.Cyan(b, a...)
 }
 
 func RecoverableError(msg string) {
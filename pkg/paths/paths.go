@@ -0,0 +1,223 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// paths.go renders the templates that decide where a repository is kloned
+
+package paths
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// File is where the path templates for every klone live
+var File = fmt.Sprintf("%s/.klone/paths", local.Home())
+
+// templates are the path templates we have loaded
+var templates = &Templates{}
+
+// Templates decide where repositories are kloned. The most specific template for a
+// repository wins ( owner, host, language, then default ), and a repository with no
+// template is kloned wherever it's kloner would put it.
+//
+//	default: "{{ .Workspace }}/{{ .Server }}/{{ .Owner }}/{{ .Name }}"
+//	languages:
+//	  go: "{{ .Gopath }}/src/{{ .Server }}/{{ .Owner }}/{{ .Name }}"
+//	hosts:
+//	  git.corp.example: "~/corp/{{ .Owner }}/{{ .Name }}"
+//	owners:
+//	  kubernetes: "{{ .Gopath }}/src/k8s.io/{{ .Name }}"
+//	  gitlab.com/team: "~/team/{{ .Name }}"
+type Templates struct {
+	Default   string            `yaml:"default"`
+	Languages map[string]string `yaml:"languages"`
+	Hosts     map[string]string `yaml:"hosts"`
+	Owners    map[string]string `yaml:"owners"`
+}
+
+// Values are the values a path template can use
+type Values struct {
+	Home      string
+	Workspace string
+	Gopath    string
+	Server    string
+	Owner     string
+	Name      string
+}
+
+// Load will read the path templates for every klone, having no file is fine
+func Load(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		templates = &Templates{}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read path templates [%s]: %v", path, err)
+	}
+	t, err := Parse(content)
+	if err != nil {
+		return fmt.Errorf("unable to load path templates [%s]: %v", path, err)
+	}
+	templates = t
+	return nil
+}
+
+// Parse will parse and validate path templates
+func Parse(content []byte) (*Templates, error) {
+	t := &Templates{}
+	err := yaml.Unmarshal(content, t)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse path templates: %v", err)
+	}
+	all := []string{t.Default}
+	for _, m := range []map[string]string{t.Languages, t.Hosts, t.Owners} {
+		for _, tmpl := range m {
+			all = append(all, tmpl)
+		}
+	}
+	for _, tmpl := range all {
+		if tmpl == "" {
+			continue
+		}
+		_, err := template.New("path").Parse(tmpl)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse path template [%s]: %v", tmpl, err)
+		}
+	}
+	return t, nil
+}
+
+// Lookup is the most specific template for a repository, or "" if we have none. The
+// language of the repository wins over the language of the kloner (E.G. typescript
+// over javascript).
+func (t *Templates) Lookup(server, owner string, languages ...string) string {
+	if tmpl, ok := t.Owners[fmt.Sprintf("%s/%s", server, owner)]; ok {
+		return tmpl
+	}
+	if tmpl, ok := t.Owners[owner]; ok {
+		return tmpl
+	}
+	if tmpl, ok := t.Hosts[server]; ok {
+		return tmpl
+	}
+	for _, language := range languages {
+		if tmpl, ok := t.Languages[strings.ToLower(language)]; ok && language != "" {
+			return tmpl
+		}
+	}
+	return t.Default
+}
+
+// Configured will render the template we have for a repository. It is false if we
+// have no template (or it will not render), and the kloner should decide.
+func Configured(server provider.GitServer, repo provider.Repo, language string) (string, bool) {
+	tmpl := templates.Lookup(server.GetServerString(), repo.Owner(), repo.Language(), language)
+	if tmpl == "" {
+		return "", false
+	}
+	path, err := Render(tmpl, NewValues(server, repo))
+	if err != nil {
+		local.RecoverableErrorf("unable to use path template: %v", err)
+		return "", false
+	}
+	return path, true
+}
+
+// NewValues are the values for a repository on a git server
+func NewValues(server provider.GitServer, repo provider.Repo) *Values {
+	return &Values{
+		Home:      local.Home(),
+		Workspace: Workspace(),
+		Gopath:    Gopath(),
+		Server:    server.GetServerString(),
+		Owner:     repo.Owner(),
+		Name:      repo.Name(),
+	}
+}
+
+// Render will render a path template. Relative paths are relative to the workspace.
+func Render(tmpl string, values *Values) (string, error) {
+	t, err := template.New("path").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse path template: %v", err)
+	}
+	b := &bytes.Buffer{}
+	err = t.Execute(b, values)
+	if err != nil {
+		return "", fmt.Errorf("unable to render path template: %v", err)
+	}
+	path := strings.TrimSpace(b.String())
+	if path == "" {
+		return "", fmt.Errorf("path template [%s] is empty", tmpl)
+	}
+	if strings.HasPrefix(path, "~") {
+		path = values.Home + strings.TrimPrefix(path, "~")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(values.Workspace, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// Under is true if path is inside dir (and is not dir itself)
+func Under(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// Workspace is $KLONE_WORKSPACE, or the current directory if it is not set
+func Workspace() string {
+	ws := os.Getenv("KLONE_WORKSPACE")
+	if ws != "" {
+		return ws
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		local.RecoverableErrorf("unable to determine current directory")
+		return local.Home()
+	}
+	return wd
+}
+
+// Gopath is the first path in $GOPATH, or $HOME/go if it is not set
+func Gopath() string {
+	epath := os.Getenv("GOPATH")
+	if epath == "" {
+		// It's now safe to assume $HOME/go
+		// thanks to Dave Cheney and the folks
+		// who work on the standard library
+		// https://github.com/golang/go/issues/17262
+		return fmt.Sprintf("%s/go", local.Home())
+	}
+	// Here we will take the first gopath defined and use that
+	return strings.Split(epath, ":")[0]
+}
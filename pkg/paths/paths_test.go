@@ -0,0 +1,140 @@
+package paths
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const content = `default: "{{ .Workspace }}/{{ .Server }}/{{ .Owner }}/{{ .Name }}"
+languages:
+  go: "{{ .Gopath }}/src/{{ .Server }}/{{ .Owner }}/{{ .Name }}"
+  typescript: "~/ts/{{ .Name }}"
+hosts:
+  git.corp.example: "~/corp/{{ .Owner }}/{{ .Name }}"
+owners:
+  kubernetes: "{{ .Gopath }}/src/k8s.io/{{ .Name }}"
+  gitlab.com/team: "team/{{ .Name }}"
+`
+
+func TestLookup(t *testing.T) {
+	templates, err := Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("Unable to parse: %v", err)
+	}
+	cases := []struct {
+		server, owner string
+		languages     []string
+		expected      string
+	}{
+		{"github.com", "kubernetes", []string{"Go", "go"}, "{{ .Gopath }}/src/k8s.io/{{ .Name }}"},
+		{"gitlab.com", "team", []string{"", ""}, "team/{{ .Name }}"},
+		{"github.com", "team", []string{"", ""}, templates.Default},
+		{"git.corp.example", "kubernetes", []string{"", ""}, "{{ .Gopath }}/src/k8s.io/{{ .Name }}"},
+		{"git.corp.example", "team", []string{"Go", "go"}, "~/corp/{{ .Owner }}/{{ .Name }}"},
+		{"github.com", "team", []string{"", "go"}, "{{ .Gopath }}/src/{{ .Server }}/{{ .Owner }}/{{ .Name }}"},
+		{"github.com", "team", []string{"TypeScript", "javascript"}, "~/ts/{{ .Name }}"},
+	}
+	for _, c := range cases {
+		if actual := templates.Lookup(c.server, c.owner, c.languages...); actual != c.expected {
+			t.Errorf("Expected [%s] for %s/%s %v, got [%s]", c.expected, c.server, c.owner, c.languages, actual)
+		}
+	}
+	if actual := (&Templates{}).Lookup("github.com", "team", "go"); actual != "" {
+		t.Fatalf("Found a template with no templates: %s", actual)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"default: [":                       "unable to parse path templates",
+		"owners:\n  team: \"{{ .Name \"\n": "unable to parse path template",
+	}
+	for content, expected := range cases {
+		_, err := Parse([]byte(content))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected [%s] for [%s], got: %v", expected, content, err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	values := &Values{
+		Home:      "/home/me",
+		Workspace: "/work",
+		Gopath:    "/home/me/go",
+		Server:    "github.com",
+		Owner:     "team",
+		Name:      "service",
+	}
+	cases := map[string]string{
+		"{{ .Workspace }}/{{ .Server }}/{{ .Owner }}/{{ .Name }}": "/work/github.com/team/service",
+		"~/src/{{ .Name }}":        "/home/me/src/service",
+		"{{ .Owner }}-{{ .Name }}": "/work/team-service",
+	}
+	for tmpl, expected := range cases {
+		actual, err := Render(tmpl, values)
+		if err != nil {
+			t.Fatalf("Unable to render [%s]: %v", tmpl, err)
+		}
+		if actual != expected {
+			t.Fatalf("Expected [%s] for [%s], got [%s]", expected, tmpl, actual)
+		}
+	}
+	for _, tmpl := range []string{"{{ .Missing }}", " "} {
+		if _, err := Render(tmpl, values); err == nil {
+			t.Fatalf("Able to render [%s]", tmpl)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { templates = &Templates{} }()
+	path := filepath.Join(dir, "paths")
+	ioutil.WriteFile(path, []byte(content), 0644)
+	err = Load(path)
+	if err != nil || templates.Owners["kubernetes"] == "" {
+		t.Fatalf("Unable to load path templates: %v", err)
+	}
+	err = Load(filepath.Join(dir, "missing"))
+	if err != nil || templates.Default != "" {
+		t.Fatalf("Unable to load missing path templates: %v", err)
+	}
+	ioutil.WriteFile(path, []byte("default: [\n"), 0644)
+	if err = Load(path); err == nil {
+		t.Fatal("Able to load invalid path templates")
+	}
+}
+
+func TestGopath(t *testing.T) {
+	gopath := os.Getenv("GOPATH")
+	defer os.Setenv("GOPATH", gopath)
+	os.Setenv("GOPATH", "/first:/second")
+	if actual := Gopath(); actual != "/first" {
+		t.Fatalf("Unexpected GOPATH: %s", actual)
+	}
+}
+
+func TestUnder(t *testing.T) {
+	cases := map[string]bool{
+		"/ws/team/service":  true,
+		"/ws/service":       true,
+		"/ws":               false,
+		"/ws/../etc":        false,
+		"/wsother/service":  false,
+		"/home/me/.ssh":     false,
+		"/ws/team/../../ws": false,
+	}
+	for path, expected := range cases {
+		if actual := Under("/ws", path); actual != expected {
+			t.Fatalf("Expected [%v] for [%s], got [%v]", expected, path, actual)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// api.go is the JSON REST API of a git server, every provider brings only it's auth header
+
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultClient is the client we talk to git servers with, so a server that hangs is
+// unable to hang klone with it
+var DefaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// API is the JSON REST API of a git server
+type API struct {
+	// BaseURL is where every path is sent, E.G. https://gitlab.com/api/v4
+	BaseURL string
+
+	// Client is DefaultClient unless it is set
+	Client *http.Client
+
+	// Authorize will add our credentials to a request
+	Authorize func(req *http.Request)
+}
+
+// Do will send a request to the API and decode the response into v, a *[]byte will
+// get the raw response instead
+func (a *API) Do(method, path string, body, v interface{}) error {
+	_, err := a.DoResponse(method, path, body, v)
+	return err
+}
+
+// DoResponse is Do, and will also return the response (E.G. for the headers of a paginated response).
+// Paginated responses may give us an absolute URL for the next page, which has to be on our host
+// as we send our credentials with it.
+func (a *API) DoResponse(method, path string, body, v interface{}) (*http.Response, error) {
+	u := fmt.Sprintf("%s%s", a.BaseURL, path)
+	if strings.Contains(path, "://") {
+		if !sameHost(a.BaseURL, path) {
+			return nil, fmt.Errorf("refusing to send credentials for [%s] to [%s]", a.BaseURL, path)
+		}
+		u = path
+	}
+	reader := bytes.NewReader([]byte{})
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if a.Authorize != nil {
+		a.Authorize(req)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := a.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, fmt.Errorf("%s %s: %s %s", method, u, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if raw, ok := v.(*[]byte); ok {
+		*raw = data
+		return resp, nil
+	}
+	if v != nil && len(data) > 0 {
+		err = json.Unmarshal(data, v)
+		if err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// sameHost is true if two urls have the same scheme and host
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// CustomBaseURL is the base URL of a self-hosted git server defined in an env var
+// (E.G. $KLONE_GITLABURL), https:// unless it says otherwise
+func CustomBaseURL(env string) (string, bool) {
+	custom := os.Getenv(env)
+	if custom == "" {
+		return "", false
+	}
+	if !strings.Contains(custom, "://") {
+		custom = fmt.Sprintf("https://%s", custom)
+	}
+	return strings.TrimSuffix(custom, "/"), true
+}
+
+// CustomHost is the host of the git server defined in an env var, if there is one
+func CustomHost(env string) (string, bool) {
+	custom, ok := CustomBaseURL(env)
+	if !ok {
+		return "", false
+	}
+	u, err := url.Parse(custom)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	return u.Host, true
+}
+
+// Host is the host of a base URL, which is the string we would want to use in things like $GOPATH
+func Host(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIDo(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/user":
+			w.Write([]byte(`{"login":"kris-nova"}`))
+		case "/api/raw":
+			w.Write([]byte("raw content"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}
+	}))
+	defer ts.Close()
+	api := &API{
+		BaseURL: ts.URL + "/api",
+		Authorize: func(req *http.Request) {
+			req.Header.Set("Authorization", "token secret")
+		},
+	}
+	usr := struct {
+		Login string `json:"login"`
+	}{}
+	err := api.Do("GET", "/user", nil, &usr)
+	if err != nil {
+		t.Fatalf("Unable to get user: %v", err)
+	}
+	if usr.Login != "kris-nova" {
+		t.Fatalf("Expected [kris-nova] got [%s]", usr.Login)
+	}
+	var raw []byte
+	err = api.Do("GET", ts.URL+"/api/raw", nil, &raw)
+	if err != nil {
+		t.Fatalf("Unable to follow absolute URL on our host: %v", err)
+	}
+	if string(raw) != "raw content" {
+		t.Fatalf("Expected raw response got [%s]", string(raw))
+	}
+	err = api.Do("GET", "/missing", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected 404 got: %v", err)
+	}
+}
+
+func TestAPIRefusesOtherHosts(t *testing.T) {
+	sent := false
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+	}))
+	defer other.Close()
+	api := &API{
+		BaseURL: "https://api.example.com",
+		Authorize: func(req *http.Request) {
+			req.SetBasicAuth("user", "secret")
+		},
+	}
+	err := api.Do("GET", other.URL+"/2.0/repositories?page=2", nil, nil)
+	if err == nil {
+		t.Fatalf("Expected error sending credentials to another host")
+	}
+	if sent {
+		t.Fatalf("Request was sent to another host")
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Unsetenv("KLONE_TESTTOKEN")
+	os.Unsetenv("KLONE_TESTUSER")
+	src := &TokenSource{
+		Name:     "Test",
+		Host:     "git.example.com",
+		CacheDir: filepath.Join(dir, "test"),
+		TokenEnv: "KLONE_TESTTOKEN",
+		UserEnv:  "KLONE_TESTUSER",
+		Testing:  true,
+	}
+	_, _, err = src.Token()
+	if err == nil {
+		t.Fatalf("Expected error without a token")
+	}
+	err = src.Cache("kris-nova:cached")
+	if err != nil {
+		t.Fatalf("Unable to cache: %v", err)
+	}
+	info, err := os.Stat(src.CachePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected cache to be 0600 got %v", info.Mode().Perm())
+	}
+	if content, _ := ioutil.ReadFile(src.CachePath()); !IsEncrypted(content) || strings.Contains(string(content), "cached") {
+		t.Fatalf("Cache was not encrypted: %s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.key")); err != nil {
+		t.Fatalf("Unable to find identity: %v", err)
+	}
+	user, token, err := src.Token()
+	if err != nil {
+		t.Fatalf("Unable to read cached token: %v", err)
+	}
+	if user != "kris-nova" || token != "cached" {
+		t.Fatalf("Expected [kris-nova:cached] got [%s:%s]", user, token)
+	}
+
+	// A plaintext cache from an older klone is left alone while we plan, and encrypted otherwise
+	err = ioutil.WriteFile(src.CachePath(), []byte("kris-nova:plaintext\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	KeepCredentials = false
+	user, token, err = src.Token()
+	KeepCredentials = true
+	if err != nil || user != "kris-nova" || token != "plaintext" {
+		t.Fatalf("Unable to read plaintext cache: [%s:%s] %v", user, token, err)
+	}
+	if content, _ := ioutil.ReadFile(src.CachePath()); string(content) != "kris-nova:plaintext\n" {
+		t.Fatalf("Cache was rewritten while planning: %s", content)
+	}
+	_, token, err = src.Token()
+	if err != nil || token != "plaintext" {
+		t.Fatalf("Unable to read plaintext cache: [%s] %v", token, err)
+	}
+	if content, _ := ioutil.ReadFile(src.CachePath()); !IsEncrypted(content) {
+		t.Fatalf("Plaintext cache was not encrypted: %s", content)
+	}
+
+	os.Setenv("KLONE_TESTTOKEN", "env")
+	os.Setenv("KLONE_TESTUSER", "env-user")
+	defer os.Unsetenv("KLONE_TESTTOKEN")
+	defer os.Unsetenv("KLONE_TESTUSER")
+	user, token, err = src.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != "env-user" || token != "env" {
+		t.Fatalf("Expected env to win got [%s:%s]", user, token)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// bitbucket.go is a representation of Bitbucket Cloud (bitbucket.org) or a Bitbucket Server as a git server
+
+package bitbucket
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"net/http"
+)
+
+var (
+	CacheDir           = fmt.Sprintf("%s/.klone/bitbucket", local.Home())
+	RefreshCredentials = false
+	Testing            = false
+
+	// CloudAPIURL is the root of the Bitbucket Cloud API
+	CloudAPIURL = "https://api.bitbucket.org"
+)
+
+const (
+	CloudServerString = "bitbucket.org"
+)
+
+// GitServer is a representation of Bitbucket. Bitbucket Cloud uses workspaces as
+// owners, where Bitbucket Server uses projects (and ~USER for personal projects).
+// If baseURL is empty we are talking to Bitbucket Cloud.
+type GitServer struct {
+	baseURL  string
+	username string
+	token    string
+	email    string
+	owner    string
+	client   *http.Client
+	repos    map[string]provider.Repo
+}
+
+// ServerBaseURL will return the base URL of the Bitbucket instance we know
+// for a server string. bitbucket.org is always known (with an empty base URL
+// meaning Bitbucket Cloud), and a Bitbucket Server can be defined with $KLONE_BITBUCKETURL
+func ServerBaseURL(server string) (string, bool) {
+	if server == CloudServerString {
+		return "", true
+	}
+	if host, ok := provider.CustomHost("KLONE_BITBUCKETURL"); !ok || host != server {
+		return "", false
+	}
+	return provider.CustomBaseURL("KLONE_BITBUCKETURL")
+}
+
+// Hosts are the server strings of every Bitbucket instance we know about
+func Hosts() []string {
+	hosts := []string{CloudServerString}
+	if host, ok := provider.CustomHost("KLONE_BITBUCKETURL"); ok {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// isCloud is true when we are talking to bitbucket.org
+func (s *GitServer) isCloud() bool {
+	return s.baseURL == ""
+}
+
+// GetServerString returns the host of the Bitbucket instance, which is the string
+// we would want to use in things like $GOPATH
+func (s *GitServer) GetServerString() string {
+	if s.isCloud() {
+		return CloudServerString
+	}
+	return provider.Host(s.baseURL)
+}
+
+// OwnerName is your username on Bitbucket Cloud, and your personal project (~USER) on Bitbucket Server
+func (s *GitServer) OwnerName() string {
+	return s.owner
+}
+
+// HTTPSCredentials is our user and app password (Cloud) or access token (Server), which
+// Bitbucket takes for git over HTTPS
+func (s *GitServer) HTTPSCredentials() (string, string) {
+	return s.username, s.token
+}
+
+func (s *GitServer) OwnerEmail() string {
+	return s.email
+}
+
+// Authenticate will look for a username and app password (or access token) with the following hierarchy.
+// 1. Username and token from env vars
+// 2. Username and token from the local cache for this host
+// 3. Prompt for username and token (and cache them)
+func (s *GitServer) Authenticate() error {
+	user, token, err := s.tokenSource().Token()
+	if err != nil {
+		return err
+	}
+	RefreshCredentials = false
+	s.username = user
+	s.token = token
+	if s.isCloud() {
+		err = s.cloudAuthenticate()
+	} else {
+		err = s.serverAuthenticate()
+	}
+	if err != nil {
+		return err
+	}
+	local.Printf("Successfully authenticated [%s] with [%s]", s.username, s.GetServerString())
+	return nil
+}
+
+// GetRepoByOwner is the most effecient way to look up a repository exactly by it's name and owner
+func (s *GitServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
+	var r *Repo
+	var err error
+	if s.isCloud() {
+		r, err = s.cloudGetRepo(owner, name)
+	} else {
+		r, err = s.serverGetRepo(owner, name)
+	}
+	if err != nil {
+		local.Printf("Unable to find repo [%s/%s]", owner, name)
+		return &Repo{owner: owner, slug: name}, err
+	}
+	return r, nil
+}
+
+// GetRepo is the most effecient way to look up a repository exactly by it's name and assumed owner (you)
+func (s *GitServer) GetRepo(name string) (provider.Repo, error) {
+	return s.GetRepoByOwner(s.OwnerName(), name)
+}
+
+// GetRepos will return (and cache) a hash map of repositories by name
+func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
+	if len(s.repos) == 0 {
+		var repos []*Repo
+		var err error
+		if s.isCloud() {
+			repos, err = s.cloudListRepos()
+		} else {
+			repos, err = s.serverListRepos()
+		}
+		if err != nil {
+			return make(map[string]provider.Repo), err
+		}
+		providerRepos := make(map[string]provider.Repo)
+		for _, r := range repos {
+			providerRepos[r.Name()] = r
+		}
+		s.repos = providerRepos
+	}
+	local.Printf("Cached %d repositories in memory", len(s.repos))
+	return s.repos, nil
+}
+
+// Fork will fork a repository into the workspace (or project) newOwner
+func (s *GitServer) Fork(parent provider.Repo, newOwner string) (provider.Repo, error) {
+	var r *Repo
+	var err error
+	if s.isCloud() {
+		r, err = s.cloudFork(parent, newOwner)
+	} else {
+		r, err = s.serverFork(parent, newOwner)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to fork repository [%s]: %v", parent.Name(), err)
+	}
+	return r, nil
+}
+
+func (s *GitServer) NewRepo(name, desc string) (provider.Repo, error) {
+	var r *Repo
+	var err error
+	if s.isCloud() {
+		r, err = s.cloudNewRepo(name, desc)
+	} else {
+		r, err = s.serverNewRepo(name, desc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *GitServer) DeleteRepoByOwner(owner, name string) (bool, error) {
+	var path string
+	if s.isCloud() {
+		path = fmt.Sprintf("/2.0/repositories/%s/%s", owner, name)
+	} else {
+		path = fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", owner, name)
+	}
+	err := s.do("DELETE", path, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GitServer) DeleteRepo(name string) (bool, error) {
+	return s.DeleteRepoByOwner(s.OwnerName(), name)
+}
+
+// PullRequestRef is where Bitbucket Server keeps the head of a pull request,
+// Bitbucket Cloud does not advertise pull requests as git refs
+func (s *GitServer) PullRequestRef(number int) (string, error) {
+	if s.isCloud() {
+		return "", fmt.Errorf("Bitbucket Cloud does not support kloning pull requests")
+	}
+	return fmt.Sprintf("refs/pull-requests/%d/from", number), nil
+}
+
+// klonefile will fetch the raw Klonefile from the default branch of a repository
+func (s *GitServer) klonefile(owner, name string) ([]byte, error) {
+	var path string
+	if s.isCloud() {
+		path = fmt.Sprintf("/2.0/repositories/%s/%s/src/HEAD/%s", owner, name, klonefile.Filename)
+	} else {
+		path = fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s/raw/%s", owner, name, klonefile.Filename)
+	}
+	var content []byte
+	err := s.do("GET", path, nil, &content)
+	if err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// apiURL is the root we send API requests to
+func (s *GitServer) apiURL() string {
+	if s.isCloud() {
+		return CloudAPIURL
+	}
+	return s.baseURL
+}
+
+// do will send a request to the Bitbucket API and decode the response into v,
+// a *[]byte will get the raw response instead. Paginated responses give us the
+// absolute URL of the next page, which we only follow on our own host.
+func (s *GitServer) do(method, path string, body, v interface{}) error {
+	api := &provider.API{
+		BaseURL: s.apiURL(),
+		Client:  s.client,
+		Authorize: func(req *http.Request) {
+			req.SetBasicAuth(s.username, s.token)
+		},
+	}
+	return api.Do(method, path, body, v)
+}
+
+// tokenSource is where we find the username and app password (or access token) for this Bitbucket host
+func (s *GitServer) tokenSource() *provider.TokenSource {
+	return &provider.TokenSource{
+		Name:        "Bitbucket",
+		Host:        s.GetServerString(),
+		CacheDir:    CacheDir,
+		TokenEnv:    "KLONE_BITBUCKETTOKEN",
+		UserEnv:     "KLONE_BITBUCKETUSER",
+		TokenPrompt: "App Password or Access Token",
+		Refresh:     RefreshCredentials,
+		Testing:     Testing,
+	}
+}
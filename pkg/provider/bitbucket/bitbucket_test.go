@@ -0,0 +1,206 @@
+package bitbucket
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// cloudRepo builds a Bitbucket Cloud API repository response
+func cloudRepo(owner, slug, lang string, parent map[string]interface{}) map[string]interface{} {
+	r := map[string]interface{}{
+		"slug":      slug,
+		"full_name": fmt.Sprintf("%s/%s", owner, slug),
+		"language":  lang,
+		"links": map[string]interface{}{
+			"clone": []map[string]string{
+				{"name": "https", "href": fmt.Sprintf("https://alice@bitbucket.org/%s/%s.git", owner, slug)},
+				{"name": "ssh", "href": fmt.Sprintf("git@bitbucket.org:%s/%s.git", owner, slug)},
+			},
+		},
+	}
+	if parent != nil {
+		r["parent"] = parent
+	}
+	return r
+}
+
+// serverRepo builds a Bitbucket Server API repository response
+func serverRepo(project, slug string, origin map[string]interface{}) map[string]interface{} {
+	r := map[string]interface{}{
+		"slug":    slug,
+		"project": map[string]string{"key": project},
+		"links": map[string]interface{}{
+			"clone": []map[string]string{
+				{"name": "http", "href": fmt.Sprintf("https://alice@bitbucket.example.com/scm/%s/%s.git", strings.ToLower(project), slug)},
+				{"name": "ssh", "href": fmt.Sprintf("ssh://git@bitbucket.example.com:7999/%s/%s.git", strings.ToLower(project), slug)},
+			},
+		},
+	}
+	if origin != nil {
+		r["origin"] = origin
+	}
+	return r
+}
+
+func newTestServer(t *testing.T, handler http.HandlerFunc, cloud bool) (*GitServer, func()) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "alice" || pass != "secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}))
+	os.Setenv("KLONE_BITBUCKETUSER", "alice")
+	os.Setenv("KLONE_BITBUCKETTOKEN", "secret")
+	Testing = true
+	s := &GitServer{baseURL: ts.URL}
+	if cloud {
+		CloudAPIURL = ts.URL
+		s.baseURL = ""
+	}
+	err := s.Authenticate()
+	if err != nil {
+		ts.Close()
+		t.Fatalf("Unable to auth: %v", err)
+	}
+	return s, ts.Close
+}
+
+func TestCloudGetRepoAndFork(t *testing.T) {
+	var forkBody map[string]map[string]string
+	s, done := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/2.0/user":
+			json.NewEncoder(w).Encode(map[string]string{"username": "alice"})
+		case r.URL.Path == "/2.0/user/emails":
+			json.NewEncoder(w).Encode(map[string]interface{}{"values": []map[string]interface{}{{"email": "alice@example.com", "is_primary": true}}})
+		case r.URL.Path == "/2.0/repositories/atlassian/stash" && r.Method == "GET":
+			json.NewEncoder(w).Encode(cloudRepo("atlassian", "stash", "java", nil))
+		case r.URL.Path == "/2.0/repositories/atlassian/stash/forks" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&forkBody)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(cloudRepo(forkBody["workspace"]["slug"], "stash", "java", cloudRepo("atlassian", "stash", "java", nil)))
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}, true)
+	defer done()
+	if s.GetServerString() != "bitbucket.org" || s.OwnerName() != "alice" || s.OwnerEmail() != "alice@example.com" {
+		t.Fatalf("Unexpected server [%s] owner [%s] email [%s]", s.GetServerString(), s.OwnerName(), s.OwnerEmail())
+	}
+	repo, err := s.GetRepoByOwner("atlassian", "stash")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if repo.Owner() != "atlassian" || repo.Name() != "stash" || repo.Language() != "java" {
+		t.Fatalf("Unexpected repo: %s/%s [%s]", repo.Owner(), repo.Name(), repo.Language())
+	}
+	if repo.HttpsCloneUrl() != "https://bitbucket.org/atlassian/stash.git" {
+		t.Fatalf("Unexpected https url: %s", repo.HttpsCloneUrl())
+	}
+	if repo.GitRemoteUrl() != "git@bitbucket.org:atlassian/stash.git" {
+		t.Fatalf("Unexpected remote url: %s", repo.GitRemoteUrl())
+	}
+	fork, err := s.Fork(repo, s.OwnerName())
+	if err != nil {
+		t.Fatalf("Unable to fork: %v", err)
+	}
+	if forkBody["workspace"]["slug"] != "alice" {
+		t.Fatalf("Unexpected fork request: %v", forkBody)
+	}
+	if fork.Owner() != "alice" || fork.ForkedFrom() == nil || fork.ForkedFrom().Owner() != "atlassian" {
+		t.Fatalf("Unexpected fork: %s/%s", fork.Owner(), fork.Name())
+	}
+	_, err = s.GetRepo("missing")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("Expected 404 for missing repo: %v", err)
+	}
+}
+
+func TestServerGetRepoAndFork(t *testing.T) {
+	var forkBody map[string]interface{}
+	s, done := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/api/1.0/users/alice":
+			json.NewEncoder(w).Encode(map[string]string{"name": "alice", "slug": "alice", "emailAddress": "alice@example.com"})
+		case r.URL.Path == "/rest/api/1.0/projects/PLAT/repos/service" && r.Method == "GET":
+			json.NewEncoder(w).Encode(serverRepo("PLAT", "service", nil))
+		case r.URL.Path == "/rest/api/1.0/projects/PLAT/repos/service" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&forkBody)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(serverRepo("~ALICE", "service", serverRepo("PLAT", "service", nil)))
+		case r.URL.Path == "/rest/api/1.0/projects/~ALICE/repos":
+			json.NewEncoder(w).Encode(map[string]interface{}{"values": []interface{}{serverRepo("~ALICE", "service", serverRepo("PLAT", "service", nil))}, "isLastPage": true})
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}, false)
+	defer done()
+	if s.OwnerName() != "~ALICE" {
+		t.Fatalf("Unexpected owner: %s", s.OwnerName())
+	}
+	repo, err := s.GetRepoByOwner("PLAT", "service")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if repo.Owner() != "PLAT" || repo.Name() != "service" || repo.Language() != "" {
+		t.Fatalf("Unexpected repo: %s/%s", repo.Owner(), repo.Name())
+	}
+	if repo.HttpsCloneUrl() != "https://bitbucket.example.com/scm/plat/service.git" {
+		t.Fatalf("Unexpected https url: %s", repo.HttpsCloneUrl())
+	}
+	fork, err := s.Fork(repo, s.OwnerName())
+	if err != nil {
+		t.Fatalf("Unable to fork: %v", err)
+	}
+	if forkBody["project"].(map[string]interface{})["key"] != "~ALICE" {
+		t.Fatalf("Unexpected fork request: %v", forkBody)
+	}
+	if fork.Owner() != "~ALICE" || fork.ForkedFrom().Owner() != "PLAT" {
+		t.Fatalf("Unexpected fork: %s/%s", fork.Owner(), fork.Name())
+	}
+	repos, err := s.GetRepos()
+	if err != nil {
+		t.Fatalf("Unable to list repos: %v", err)
+	}
+	if _, ok := repos["service"]; !ok {
+		t.Fatalf("Unable to find fork in repos: %v", repos)
+	}
+}
+
+func TestCloudNextPageOtherHost(t *testing.T) {
+	leaked := false
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = true
+	}))
+	defer other.Close()
+	s, done := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/user":
+			json.NewEncoder(w).Encode(map[string]string{"username": "alice"})
+		case "/2.0/user/emails":
+			json.NewEncoder(w).Encode(map[string]interface{}{"values": []map[string]interface{}{}})
+		case "/2.0/repositories/alice":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"values": []map[string]interface{}{cloudRepo("alice", "stash", "java", nil)},
+				"next":   other.URL + "/2.0/repositories/alice?page=2",
+			})
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}, true)
+	defer done()
+	_, err := s.GetRepos()
+	if err == nil {
+		t.Fatalf("Expected error following a next page on another host")
+	}
+	if leaked {
+		t.Fatalf("Sent our credentials to another host")
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// cloud.go holds the Bitbucket Cloud (api.bitbucket.org/2.0) half of the provider
+
+package bitbucket
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+	"strings"
+)
+
+type link struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type cloudUser struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+type cloudEmails struct {
+	Values []struct {
+		Email     string `json:"email"`
+		IsPrimary bool   `json:"is_primary"`
+	} `json:"values"`
+}
+
+type cloudRepository struct {
+	Slug        string           `json:"slug"`
+	Name        string           `json:"name"`
+	FullName    string           `json:"full_name"`
+	Description string           `json:"description"`
+	Language    string           `json:"language"`
+	Parent      *cloudRepository `json:"parent"`
+	Links       struct {
+		Clone []link `json:"clone"`
+	} `json:"links"`
+}
+
+type cloudRepositories struct {
+	Values []*cloudRepository `json:"values"`
+	Next   string             `json:"next"`
+}
+
+// toRepo will convert a Bitbucket Cloud repository into a klone Repo
+func (c *cloudRepository) toRepo(s *GitServer) *Repo {
+	r := &Repo{
+		slug:        c.Slug,
+		description: c.Description,
+		language:    c.Language,
+		server:      s,
+	}
+	// Workspaces are the first half of the full name
+	if spl := strings.SplitN(c.FullName, "/", 2); len(spl) == 2 {
+		r.owner = spl[0]
+		if r.slug == "" {
+			r.slug = spl[1]
+		}
+	}
+	for _, l := range c.Links.Clone {
+		switch l.Name {
+		case "https":
+			r.httpsURL = stripUserInfo(l.Href)
+		case "ssh":
+			r.sshURL = l.Href
+		}
+	}
+	if c.Parent != nil {
+		r.forkedFrom = c.Parent.toRepo(s)
+	}
+	return r
+}
+
+func (s *GitServer) cloudAuthenticate() error {
+	usr := &cloudUser{}
+	err := s.do("GET", "/2.0/user", nil, usr)
+	if err != nil {
+		return err
+	}
+	s.owner = usr.Username
+	emails := &cloudEmails{}
+	err = s.do("GET", "/2.0/user/emails", nil, emails)
+	if err != nil {
+		// Email requires an extra scope, so we can live without it
+		return nil
+	}
+	for _, e := range emails.Values {
+		if e.IsPrimary {
+			s.email = e.Email
+		}
+	}
+	return nil
+}
+
+func (s *GitServer) cloudGetRepo(owner, name string) (*Repo, error) {
+	c := &cloudRepository{}
+	err := s.do("GET", fmt.Sprintf("/2.0/repositories/%s/%s", owner, name), nil, c)
+	if err != nil {
+		return nil, err
+	}
+	return c.toRepo(s), nil
+}
+
+func (s *GitServer) cloudListRepos() ([]*Repo, error) {
+	var repos []*Repo
+	next := fmt.Sprintf("/2.0/repositories/%s?pagelen=100", s.OwnerName())
+	for next != "" {
+		page := &cloudRepositories{}
+		err := s.do("GET", next, nil, page)
+		if err != nil {
+			return repos, err
+		}
+		for _, c := range page.Values {
+			repos = append(repos, c.toRepo(s))
+		}
+		next = page.Next
+	}
+	return repos, nil
+}
+
+func (s *GitServer) cloudFork(parent provider.Repo, newOwner string) (*Repo, error) {
+	body := map[string]interface{}{
+		"workspace": map[string]string{
+			"slug": newOwner,
+		},
+	}
+	c := &cloudRepository{}
+	err := s.do("POST", fmt.Sprintf("/2.0/repositories/%s/%s/forks", parent.Owner(), parent.Name()), body, c)
+	if err != nil {
+		return nil, err
+	}
+	return c.toRepo(s), nil
+}
+
+func (s *GitServer) cloudNewRepo(name, desc string) (*Repo, error) {
+	body := map[string]interface{}{
+		"scm":         "git",
+		"description": desc,
+	}
+	c := &cloudRepository{}
+	err := s.do("POST", fmt.Sprintf("/2.0/repositories/%s/%s", s.OwnerName(), name), body, c)
+	if err != nil {
+		return nil, err
+	}
+	return c.toRepo(s), nil
+}
+
+// stripUserInfo will remove the user@ Bitbucket puts in HTTPS clone links
+func stripUserInfo(href string) string {
+	spl := strings.SplitN(href, "://", 2)
+	if len(spl) != 2 {
+		return href
+	}
+	rest := spl[1]
+	at := strings.Index(rest, "@")
+	slash := strings.Index(rest, "/")
+	if at == -1 || (slash != -1 && at > slash) {
+		return href
+	}
+	return fmt.Sprintf("%s://%s", spl[0], rest[at+1:])
+}
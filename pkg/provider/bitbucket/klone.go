@@ -0,0 +1,45 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// klone.go is top of the provider. This is the primary data structure.
+
+package bitbucket
+
+import (
+	"github.com/kris-nova/klone/pkg/provider"
+	"strings"
+)
+
+// KloneProvider is the Bitbucket provider. BaseURL is the root of a Bitbucket Server
+// instance (E.G. https://bitbucket.example.com) and Bitbucket Cloud is used if empty.
+type KloneProvider struct {
+	BaseURL string
+}
+
+func (k *KloneProvider) NewGitServer() (provider.GitServer, error) {
+	srv := &GitServer{
+		baseURL: strings.TrimSuffix(k.BaseURL, "/"),
+	}
+	err := srv.Authenticate()
+	if err != nil {
+		return srv, err
+	}
+	return srv, nil
+}
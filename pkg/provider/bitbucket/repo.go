@@ -0,0 +1,110 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// repo.go is an implementation of a git repository according to klone
+
+package bitbucket
+
+import (
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"strings"
+)
+
+// Repo is a Bitbucket repository. Cloud and Server repositories look
+// very different in the API, so we keep the fields klone cares about.
+type Repo struct {
+	slug        string
+	owner       string
+	description string
+	language    string
+	httpsURL    string
+	sshURL      string
+	forkedFrom  *Repo
+	server      *GitServer
+}
+
+// SetImplementation accepts a Bitbucket Cloud or Bitbucket Server repository
+func (r *Repo) SetImplementation(impl interface{}) {
+	var n *Repo
+	switch i := impl.(type) {
+	case *cloudRepository:
+		n = i.toRepo(nil)
+	case *serverRepository:
+		n = i.toRepo(nil)
+	default:
+		return
+	}
+	*r = *n
+}
+
+// GitRemoteUrl is the url we use for remotes, over SSH unless we are using HTTPS for the host
+func (r *Repo) GitRemoteUrl() string {
+	return provider.RemoteUrl(r.sshURL, r.httpsURL)
+}
+
+// GitCloneUrl is the url we clone with, Bitbucket has no git:// protocol so we clone over HTTPS
+func (r *Repo) GitCloneUrl() string {
+	return r.httpsURL
+}
+
+func (r *Repo) HttpsCloneUrl() string {
+	return r.httpsURL
+}
+
+func (r *Repo) Language() string {
+	return r.language
+}
+
+// Owner is the workspace (Cloud) or project key (Server) the repository lives in
+func (r *Repo) Owner() string {
+	return r.owner
+}
+
+func (r *Repo) Name() string {
+	return r.slug
+}
+
+func (r *Repo) Description() string {
+	return r.description
+}
+
+func (r *Repo) ForkedFrom() provider.Repo {
+	if r.forkedFrom == nil {
+		return nil
+	}
+	return r.forkedFrom
+}
+
+// GetKlonefile will fetch the Klonefile of the repository, if it has one
+func (r *Repo) GetKlonefile() []byte {
+	if r.server == nil {
+		return []byte("")
+	}
+	content, err := r.server.klonefile(r.owner, r.slug)
+	if err != nil {
+		if !strings.Contains(err.Error(), "404") {
+			local.Printf("Unable to fetch %s for [%s/%s]: %v", klonefile.Filename, r.owner, r.slug, err)
+		}
+		return []byte("")
+	}
+	return content
+}
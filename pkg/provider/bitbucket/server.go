@@ -0,0 +1,151 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// server.go holds the Bitbucket Server (/rest/api/1.0) half of the provider
+
+package bitbucket
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+	"strings"
+)
+
+type serverUser struct {
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	EmailAddress string `json:"emailAddress"`
+}
+
+type serverRepository struct {
+	Slug        string            `json:"slug"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Origin      *serverRepository `json:"origin"`
+	Project     struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	Links struct {
+		Clone []link `json:"clone"`
+	} `json:"links"`
+}
+
+type serverRepositories struct {
+	Values        []*serverRepository `json:"values"`
+	IsLastPage    bool                `json:"isLastPage"`
+	NextPageStart int                 `json:"nextPageStart"`
+}
+
+// toRepo will convert a Bitbucket Server repository into a klone Repo
+// Bitbucket Server does not detect languages, so we never have one
+func (b *serverRepository) toRepo(s *GitServer) *Repo {
+	r := &Repo{
+		slug:        b.Slug,
+		owner:       b.Project.Key,
+		description: b.Description,
+		server:      s,
+	}
+	for _, l := range b.Links.Clone {
+		switch l.Name {
+		case "http":
+			r.httpsURL = stripUserInfo(l.Href)
+		case "ssh":
+			r.sshURL = l.Href
+		}
+	}
+	if b.Origin != nil {
+		r.forkedFrom = b.Origin.toRepo(s)
+	}
+	return r
+}
+
+// personalProject is the project key Bitbucket Server uses for a user's own repositories
+func personalProject(slug string) string {
+	return fmt.Sprintf("~%s", strings.ToUpper(slug))
+}
+
+func (s *GitServer) serverAuthenticate() error {
+	usr := &serverUser{}
+	err := s.do("GET", fmt.Sprintf("/rest/api/1.0/users/%s", s.username), nil, usr)
+	if err != nil {
+		return err
+	}
+	s.owner = personalProject(usr.Slug)
+	s.email = usr.EmailAddress
+	return nil
+}
+
+func (s *GitServer) serverGetRepo(owner, name string) (*Repo, error) {
+	b := &serverRepository{}
+	err := s.do("GET", fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", owner, name), nil, b)
+	if err != nil {
+		return nil, err
+	}
+	return b.toRepo(s), nil
+}
+
+func (s *GitServer) serverListRepos() ([]*Repo, error) {
+	var repos []*Repo
+	start := 0
+	for {
+		page := &serverRepositories{}
+		err := s.do("GET", fmt.Sprintf("/rest/api/1.0/projects/%s/repos?limit=100&start=%d", s.OwnerName(), start), nil, page)
+		if err != nil {
+			return repos, err
+		}
+		for _, b := range page.Values {
+			repos = append(repos, b.toRepo(s))
+		}
+		if page.IsLastPage {
+			break
+		}
+		start = page.NextPageStart
+	}
+	return repos, nil
+}
+
+func (s *GitServer) serverFork(parent provider.Repo, newOwner string) (*Repo, error) {
+	body := map[string]interface{}{
+		"slug": parent.Name(),
+		"project": map[string]string{
+			"key": newOwner,
+		},
+	}
+	b := &serverRepository{}
+	err := s.do("POST", fmt.Sprintf("/rest/api/1.0/projects/%s/repos/%s", parent.Owner(), parent.Name()), body, b)
+	if err != nil {
+		return nil, err
+	}
+	return b.toRepo(s), nil
+}
+
+func (s *GitServer) serverNewRepo(name, desc string) (*Repo, error) {
+	body := map[string]interface{}{
+		"name":        name,
+		"scmId":       "git",
+		"description": desc,
+	}
+	b := &serverRepository{}
+	err := s.do("POST", fmt.Sprintf("/rest/api/1.0/projects/%s/repos", s.OwnerName()), body, b)
+	if err != nil {
+		return nil, err
+	}
+	return b.toRepo(s), nil
+}
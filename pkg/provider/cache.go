@@ -0,0 +1,172 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// cache.go is how we encrypt the access tokens we cache between klones, with age (https://age-encryption.org)
+
+package provider
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"golang.org/x/crypto/ssh/terminal"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ScryptWorkFactor is how hard it is to derive the key for a cache encrypted with a passphrase (2^18)
+var ScryptWorkFactor = 18
+
+// CredentialPassphrase will return the passphrase for our caches, from $KLONE_CREDENTIALPASSPHRASE
+// or by asking for it. We only ask when a cache was encrypted with a passphrase.
+var CredentialPassphrase = func() ([]byte, error) {
+	if pass := os.Getenv("KLONE_CREDENTIALPASSPHRASE"); pass != "" {
+		return []byte(pass), nil
+	}
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return nil, fmt.Errorf("no passphrase for access token cache ( KLONE_CREDENTIALPASSPHRASE )")
+	}
+	local.PrintPrompt("Passphrase for access token cache: ")
+	pass, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	return pass, err
+}
+
+// EncryptedFile is an access token cache, as an age file. With $KLONE_CREDENTIALPASSPHRASE we
+// encrypt with a key derived from the passphrase. Otherwise we encrypt with an age identity we
+// keep next to the cache, which only keeps the token out of plain sight. Anybody who can read
+// the cache can read the identity, so all that protects it is that both files are 0600.
+type EncryptedFile struct {
+	// Path is the cache
+	Path string
+
+	// IdentityPath is the age identity we encrypt with without a passphrase
+	IdentityPath string
+}
+
+// Read will decrypt the cache. A cache that is not an age file (from an older klone) is
+// returned as it is, and encrypted is false. An empty content means we have no cache.
+func (e *EncryptedFile) Read() (content []byte, encrypted bool, err error) {
+	content = local.BGetContent(e.Path)
+	if !IsEncrypted(content) {
+		return bytes.TrimSpace(content), false, nil
+	}
+	var identities []age.Identity
+	if i, err := e.identity(false); err != nil {
+		return nil, true, err
+	} else if i != nil {
+		identities = append(identities, i)
+	}
+	if bytes.Contains(content, []byte("\n-> scrypt ")) {
+		pass, err := CredentialPassphrase()
+		if err != nil {
+			return nil, true, err
+		}
+		i, err := age.NewScryptIdentity(string(pass))
+		if err != nil {
+			return nil, true, err
+		}
+		identities = append(identities, i)
+	}
+	if len(identities) == 0 {
+		return nil, true, fmt.Errorf("unable to decrypt access token cache [%s]: no identity [%s]", e.Path, e.IdentityPath)
+	}
+	r, err := age.Decrypt(bytes.NewReader(content), identities...)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decrypt access token cache [%s]: %v", e.Path, err)
+	}
+	content, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to decrypt access token cache [%s]: %v", e.Path, err)
+	}
+	return content, true, nil
+}
+
+// Write will encrypt content into the cache, only we can read it (0600)
+func (e *EncryptedFile) Write(content string) error {
+	var r age.Recipient
+	if pass := os.Getenv("KLONE_CREDENTIALPASSPHRASE"); pass != "" {
+		sr, err := age.NewScryptRecipient(pass)
+		if err != nil {
+			return err
+		}
+		sr.SetWorkFactor(ScryptWorkFactor)
+		r = sr
+	} else {
+		i, err := e.identity(true)
+		if err != nil {
+			return err
+		}
+		local.Printf("Access token cache [%s] is only as private as [%s] ( KLONE_CREDENTIALPASSPHRASE to encrypt it with a passphrase )", e.Path, e.IdentityPath)
+		r = i.Recipient()
+	}
+	b := &bytes.Buffer{}
+	w, err := age.Encrypt(b, r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return local.SPutPrivateContent(b.String(), e.Path)
+}
+
+// identity will load the age identity for the cache, and create it if we have none
+// and are asked to. We write it like age-keygen, so age -d -i can read the cache.
+func (e *EncryptedFile) identity(create bool) (*age.X25519Identity, error) {
+	for _, line := range strings.Split(local.SGetContent(e.IdentityPath), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i, err := age.ParseX25519Identity(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity [%s]: %v", e.IdentityPath, err)
+		}
+		return i, nil
+	}
+	if !create {
+		return nil, nil
+	}
+	i, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), i.Recipient(), i)
+	err = local.SPutPrivateContent(content, e.IdentityPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write identity [%s]: %v", e.IdentityPath, err)
+	}
+	return i, nil
+}
+
+// IsEncrypted is true if content is an age file
+func IsEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, []byte("age-encryption.org/v1\n"))
+}
@@ -0,0 +1,292 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// gitea.go is a representation of a self-hosted Gitea (or Forgejo) instance as a git server
+
+package gitea
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"net/http"
+)
+
+var (
+	CacheDir           = fmt.Sprintf("%s/.klone/gitea", local.Home())
+	RefreshCredentials = false
+	Testing            = false
+)
+
+const (
+	apiPath = "/api/v1"
+)
+
+// GitServer is a representation of a Gitea instance
+type GitServer struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	usr     *user
+	repos   map[string]provider.Repo
+}
+
+type user struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	Email string `json:"email"`
+}
+
+type repository struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	FullName    string      `json:"full_name"`
+	Description string      `json:"description"`
+	Owner       *user       `json:"owner"`
+	Fork        bool        `json:"fork"`
+	Parent      *repository `json:"parent"`
+	CloneURL    string      `json:"clone_url"`
+	SSHURL      string      `json:"ssh_url"`
+}
+
+// file is a file in a repository, as the Gitea contents API returns it
+type file struct {
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+// ServerBaseURL will return the base URL of the Gitea instance defined
+// with $KLONE_GITEAURL if it is the instance for the server string
+func ServerBaseURL(server string) (string, bool) {
+	if host, ok := provider.CustomHost("KLONE_GITEAURL"); !ok || host != server {
+		return "", false
+	}
+	return provider.CustomBaseURL("KLONE_GITEAURL")
+}
+
+// Hosts are the server strings of every Gitea instance we know about
+func Hosts() []string {
+	var hosts []string
+	if host, ok := provider.CustomHost("KLONE_GITEAURL"); ok {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// GetServerString returns the host of the Gitea instance, which is the string
+// we would want to use in things like $GOPATH
+func (s *GitServer) GetServerString() string {
+	return provider.Host(s.baseURL)
+}
+
+func (s *GitServer) OwnerName() string {
+	return s.usr.Login
+}
+
+// HTTPSCredentials is our user and access token, which Gitea takes for git over HTTPS
+func (s *GitServer) HTTPSCredentials() (string, string) {
+	return s.OwnerName(), s.token
+}
+
+func (s *GitServer) OwnerEmail() string {
+	return s.usr.Email
+}
+
+// Authenticate will look for an access token with the following hierarchy.
+// 1. Access token from env var
+// 2. Access token from the local cache for this host
+// 3. Prompt for an access token (and cache it)
+func (s *GitServer) Authenticate() error {
+	if s.baseURL == "" {
+		return fmt.Errorf("no Gitea base URL defined, please set $KLONE_GITEAURL")
+	}
+	_, token, err := s.tokenSource().Token()
+	if err != nil {
+		return err
+	}
+	RefreshCredentials = false
+	s.token = token
+	usr := &user{}
+	err = s.do("GET", "/user", nil, usr)
+	if err != nil {
+		return err
+	}
+	s.usr = usr
+	local.Printf("Successfully authenticated [%s] with [%s]", usr.Login, s.GetServerString())
+	return nil
+}
+
+// GetRepoByOwner is the most effecient way to look up a repository exactly by it's name and owner
+func (s *GitServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
+	r := &Repo{assumedOwner: owner, server: s}
+	g := &repository{}
+	err := s.do("GET", fmt.Sprintf("/repos/%s/%s", owner, name), nil, g)
+	if err != nil {
+		local.Printf("Unable to find repo [%s/%s]", owner, name)
+		return r, err
+	}
+	return s.newRepo(g), nil
+}
+
+// GetRepo is the most effecient way to look up a repository exactly by it's name and assumed owner (you)
+func (s *GitServer) GetRepo(name string) (provider.Repo, error) {
+	return s.GetRepoByOwner(s.OwnerName(), name)
+}
+
+// GetRepos will return (and cache) a hash map of repositories by name
+func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
+	providerRepos := make(map[string]provider.Repo)
+	if len(s.repos) == 0 {
+		for page := 1; ; page++ {
+			var repos []*repository
+			err := s.do("GET", fmt.Sprintf("/user/repos?limit=50&page=%d", page), nil, &repos)
+			if err != nil {
+				return providerRepos, err
+			}
+			if len(repos) == 0 {
+				break
+			}
+			for _, g := range repos {
+				providerRepos[g.Name] = s.newRepo(g)
+			}
+		}
+		s.repos = providerRepos
+	}
+	local.Printf("Cached %d repositories in memory", len(s.repos))
+	return s.repos, nil
+}
+
+// Fork will fork a repository to newOwner. If newOwner is not us, it is
+// assumed to be an organization we can create repositories in.
+func (s *GitServer) Fork(parent provider.Repo, newOwner string) (provider.Repo, error) {
+	body := map[string]string{}
+	if newOwner != s.OwnerName() {
+		body["organization"] = newOwner
+	}
+	g := &repository{}
+	err := s.do("POST", fmt.Sprintf("/repos/%s/%s/forks", parent.Owner(), parent.Name()), body, g)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fork repository [%s]: %v", parent.Name(), err)
+	}
+	return s.newRepo(g), nil
+}
+
+func (s *GitServer) NewRepo(name, desc string) (provider.Repo, error) {
+	body := map[string]interface{}{
+		"name":        name,
+		"description": desc,
+		"auto_init":   true,
+	}
+	g := &repository{}
+	err := s.do("POST", "/user/repos", body, g)
+	if err != nil {
+		return nil, err
+	}
+	return s.newRepo(g), nil
+}
+
+func (s *GitServer) DeleteRepoByOwner(owner, name string) (bool, error) {
+	err := s.do("DELETE", fmt.Sprintf("/repos/%s/%s", owner, name), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GitServer) DeleteRepo(name string) (bool, error) {
+	return s.DeleteRepoByOwner(s.OwnerName(), name)
+}
+
+// PullRequestRef is where Gitea keeps the head of a pull request
+func (s *GitServer) PullRequestRef(number int) (string, error) {
+	return fmt.Sprintf("refs/pull/%d/head", number), nil
+}
+
+// newRepo will wrap a Gitea repository (and it's parent) as a klone Repo
+func (s *GitServer) newRepo(g *repository) *Repo {
+	r := &Repo{impl: g, server: s}
+	if g.Fork && g.Parent != nil {
+		r.forkedFrom = &Repo{impl: g.Parent, server: s}
+	}
+	return r
+}
+
+// klonefile will fetch the Klonefile from the default branch of a repository
+func (s *GitServer) klonefile(owner, name string) ([]byte, error) {
+	f := &file{}
+	err := s.do("GET", fmt.Sprintf("/repos/%s/%s/contents/%s", owner, name, klonefile.Filename), nil, f)
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != "file" {
+		return nil, fmt.Errorf("%s is not a file", klonefile.Filename)
+	}
+	if f.Encoding != "base64" {
+		return []byte(f.Content), nil
+	}
+	return base64.StdEncoding.DecodeString(f.Content)
+}
+
+// language will return the language Gitea detected the most bytes of in a repository
+func (s *GitServer) language(owner, name string) string {
+	langs := make(map[string]int64)
+	err := s.do("GET", fmt.Sprintf("/repos/%s/%s/languages", owner, name), nil, &langs)
+	if err != nil {
+		local.RecoverableErrorf("Unable to detect language: %v", err)
+		return ""
+	}
+	var lang string
+	var max int64
+	for l, size := range langs {
+		if size > max {
+			lang = l
+			max = size
+		}
+	}
+	return lang
+}
+
+// do will send a request to the Gitea v1 API and decode the response into v
+func (s *GitServer) do(method, path string, body, v interface{}) error {
+	api := &provider.API{
+		BaseURL: s.baseURL + apiPath,
+		Client:  s.client,
+		Authorize: func(req *http.Request) {
+			req.Header.Set("Authorization", fmt.Sprintf("token %s", s.token))
+		},
+	}
+	return api.Do(method, path, body, v)
+}
+
+// tokenSource is where we find the access token for this Gitea host
+func (s *GitServer) tokenSource() *provider.TokenSource {
+	return &provider.TokenSource{
+		Name:        "Gitea",
+		Host:        s.GetServerString(),
+		CacheDir:    CacheDir,
+		TokenEnv:    "KLONE_GITEATOKEN",
+		TokenPrompt: "Access Token",
+		Refresh:     RefreshCredentials,
+		Testing:     Testing,
+	}
+}
@@ -0,0 +1,144 @@
+package gitea
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func fakeRepo(owner, name string, parent *repository) *repository {
+	return &repository{
+		Name:     name,
+		FullName: fmt.Sprintf("%s/%s", owner, name),
+		Owner:    &user{Login: owner},
+		Fork:     parent != nil,
+		Parent:   parent,
+		CloneURL: fmt.Sprintf("https://git.example.internal/%s/%s.git", owner, name),
+		SSHURL:   fmt.Sprintf("git@git.example.internal:%s/%s.git", owner, name),
+	}
+}
+
+func newTestServer(t *testing.T) (*GitServer, *map[string]string, func()) {
+	forkBody := make(map[string]string)
+	repos := map[string]*repository{
+		"team/service": fakeRepo("team", "service", nil),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		path := strings.TrimPrefix(r.URL.Path, apiPath)
+		switch {
+		case path == "/user":
+			json.NewEncoder(w).Encode(&user{Login: "alice", Email: "alice@example.com"})
+		case path == "/repos/team/service/languages":
+			json.NewEncoder(w).Encode(map[string]int64{"Go": 52000, "Makefile": 800})
+		case path == "/repos/team/service/contents/.Klonefile":
+			json.NewEncoder(w).Encode(&file{Type: "file", Content: base64.StdEncoding.EncodeToString([]byte("submodules: none\n")), Encoding: "base64"})
+		case path == "/repos/team/service/forks" && r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&forkBody)
+			owner := "alice"
+			if forkBody["organization"] != "" {
+				owner = forkBody["organization"]
+			}
+			fork := fakeRepo(owner, "service", repos["team/service"])
+			repos[fmt.Sprintf("%s/service", owner)] = fork
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(fork)
+		case strings.HasPrefix(path, "/repos/") && r.Method == "GET":
+			g, ok := repos[strings.TrimPrefix(path, "/repos/")]
+			if !ok {
+				http.Error(w, "Not Found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(g)
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}))
+	os.Setenv("KLONE_GITEATOKEN", "secret")
+	Testing = true
+	s := &GitServer{baseURL: ts.URL}
+	err := s.Authenticate()
+	if err != nil {
+		ts.Close()
+		t.Fatalf("Unable to auth: %v", err)
+	}
+	return s, &forkBody, ts.Close
+}
+
+func TestServerBaseURL(t *testing.T) {
+	os.Setenv("KLONE_GITEAURL", "https://git.example.internal/")
+	defer os.Unsetenv("KLONE_GITEAURL")
+	baseURL, ok := ServerBaseURL("git.example.internal")
+	if !ok || baseURL != "https://git.example.internal" {
+		t.Fatalf("Unable to match Gitea server: %s", baseURL)
+	}
+	_, ok = ServerBaseURL("github.com")
+	if ok {
+		t.Fatal("Matched a server that is not Gitea")
+	}
+}
+
+func TestGetRepoByOwnerAndFork(t *testing.T) {
+	s, forkBody, done := newTestServer(t)
+	defer done()
+	if s.OwnerName() != "alice" {
+		t.Fatalf("Unexpected owner: %s", s.OwnerName())
+	}
+	repo, err := s.GetRepoByOwner("team", "service")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if repo.Owner() != "team" || repo.Name() != "service" || repo.ForkedFrom() != nil {
+		t.Fatalf("Unexpected repo: %s/%s", repo.Owner(), repo.Name())
+	}
+	if repo.Language() != "Go" {
+		t.Fatalf("Unexpected language: %s", repo.Language())
+	}
+	_, err = s.GetRepo("service")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("Expected 404 before fork: %v", err)
+	}
+	fork, err := s.Fork(repo, s.OwnerName())
+	if err != nil {
+		t.Fatalf("Unable to fork: %v", err)
+	}
+	if _, ok := (*forkBody)["organization"]; ok {
+		t.Fatalf("Forking to ourselves should not set an organization: %v", *forkBody)
+	}
+	if fork.Owner() != "alice" || fork.ForkedFrom() == nil || fork.ForkedFrom().Owner() != "team" {
+		t.Fatalf("Unexpected fork: %s/%s", fork.Owner(), fork.Name())
+	}
+	if fork.GitRemoteUrl() != "git@git.example.internal:alice/service.git" {
+		t.Fatalf("Unexpected remote url: %s", fork.GitRemoteUrl())
+	}
+	if fork.ForkedFrom().GitRemoteUrl() != "git@git.example.internal:team/service.git" {
+		t.Fatalf("Unexpected upstream url: %s", fork.ForkedFrom().GitRemoteUrl())
+	}
+}
+
+func TestGetKlonefile(t *testing.T) {
+	s, _, done := newTestServer(t)
+	defer done()
+	repo, err := s.GetRepoByOwner("team", "service")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if content := string(repo.GetKlonefile()); content != "submodules: none\n" {
+		t.Fatalf("Unexpected Klonefile: %s", content)
+	}
+	fork, err := s.Fork(repo, s.OwnerName())
+	if err != nil {
+		t.Fatalf("Unable to fork: %v", err)
+	}
+	if content := fork.GetKlonefile(); len(content) != 0 {
+		t.Fatalf("Unexpected Klonefile: %s", content)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// credentials.go is where we keep GitHub access tokens between klones, with git's credential
+// helpers, in the keyring of the OS, or in our own cache
+
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	CredentialStoreAuto    = "auto"    // git if git has a credential helper, then keyring, then klone
+	CredentialStoreGit     = "git"     // git credential fill/approve/reject
+	CredentialStoreKeyring = "keyring" // The keyring of the OS (macOS keychain, or libsecret)
+	CredentialStoreKlone   = "klone"   // Our own cache in ~/.klone/auth
+)
+
+// Credential is an access token for a host
+type Credential struct {
+	Host     string
+	Username string
+	Token    string
+}
+
+// CredentialStore keeps access tokens between klones. Get returns a nil Credential
+// if we have nothing for a host.
+type CredentialStore interface {
+	Get(host string) (*Credential, error)
+	Store(c *Credential) error
+	Erase(c *Credential) error
+}
+
+// credentialStore is the store we have decided on, tests may set their own
+var credentialStore CredentialStore
+
+// Credentials is the store we keep access tokens in, from $KLONE_GITHUBCREDENTIALS
+func Credentials() (CredentialStore, error) {
+	if credentialStore != nil {
+		return credentialStore, nil
+	}
+	name := os.Getenv("KLONE_GITHUBCREDENTIALS")
+	switch name {
+	case CredentialStoreKlone:
+		credentialStore = &FileCredentialStore{}
+	case CredentialStoreGit:
+		credentialStore = &GitCredentialStore{}
+	case CredentialStoreKeyring:
+		k := &KeyringCredentialStore{}
+		if !k.Available() {
+			return nil, fmt.Errorf("unable to find a keyring [%s] ( KLONE_GITHUBCREDENTIALS=%s )", k.command(), CredentialStoreKlone)
+		}
+		credentialStore = k
+	case CredentialStoreAuto, "":
+		g := &GitCredentialStore{}
+		k := &KeyringCredentialStore{}
+		if g.HasHelper() {
+			credentialStore = g
+		} else if k.Available() {
+			credentialStore = k
+		} else {
+			credentialStore = &FileCredentialStore{}
+		}
+	default:
+		return nil, fmt.Errorf("unknown credential store [%s] ( %s, %s, %s, %s )", name, CredentialStoreAuto, CredentialStoreGit, CredentialStoreKeyring, CredentialStoreKlone)
+	}
+	return credentialStore, nil
+}
+
+// FileCredentialStore is our own cache, one age (https://age-encryption.org) file per host
+// (see Host.CachePath), encrypted with the identity in ~/.klone/auth.key or with
+// $KLONE_CREDENTIALPASSPHRASE (see provider.EncryptedFile). Plaintext caches from an older
+// klone are rewritten the first time we read them, unless we are only planning a klone.
+type FileCredentialStore struct{}
+
+func (f *FileCredentialStore) path(host string) string {
+	return (&Host{Name: host}).CachePath()
+}
+
+// file is the encrypted cache for a host, every host shares the identity next to Cache
+func (f *FileCredentialStore) file(host string) *provider.EncryptedFile {
+	return &provider.EncryptedFile{
+		Path:         f.path(host),
+		IdentityPath: fmt.Sprintf("%s.key", Cache),
+	}
+}
+
+func (f *FileCredentialStore) Get(host string) (*Credential, error) {
+	content, encrypted, err := f.file(host).Read()
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+	c := &Credential{Host: host, Token: string(content)}
+	if encrypted || !provider.KeepCredentials {
+		return c, nil
+	}
+	path := f.path(host)
+	err = f.Store(c)
+	if err != nil {
+		local.RecoverableErrorf("Unable to rewrite access token cache [%s]: %v", path, err)
+	} else {
+		local.Printf("Rewrote plaintext access token cache [%s]", path)
+	}
+	return c, nil
+}
+
+func (f *FileCredentialStore) Store(c *Credential) error {
+	os.MkdirAll(filepath.Dir(f.path(c.Host)), 0700)
+	return f.file(c.Host).Write(c.Token)
+}
+
+func (f *FileCredentialStore) Erase(c *Credential) error {
+	err := os.Remove(f.path(c.Host))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// GitCredentialStore uses git credential, so we share access tokens with git and
+// whatever credential helper it has (osxkeychain, libsecret, store, ...)
+type GitCredentialStore struct {
+	Command string   // git, by default
+	Args    []string // Before credential, E.G. -c credential.helper=...
+}
+
+// HasHelper is true if git has a credential helper, without one git would only prompt us
+func (g *GitCredentialStore) HasHelper() bool {
+	args := append(append([]string{}, g.Args...), "config", "--get-all", "credential.helper")
+	out, err := g.command(args...).Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// Get will ask git to fill a credential, git will never prompt us for one
+func (g *GitCredentialStore) Get(host string) (*Credential, error) {
+	out, err := g.credential("fill", &Credential{Host: host})
+	if _, ok := err.(*exec.ExitError); ok {
+		// No helper has a credential, and git was unable to prompt for one
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Credential{Host: host}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "username":
+			c.Username = parts[1]
+		case "password":
+			c.Token = parts[1]
+		}
+	}
+	if c.Token == "" {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// Store will approve a credential, so git's helpers remember it
+func (g *GitCredentialStore) Store(c *Credential) error {
+	_, err := g.credential("approve", c)
+	return err
+}
+
+// Erase will reject a credential, so git's helpers forget it
+func (g *GitCredentialStore) Erase(c *Credential) error {
+	_, err := g.credential("reject", c)
+	return err
+}
+
+func (g *GitCredentialStore) credential(action string, c *Credential) ([]byte, error) {
+	args := append(append([]string{}, g.Args...), "-c", "credential.interactive=never", "credential", action)
+	cmd := g.command(args...)
+	input := fmt.Sprintf("protocol=https\nhost=%s\n", c.Host)
+	if c.Username != "" {
+		input = fmt.Sprintf("%susername=%s\n", input, c.Username)
+	}
+	if c.Token != "" {
+		input = fmt.Sprintf("%spassword=%s\n", input, c.Token)
+	}
+	cmd.Stdin = strings.NewReader(input + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unable to run git credential %s: %v", action, err)
+	}
+	return out, nil
+}
+
+// command is git, and it will never prompt on the terminal (or with askpass)
+func (g *GitCredentialStore) command(args ...string) *exec.Cmd {
+	command := g.Command
+	if command == "" {
+		command = "git"
+	}
+	cmd := exec.Command(command, args...)
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, "GIT_ASKPASS=") || strings.HasPrefix(env, "SSH_ASKPASS=") {
+			continue
+		}
+		cmd.Env = append(cmd.Env, env)
+	}
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
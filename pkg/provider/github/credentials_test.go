@@ -0,0 +1,341 @@
+package github
+
+import (
+	"bytes"
+	"filippo.io/age"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeHelper is a git credential helper that remembers one credential in a file
+const fakeHelper = `#!/bin/sh
+case "$1" in
+get) [ -f "%[1]s" ] && grep -E '^(username|password)=' "%[1]s" ;;
+store) cat > "%[1]s" ;;
+erase) rm -f "%[1]s" ;;
+esac
+exit 0
+`
+
+// newFakeGitStore is a git credential store that only uses our fake helper, and the file it stores in
+func newFakeGitStore(t *testing.T, dir string) (*GitCredentialStore, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	stored := filepath.Join(dir, "stored")
+	helper := filepath.Join(dir, "helper.sh")
+	err := ioutil.WriteFile(helper, []byte(fmt.Sprintf(fakeHelper, stored)), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &GitCredentialStore{Args: []string{"-c", "credential.helper=", "-c", "credential.helper=" + helper}}, stored
+}
+
+func TestGitCredentialStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, stored := newFakeGitStore(t, dir)
+	if !store.HasHelper() {
+		t.Fatal("Unable to find our helper")
+	}
+	if (&GitCredentialStore{Args: []string{"-c", "credential.helper="}}).HasHelper() {
+		t.Fatal("Found a helper that was reset")
+	}
+
+	c, err := store.Get("github.com")
+	if err != nil || c != nil {
+		t.Fatalf("Found a credential before we stored one: %v %v", c, err)
+	}
+	err = store.Store(&Credential{Host: "github.com", Username: "alice", Token: "token"})
+	if err != nil {
+		t.Fatalf("Unable to store credential: %v", err)
+	}
+	content, _ := ioutil.ReadFile(stored)
+	if !strings.Contains(string(content), "host=github.com\n") || !strings.Contains(string(content), "password=token\n") {
+		t.Fatalf("Unexpected credential given to helper: %s", content)
+	}
+	c, err = store.Get("github.com")
+	if err != nil || c == nil {
+		t.Fatalf("Unable to get stored credential: %v", err)
+	}
+	if c.Username != "alice" || c.Token != "token" {
+		t.Fatalf("Unexpected credential: %v", c)
+	}
+	err = store.Erase(c)
+	if err != nil {
+		t.Fatalf("Unable to erase credential: %v", err)
+	}
+	if _, err := os.Stat(stored); !os.IsNotExist(err) {
+		t.Fatal("Helper still has an erased credential")
+	}
+}
+
+func TestAuthenticateCredentialStore(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good-token" && r.Header.Get("Authorization") != "Bearer cached-token" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"login":"alice","email":"alice@corp.example"}`)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "klone-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(cache string) { Cache = cache }(Cache)
+	defer func() { credentialStore = nil }()
+	Cache = filepath.Join(dir, "auth")
+	RefreshCredentials = false
+	host := &Host{Name: "ghe.store.example", BaseURL: fmt.Sprintf("%s/api/v3", ts.URL)}
+	RegisterHost(host)
+	store, stored := newFakeGitStore(t, dir)
+	credentialStore = store
+	authenticate := func() error {
+		delete(creds, host.Name)
+		s := &GitServer{host: host}
+		return s.Authenticate()
+	}
+
+	// A token from the env is approved once it works
+	os.Setenv(host.TokenEnv(), "good-token")
+	err = authenticate()
+	os.Unsetenv(host.TokenEnv())
+	if err != nil {
+		t.Fatalf("Unable to auth with token from env: %v", err)
+	}
+	c, _ := store.Get(host.Name)
+	if c == nil || c.Token != "good-token" || c.Username != "alice" {
+		t.Fatalf("Token was not given to git: %v", c)
+	}
+
+	// And then comes from git
+	err = authenticate()
+	os.Unsetenv(host.TokenEnv())
+	if err != nil {
+		t.Fatalf("Unable to auth with token from git: %v", err)
+	}
+
+	// A token that no longer works is rejected
+	store.Store(&Credential{Host: host.Name, Username: "alice", Token: "revoked-token"})
+	err = authenticate()
+	os.Unsetenv(host.TokenEnv())
+	if err == nil {
+		t.Fatal("Able to auth with a revoked token")
+	}
+	if _, err := os.Stat(stored); !os.IsNotExist(err) {
+		t.Fatal("Revoked token was not erased")
+	}
+
+	// Our own cache is moved into git
+	(&FileCredentialStore{}).Store(&Credential{Host: host.Name, Token: "cached-token"})
+	err = authenticate()
+	os.Unsetenv(host.TokenEnv())
+	if err != nil {
+		t.Fatalf("Unable to auth with token from our cache: %v", err)
+	}
+	c, _ = store.Get(host.Name)
+	if c == nil || c.Token != "cached-token" {
+		t.Fatalf("Cached token was not given to git: %v", c)
+	}
+	if _, err := os.Stat(host.CachePath()); !os.IsNotExist(err) {
+		t.Fatal("Cached token was not removed from our cache")
+	}
+
+	// A plan logs in, but never keeps (or erases) a token
+	defer func(keep bool) { provider.KeepCredentials = keep }(provider.KeepCredentials)
+	provider.KeepCredentials = false
+	os.Setenv(host.TokenEnv(), "good-token")
+	err = authenticate()
+	os.Unsetenv(host.TokenEnv())
+	if err != nil {
+		t.Fatalf("Unable to auth with token from env: %v", err)
+	}
+	c, _ = store.Get(host.Name)
+	if c == nil || c.Token != "cached-token" {
+		t.Fatalf("Token was stored while planning: %v", c)
+	}
+	store.Store(&Credential{Host: host.Name, Username: "alice", Token: "revoked-token"})
+	err = authenticate()
+	os.Unsetenv(host.TokenEnv())
+	if err == nil {
+		t.Fatal("Able to auth with a revoked token")
+	}
+	if _, err := os.Stat(stored); err != nil {
+		t.Fatal("Revoked token was erased while planning")
+	}
+}
+
+func TestFileCredentialStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(cache string) { Cache = cache }(Cache)
+	defer os.Unsetenv("KLONE_CREDENTIALPASSPHRASE")
+	Cache = filepath.Join(dir, "auth")
+	store := &FileCredentialStore{}
+
+	// A plaintext cache from an older klone is left alone while we plan
+	err = ioutil.WriteFile(Cache, []byte("plaintext-token\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.KeepCredentials = false
+	c, err := store.Get(PublicHost)
+	provider.KeepCredentials = true
+	if err != nil || c == nil || c.Token != "plaintext-token" {
+		t.Fatalf("Unable to read plaintext cache while planning: %v %v", c, err)
+	}
+	if content, _ := ioutil.ReadFile(Cache); string(content) != "plaintext-token\n" {
+		t.Fatalf("Cache was rewritten while planning: %s", content)
+	}
+	if _, err := os.Stat(Cache + ".key"); !os.IsNotExist(err) {
+		t.Fatal("Created an identity while planning")
+	}
+
+	// And is encrypted the first time we read it otherwise
+	c, err = store.Get(PublicHost)
+	if err != nil || c == nil || c.Token != "plaintext-token" {
+		t.Fatalf("Unable to read plaintext cache: %v %v", c, err)
+	}
+	for _, path := range []string{Cache, Cache + ".key"} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Unable to find [%s]: %v", path, err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("Unexpected permissions for [%s]: %v", path, info.Mode())
+		}
+	}
+	content, _ := ioutil.ReadFile(Cache)
+	if !provider.IsEncrypted(content) || strings.Contains(string(content), "plaintext-token") {
+		t.Fatalf("Cache was not encrypted: %s", content)
+	}
+	key, _ := ioutil.ReadFile(Cache + ".key")
+	lines := strings.Split(strings.TrimSpace(string(key)), "\n")
+	i, err := age.ParseX25519Identity(lines[len(lines)-1])
+	if err != nil {
+		t.Fatalf("Unable to parse identity: %v", err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(content), i)
+	if err != nil {
+		t.Fatalf("Unable to decrypt cache with identity: %v", err)
+	}
+	token, err := ioutil.ReadAll(r)
+	if err != nil || string(token) != "plaintext-token" {
+		t.Fatalf("Unable to decrypt cache with identity: %v", err)
+	}
+	c, err = store.Get(PublicHost)
+	if err != nil || c == nil || c.Token != "plaintext-token" {
+		t.Fatalf("Unable to read encrypted cache: %v %v", c, err)
+	}
+
+	// With a passphrase
+	defer func(f int) { provider.ScryptWorkFactor = f }(provider.ScryptWorkFactor)
+	provider.ScryptWorkFactor = 10
+	os.Setenv("KLONE_CREDENTIALPASSPHRASE", "klone")
+	err = store.Store(&Credential{Host: "ghe.corp.example", Token: "passphrase-token"})
+	if err != nil {
+		t.Fatalf("Unable to store with a passphrase: %v", err)
+	}
+	content, _ = ioutil.ReadFile(Cache + "-ghe.corp.example")
+	if !strings.Contains(string(content), "\n-> scrypt ") {
+		t.Fatalf("Cache was not encrypted with a passphrase: %s", content)
+	}
+	c, err = store.Get("ghe.corp.example")
+	if err != nil || c == nil || c.Token != "passphrase-token" {
+		t.Fatalf("Unable to read cache with a passphrase: %v %v", c, err)
+	}
+	os.Setenv("KLONE_CREDENTIALPASSPHRASE", "clone")
+	if _, err := store.Get("ghe.corp.example"); err == nil {
+		t.Fatal("Able to read cache with the wrong passphrase")
+	}
+
+	err = store.Erase(c)
+	if err != nil {
+		t.Fatalf("Unable to erase: %v", err)
+	}
+	c, err = store.Get("ghe.corp.example")
+	if err != nil || c != nil {
+		t.Fatalf("Found erased token: %v %v", c, err)
+	}
+}
+
+// fakeSecretTool is secret-tool, remembering one secret in a file
+const fakeSecretTool = `#!/bin/sh
+case "$1" in
+lookup) [ -f "%[1]s" ] || exit 1; cat "%[1]s" ;;
+store) echo "$@" > "%[1]s.args"; cat > "%[1]s" ;;
+clear) [ -f "%[1]s" ] || exit 1; rm -f "%[1]s" ;;
+esac
+`
+
+func TestKeyringCredentialStore(t *testing.T) {
+	if runtime.GOOS == "darwin" {
+		t.Skip("Unable to fake the macOS keychain")
+	}
+	dir, err := ioutil.TempDir("", "klone-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(bus string) { os.Setenv("DBUS_SESSION_BUS_ADDRESS", bus) }(os.Getenv("DBUS_SESSION_BUS_ADDRESS"))
+	stored := filepath.Join(dir, "secret")
+	tool := filepath.Join(dir, "secret-tool")
+	err = ioutil.WriteFile(tool, []byte(fmt.Sprintf(fakeSecretTool, stored)), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &KeyringCredentialStore{Command: tool}
+	os.Unsetenv("DBUS_SESSION_BUS_ADDRESS")
+	if store.Available() {
+		t.Fatal("Keyring is available without a session bus")
+	}
+	os.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(dir, "bus"))
+	if !store.Available() {
+		t.Fatal("Keyring is not available")
+	}
+
+	c, err := store.Get("github.com")
+	if err != nil || c != nil {
+		t.Fatalf("Found a token before we stored one: %v %v", c, err)
+	}
+	err = store.Store(&Credential{Host: "github.com", Token: "keyring-token"})
+	if err != nil {
+		t.Fatalf("Unable to store: %v", err)
+	}
+	args, _ := ioutil.ReadFile(stored + ".args")
+	if strings.Contains(string(args), "keyring-token") || !strings.Contains(string(args), "service klone host github.com") {
+		t.Fatalf("Unexpected arguments: %s", args)
+	}
+	c, err = store.Get("github.com")
+	if err != nil || c == nil || c.Token != "keyring-token" {
+		t.Fatalf("Unable to get token: %v %v", c, err)
+	}
+	err = store.Erase(c)
+	if err != nil {
+		t.Fatalf("Unable to erase: %v", err)
+	}
+	if err := store.Erase(c); err != nil {
+		t.Fatalf("Unable to erase twice: %v", err)
+	}
+	c, _ = store.Get("github.com")
+	if c != nil {
+		t.Fatal("Found erased token")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// device.go is the OAuth device authorization flow, how we get a new access token for GitHub
+
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Scopes are the OAuth scopes klone asks for
+var Scopes = []string{"repo", "delete_repo", "user"}
+
+// sleep is how we wait between polls, tests never wait
+var sleep = time.Sleep
+
+// DeviceFlow will authorize klone with the OAuth device flow. We ask GitHub for a code,
+// the user enters it in their browser, and we poll GitHub until they have.
+type DeviceFlow struct {
+	WebURL   string // E.G. https://github.com
+	ClientID string
+	Scopes   []string
+	Client   *http.Client // provider.DefaultClient unless it is set
+}
+
+// DeviceCode is the code the user enters, and the code we poll with
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+	oauthError
+}
+
+// oauthError is how GitHub tells us something went wrong, often with 200 OK
+type oauthError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// deviceToken is every answer to a poll, an access token or an error
+type deviceToken struct {
+	AccessToken string `json:"access_token"`
+	Interval    int    `json:"interval"`
+	oauthError
+}
+
+// RequestCode will ask GitHub for a new device code
+func (d *DeviceFlow) RequestCode() (*DeviceCode, error) {
+	code := &DeviceCode{}
+	err := d.post("/login/device/code", url.Values{
+		"client_id": {d.ClientID},
+		"scope":     {strings.Join(d.Scopes, " ")},
+	}, code)
+	if err != nil {
+		return nil, err
+	}
+	if code.Error != "" {
+		return nil, fmt.Errorf("unable to request device code [%s]: %s", code.Error, code.ErrorDescription)
+	}
+	if code.DeviceCode == "" || code.UserCode == "" {
+		return nil, fmt.Errorf("unable to request device code: empty response")
+	}
+	return code, nil
+}
+
+// PollToken will poll GitHub until the user has entered the code, and return the access token.
+// We wait the interval GitHub asks us to, and slow down when we are told to.
+func (d *DeviceFlow) PollToken(code *DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(code.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 15 * time.Minute
+	}
+	var waited time.Duration
+	for waited < expiresIn {
+		sleep(interval)
+		waited += interval
+		t := &deviceToken{}
+		err := d.post("/login/oauth/access_token", url.Values{
+			"client_id":   {d.ClientID},
+			"device_code": {code.DeviceCode},
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		}, t)
+		if err != nil {
+			return "", err
+		}
+		switch t.Error {
+		case "":
+			if t.AccessToken == "" {
+				return "", fmt.Errorf("unable to authorize: empty access token")
+			}
+			return t.AccessToken, nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			if t.Interval > 0 {
+				interval = time.Duration(t.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		case "expired_token":
+			return "", fmt.Errorf("device code [%s] has expired ( try again )", code.UserCode)
+		case "access_denied":
+			return "", fmt.Errorf("authorization was denied")
+		default:
+			return "", fmt.Errorf("unable to authorize [%s]: %s", t.Error, t.ErrorDescription)
+		}
+	}
+	return "", fmt.Errorf("device code [%s] has expired ( try again )", code.UserCode)
+}
+
+// post will post a form, and decode the response. Errors are in the response, and
+// GitHub will often send them with 200 OK.
+func (d *DeviceFlow) post(path string, form url.Values, v interface{}) error {
+	client := d.Client
+	if client == nil {
+		client = provider.DefaultClient
+	}
+	u := fmt.Sprintf("%s%s", strings.TrimSuffix(d.WebURL, "/"), path)
+	req, err := http.NewRequest("POST", u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to reach [%s]: %v", u, err)
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected response from [%s]: %s", u, resp.Status)
+		}
+		return fmt.Errorf("unable to parse response from [%s]: %v", u, err)
+	}
+	return nil
+}
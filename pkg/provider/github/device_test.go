@@ -0,0 +1,185 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// deviceServer is a stand-in for the OAuth endpoints of GitHub. Every device code answers
+// each poll with the next of it's responses, and keeps answering with the last one.
+type deviceServer struct {
+	*httptest.Server
+	responses map[string][]string
+	polls     map[string]int
+}
+
+func newDeviceServer(t *testing.T, responses map[string][]string) *deviceServer {
+	d := &deviceServer{responses: responses, polls: make(map[string]int)}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Header.Get("Accept") != "application/json" || r.Form.Get("client_id") != "klone-client" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"incorrect_client_credentials","error_description":"bad client"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/login/device/code":
+			if r.Form.Get("scope") != "repo delete_repo user" {
+				t.Errorf("Unexpected scope [%s]", r.Form.Get("scope"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "device",
+				"user_code":        "WDJB-MJHT",
+				"verification_uri": d.URL + "/login/device",
+				"expires_in":       900,
+				"interval":         5,
+			})
+		case "/login/oauth/access_token":
+			if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:device_code" {
+				t.Errorf("Unexpected grant type [%s]", r.Form.Get("grant_type"))
+			}
+			code := r.Form.Get("device_code")
+			responses := d.responses[code]
+			i := d.polls[code]
+			if i >= len(responses) {
+				i = len(responses) - 1
+			}
+			d.polls[code]++
+			fmt.Fprint(w, responses[i])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return d
+}
+
+const (
+	pending  = `{"error":"authorization_pending"}`
+	slowDown = `{"error":"slow_down","interval":10}`
+	granted  = `{"access_token":"device-token","token_type":"bearer","scope":"repo,delete_repo,user"}`
+)
+
+func TestDeviceFlow(t *testing.T) {
+	d := newDeviceServer(t, map[string][]string{
+		"granted": {pending, slowDown, pending, granted},
+		"slow":    {`{"error":"slow_down"}`, granted},
+		"denied":  {pending, `{"error":"access_denied"}`},
+		"expired": {`{"error":"expired_token"}`},
+		"waiting": {pending},
+		"broken":  {`{"error":"unsupported_grant_type","error_description":"bad grant"}`},
+		"empty":   {`{}`},
+	})
+	defer d.Close()
+	var slept []time.Duration
+	defer func(f func(time.Duration)) { sleep = f }(sleep)
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+
+	flow := &DeviceFlow{WebURL: d.URL, ClientID: "klone-client", Scopes: Scopes}
+	code, err := flow.RequestCode()
+	if err != nil {
+		t.Fatalf("Unable to request code: %v", err)
+	}
+	if code.UserCode != "WDJB-MJHT" || code.DeviceCode != "device" || code.Interval != 5 || code.ExpiresIn != 900 {
+		t.Fatalf("Unexpected code: %v", code)
+	}
+	_, err = (&DeviceFlow{WebURL: d.URL, ClientID: "someone-else"}).RequestCode()
+	if err == nil || !strings.Contains(err.Error(), "incorrect_client_credentials") {
+		t.Fatalf("Expected client error, got: %v", err)
+	}
+
+	// Pending, and slowing down to the interval we are given
+	code.DeviceCode = "granted"
+	token, err := flow.PollToken(code)
+	if err != nil || token != "device-token" {
+		t.Fatalf("Unable to poll token: %v", err)
+	}
+	if fmt.Sprint(slept) != "[5s 5s 10s 10s]" {
+		t.Fatalf("Unexpected intervals: %v", slept)
+	}
+
+	// Slowing down by 5 seconds without an interval
+	slept = nil
+	code.DeviceCode = "slow"
+	token, err = flow.PollToken(code)
+	if err != nil || token != "device-token" || fmt.Sprint(slept) != "[5s 10s]" {
+		t.Fatalf("Unexpected slow down [%s] %v: %v", token, slept, err)
+	}
+
+	cases := map[string]string{
+		"denied":  "denied",
+		"expired": "expired",
+		"broken":  "unsupported_grant_type",
+		"empty":   "empty access token",
+	}
+	for deviceCode, expected := range cases {
+		code.DeviceCode = deviceCode
+		_, err := flow.PollToken(code)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected [%s] for [%s], got: %v", expected, deviceCode, err)
+		}
+	}
+
+	// We stop polling once the code expires
+	slept = nil
+	code.DeviceCode = "waiting"
+	code.ExpiresIn = 30
+	_, err = flow.PollToken(code)
+	if err == nil || !strings.Contains(err.Error(), "expired") || len(slept) != 6 {
+		t.Fatalf("Expected to expire after 6 polls, got %d: %v", len(slept), err)
+	}
+}
+
+func TestAuthenticateDeviceFlow(t *testing.T) {
+	d := newDeviceServer(t, map[string][]string{"device": {pending, granted}})
+	defer d.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer device-token" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"login":"alice","email":"alice@corp.example"}`)
+	}))
+	defer api.Close()
+	dir, err := ioutil.TempDir("", "klone-device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(cache string) { Cache = cache }(Cache)
+	defer func(f func(time.Duration)) { sleep = f }(sleep)
+	defer func() { credentialStore = nil }()
+	sleep = func(time.Duration) {}
+	Cache = filepath.Join(dir, "auth")
+	credentialStore = &FileCredentialStore{}
+	RefreshCredentials = false
+
+	host := &Host{Name: "ghe.device.example", BaseURL: api.URL + "/api/v3", WebURL: d.URL}
+	RegisterHost(host)
+	os.Setenv(host.ClientIDEnv(), "klone-client")
+	defer os.Unsetenv(host.ClientIDEnv())
+	defer os.Unsetenv(host.TokenEnv())
+	delete(creds, host.Name)
+	s := &GitServer{host: host}
+	err = s.Authenticate()
+	if err != nil {
+		t.Fatalf("Unable to auth with the device flow: %v", err)
+	}
+	if s.OwnerName() != "alice" || d.polls["device"] != 2 {
+		t.Fatalf("Unexpected owner [%s] after %d polls", s.OwnerName(), d.polls["device"])
+	}
+	c, err := credentialStore.Get(host.Name)
+	if err != nil || c == nil || c.Token != "device-token" {
+		t.Fatalf("Device token was not stored: %v %v", c, err)
+	}
+	if _, password := s.HTTPSCredentials(); password != "device-token" {
+		t.Fatalf("Unexpected HTTPS password [%s]", password)
+	}
+}
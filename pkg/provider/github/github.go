@@ -23,14 +23,15 @@
 package github
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"github.com/google/go-github/github"
+	"github.com/kris-nova/klone/pkg/klonefile"
 	"github.com/kris-nova/klone/pkg/local"
 	"github.com/kris-nova/klone/pkg/provider"
 	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/oauth2"
+	"net/url"
 	"os"
 	"strings"
 	"syscall"
@@ -42,13 +43,9 @@ var (
 	Testing            = false
 )
 
-const (
-	AccessTokenNote = "Access token automatically managed my Klone. More information: https://github.com/kris-nova/klone."
-)
-
-// GitServer is a representation of GitHub.com, by design we never store credentials here in memory
-// Todo (@kris-nova) encrypt these creds please!
+// GitServer is a representation of GitHub.com (or a GitHub Enterprise host), by design we never store credentials here in memory
 type GitServer struct {
+	host     *Host
 	username string
 	client   *github.Client
 	ctx      context.Context
@@ -65,15 +62,29 @@ func (s *GitServer) Fork(parent provider.Repo, newOwner string) (provider.Repo,
 		return nil, fmt.Errorf("unable to fork repository [%s]: %v", parent.Name(), err)
 	}
 	r := &Repo{
-		impl: repo,
+		impl:   repo,
+		server: s,
 	}
 	return r, nil
 }
 
 // GetServerString returns a server string is the string we would want to use in things like $GOPATH
-// In this case we know we are dealing with GitHub.com so we can safely return it.
+// This is GitHub.com unless we are dealing with a GitHub Enterprise host.
 func (s *GitServer) GetServerString() string {
-	return "github.com"
+	return s.getHost().Name
+}
+
+// HTTPSCredentials is our access token, which GitHub takes as the password for git over HTTPS
+func (s *GitServer) HTTPSCredentials() (string, string) {
+	return s.username, os.Getenv(s.getHost().TokenEnv())
+}
+
+// getHost will default to GitHub.com if we were never given a host
+func (s *GitServer) getHost() *Host {
+	if s.host == nil {
+		s.host = &Host{Name: PublicHost}
+	}
+	return s.host
 }
 
 func (s *GitServer) OwnerName() string {
@@ -84,23 +95,21 @@ func (s *GitServer) OwnerEmail() string {
 }
 
 // Authenticate will parse configuration with the following hierarchy.
-// 1. Access token from local cache
-// 2. Access token from env var
-// 3. Username/Password from env var
-// Authenticate will then attempt to log in (prompting for MFA if necessary)
-// Authenticate will then attempt to ensure a unique access token created by klone for future access
-// To ensure a new auth token, simply set the env var and klone will re-cache the new token
+// 1. Access token from env var
+// 2. Access token from our credential store (git credential, the keyring, or ~/.klone/auth)
+// 3. Username/Personal access token from env var
+// 4. A new access token, from the OAuth device flow or a personal access token we ask for
+// A new access token is stored once we have logged in with it, unless we are only planning a klone.
+// To ensure a new auth token, simply set the env var (or --refresh-credentials) and klone will store the new token
 func (s *GitServer) Authenticate() error {
 	credentials, err := s.getCredentials()
 	if err != nil {
 		return err
 	}
-	r := bufio.NewReader(os.Stdin)
 	token := credentials.Token
 	s.ctx = context.Background()
 	var client *github.Client
-	var tp github.BasicAuthTransport
-	if credentials.Token != "" && !RefreshCredentials {
+	if credentials.Token != "" {
 		local.Printf("Auth [token]")
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: token},
@@ -108,44 +117,114 @@ func (s *GitServer) Authenticate() error {
 		tc := oauth2.NewClient(s.ctx, ts)
 		client = github.NewClient(tc)
 	} else {
-		local.Printf("Auth [user/pass]")
-		username := credentials.User
-		password := credentials.Pass
-		tp = github.BasicAuthTransport{
-			Username: strings.TrimSpace(username),
-			Password: strings.TrimSpace(password),
+		local.Printf("Auth [user/token]")
+		tp := github.BasicAuthTransport{
+			Username: strings.TrimSpace(credentials.User),
+			Password: strings.TrimSpace(credentials.Pass),
 		}
 		client = github.NewClient(tp.Client())
 	}
-	s.client = client
-	user, _, err := client.Users.Get(s.ctx, "")
-	s.usr = user
-	// Check if we need 2 factor
-	if _, ok := err.(*github.TwoFactorAuthError); ok {
-		local.PrintPrompt("GitHub Two Factor Auth Code: ")
-		mfa, _ := r.ReadString('\n')
-		tp.OTP = strings.TrimSpace(mfa)
-		user, _, err := client.Users.Get(s.ctx, "")
+	if s.getHost().IsEnterprise() {
+		err = s.setEnterpriseURLs(client)
 		if err != nil {
 			return err
 		}
-		s.usr = user
+	}
+	s.client = client
+	user, _, err := client.Users.Get(s.ctx, "")
+	if e, ok := err.(*github.ErrorResponse); ok && e.Response.StatusCode == 401 && credentials.stored {
+		if !provider.KeepCredentials {
+			delete(creds, s.getHost().Name)
+			return fmt.Errorf("stored access token for [%s] is no longer valid", s.getHost().Name)
+		}
+		s.eraseToken(token)
+		return fmt.Errorf("stored access token for [%s] is no longer valid, and has been erased ( try again )", s.getHost().Name)
 	} else if err != nil {
+		delete(creds, s.getHost().Name)
 		return err
 	}
-	if RefreshCredentials {
-		s.refreshToken()
+	s.usr = user
+	if credentials.store {
+		os.Setenv(s.getHost().TokenEnv(), token)
+	}
+	if credentials.store && provider.KeepCredentials {
+		err := s.storeToken(token)
+		if err != nil {
+			local.RecoverableErrorf("Unable to store access token: %v", err)
+		} else if credentials.migrate {
+			(&FileCredentialStore{}).Erase(&Credential{Host: s.getHost().Name})
+			local.Printf("Moved access token cache for [%s] into the credential store", s.getHost().Name)
+		}
 	}
 	name := *s.usr.Login
 	s.username = name
 	local.Printf("Successfully authenticated [%s]", name)
-	//s.ensureLocalAuthToken(token)
+	return nil
+}
+
+// newToken will get a new access token for a host. With the client ID of an OAuth App we use the
+// device flow, otherwise we ask for a (fine-grained) personal access token.
+func newToken(h *Host) (string, error) {
+	clientID := os.Getenv(h.ClientIDEnv())
+	if clientID == "" {
+		return promptToken(h)
+	}
+	d := &DeviceFlow{WebURL: h.webURL(), ClientID: clientID, Scopes: Scopes}
+	code, err := d.RequestCode()
+	if err != nil {
+		return "", err
+	}
+	local.PrintExclaimf("Enter the code [%s] at %s", code.UserCode, code.VerificationURI)
+	local.Printf("Waiting for authorization...")
+	token, err := d.PollToken(code)
+	if err != nil {
+		return "", err
+	}
+	local.Printf("Authorized klone with [%s]", h.Name)
+	return token, nil
+}
+
+// promptToken will ask for a personal access token. Fine-grained tokens need read and write
+// access to Administration and Contents (to fork, create, and delete repositories).
+func promptToken(h *Host) (string, error) {
+	if !terminal.IsTerminal(int(syscall.Stdin)) {
+		return "", fmt.Errorf("no access token for [%s] ( %s, or %s for the OAuth device flow )", h.Name, h.TokenEnv(), h.ClientIDEnv())
+	}
+	local.Printf("Create a fine-grained personal access token at %s/settings/personal-access-tokens/new", h.webURL())
+	local.PrintPrompt(fmt.Sprintf("GitHub Access Token [%s]: ", h.Name))
+	b, err := terminal.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", fmt.Errorf("empty access token for [%s]", h.Name)
+	}
+	return token, nil
+}
+
+// setEnterpriseURLs will point a client at the API of a GitHub Enterprise host
+func (s *GitServer) setEnterpriseURLs(client *github.Client) error {
+	h := s.getHost()
+	baseURL, err := url.Parse(h.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base url for [%s]: %v", h.Name, err)
+	}
+	client.BaseURL = baseURL
+	if h.UploadURL != "" {
+		uploadURL, err := url.Parse(h.UploadURL)
+		if err != nil {
+			return fmt.Errorf("invalid upload url for [%s]: %v", h.Name, err)
+		}
+		client.UploadURL = uploadURL
+	}
 	return nil
 }
 
 // GetRepoByOwner is the most effecient way to look up a repository exactly by it's name and owner
 func (s *GitServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
-	r := &Repo{}
+	r := &Repo{server: s}
 	repo, _, err := s.client.Repositories.Get(s.ctx, owner, name)
 	if err != nil {
 		local.Printf("Unable to find repo [%s/%s]", owner, name)
@@ -157,7 +236,7 @@ func (s *GitServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
 	r.impl = repo
 	r.assumedOwner = owner
 	if *repo.Fork && repo.Parent.Owner != nil {
-		r.forkedFrom = &Repo{impl: repo.Parent}
+		r.forkedFrom = &Repo{impl: repo.Parent, server: s}
 	}
 	return r, nil
 
@@ -173,13 +252,13 @@ func (s *GitServer) NewRepo(name, desc string) (provider.Repo, error) {
 	if err != nil {
 		return nil, err
 	}
-	r := &Repo{}
+	r := &Repo{server: s}
 	r.impl = repo
 
 	return r, nil
 }
 
-func (s *GitServer) DeleteRepoByOwner(name, owner string) (bool, error) {
+func (s *GitServer) DeleteRepoByOwner(owner, name string) (bool, error) {
 	_, err := s.client.Repositories.Delete(s.ctx, owner, name)
 	if err != nil {
 		return false, err
@@ -197,9 +276,30 @@ func (s *GitServer) DeleteRepo(name string) (bool, error) {
 
 }
 
+// PullRequestRef is where GitHub keeps the head of a pull request
+func (s *GitServer) PullRequestRef(number int) (string, error) {
+	return fmt.Sprintf("refs/pull/%d/head", number), nil
+}
+
+// klonefile will fetch the Klonefile of a repository from the contents API
+func (s *GitServer) klonefile(owner, name string) ([]byte, error) {
+	file, _, _, err := s.client.Repositories.GetContents(s.ctx, owner, name, klonefile.Filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is not a file", klonefile.Filename)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
 // GetRepo is the most effecient way to look up a repository exactly by it's name and assumed owner (you)
 func (s *GitServer) GetRepo(name string) (provider.Repo, error) {
-	r := &Repo{}
+	r := &Repo{server: s}
 	repo, _, err := s.client.Repositories.Get(s.ctx, s.username, name)
 	if err != nil {
 		local.Printf("Unable to find repo [%s/%s]", s.username, name)
@@ -210,7 +310,7 @@ func (s *GitServer) GetRepo(name string) (provider.Repo, error) {
 	}
 	r.impl = repo
 	if *repo.Fork {
-		r.forkedFrom = &Repo{impl: repo.Parent}
+		r.forkedFrom = &Repo{impl: repo.Parent, server: s}
 	}
 	return r, nil
 }
@@ -229,7 +329,7 @@ func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
 				return providerRepos, err
 			}
 			for _, repo := range repos {
-				r := &Repo{impl: repo}
+				r := &Repo{impl: repo, server: s}
 				providerRepos[*r.impl.Name] = r
 				// Here is where we look up forked repo information (if we have it)
 				if *repo.Fork {
@@ -237,7 +337,7 @@ func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
 					if err != nil {
 						return providerRepos, err
 					}
-					parent := &Repo{impl: rr.Parent}
+					parent := &Repo{impl: rr.Parent, server: s}
 					r.forkedFrom = parent
 				}
 			}
@@ -252,119 +352,124 @@ func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
 	return s.repos, nil
 }
 
-func (s *GitServer) refreshToken() {
-	req := github.AuthorizationRequest{}
-	note := AccessTokenNote
-	req.Note = &note
-	var scopes []github.Scope
-	scopes = append(scopes, github.ScopeDeleteRepo)
-	scopes = append(scopes, github.ScopeRepo)
-	scopes = append(scopes, github.ScopeUser)
-	req.Scopes = scopes
-	var auth *github.Authorization
-	auth, resp, err := s.client.Authorizations.Create(s.ctx, &req)
-	if resp.StatusCode == 422 && strings.Contains(err.Error(), "Code:already_exists") {
-		// It already exists let's delete and create a new one
-		auths, _, err := s.client.Authorizations.List(s.ctx, nil)
-		if err != nil {
-			local.RecoverableErrorf("Unable to delete existing auth token [%s]: %v", *auth.ID, err)
-			return
-		}
-		for _, a := range auths {
-			n := *a.Note
-			if n == AccessTokenNote {
-				_, err := s.client.Authorizations.Delete(s.ctx, *a.ID)
-				if err != nil {
-					local.RecoverableErrorf("Unable to delete existing auth token [%s]: %v", *auth.ID, err)
-					return
-				}
-				auth, _, err = s.client.Authorizations.Create(s.ctx, &req)
-				if err != nil {
-					local.RecoverableErrorf("Unable to create new auth token [%s]: %v", *auth.ID, err)
-					return
-				}
-				break
-			}
-		}
-	} else if err != nil {
-		local.RecoverableErrorf("Unable to generate new auth token: %v", err)
-		return
-	}
-	str := *auth.Token
-	err = local.SPutContent(str, Cache)
-	if err != nil {
-		local.RecoverableErrorf("Unable to ensure local auth token: %v", err)
-		return
-	}
-	os.Setenv("KLONE_GITHUBTOKEN", str)
-	RefreshCredentials = false
-	local.Printf("Successfully cached access token!")
-}
-
 // GitHubCredentials are how we log into GitHub.com
 type GitHubCredentials struct {
 	User  string
 	Pass  string
 	Token string
+
+	stored  bool // The token came from our credential store
+	store   bool // The token should go in our credential store, once we know it works
+	migrate bool // The token came from our own cache, and should leave it once it is stored
 }
 
-var creds *GitHubCredentials
+// creds are the credentials we have found for each host
+var creds = make(map[string]*GitHubCredentials)
 
 func (s *GitServer) getCredentials() (*GitHubCredentials, error) {
-	if creds != nil {
-		return creds, nil
+	h := s.getHost()
+	if c, ok := creds[h.Name]; ok {
+		return c, nil
 	}
-	creds = &GitHubCredentials{}
+	c := &GitHubCredentials{}
 	var token string
 	var user string
 	var pass string
 
-	setToken := os.Getenv("KLONE_GITHUBTOKEN")
-	cachedToken := local.SGetContent(Cache)
+	setToken := os.Getenv(h.TokenEnv())
+	cachedToken, migrate, err := storedToken(h)
+	if err != nil {
+		return c, err
+	}
 
 	// We have a token in memory, this always wins
 	if !RefreshCredentials {
 		if setToken != "" {
 			token = setToken
 			if setToken != cachedToken {
-				// Override cache
-				local.RecoverableError("Conflicting tokens, default to token in memory.")
-				os.MkdirAll(fmt.Sprintf("%s/.klone", local.Home()), 0700)
-				local.SPutContent(token, Cache)
+				// Override the store, once we know the token works
+				if cachedToken != "" {
+					local.RecoverableError("Conflicting tokens, default to token in memory.")
+				}
+				c.store = true
 			}
-
 		} else if cachedToken != "" {
-			os.Setenv("KLONE_GITHUBTOKEN", cachedToken)
+			os.Setenv(h.TokenEnv(), cachedToken)
 			token = cachedToken
-		} else if !Testing {
-			// We need a new token
-			RefreshCredentials = true
+			c.stored = true
+			c.store = migrate
+			c.migrate = migrate
 		}
 	}
 
 	if token == "" {
-		r := bufio.NewReader(os.Stdin)
+		// A user and personal access token from env, GitHub no longer takes passwords
 		user = os.Getenv("KLONE_GITHUBUSER")
-		if user == "" || RefreshCredentials {
-			local.PrintPrompt("GitHub Username: ")
-			u, err := r.ReadString('\n')
+		pass = os.Getenv("KLONE_GITHUBPASS")
+		if (user == "" || pass == "" || RefreshCredentials) && !Testing {
+			var err error
+			token, err = newToken(h)
 			if err != nil {
-				return creds, err
+				return c, err
 			}
-			user = strings.TrimSpace(u)
-		}
-		pass = os.Getenv("KLONE_GITHUBPASS")
-		if pass == "" || RefreshCredentials {
-			local.PrintPrompt("GitHub Password: ")
-			bytePassword, _ := terminal.ReadPassword(int(syscall.Stdin))
-			p := string(bytePassword)
-			pass = strings.TrimSpace(p)
+			user, pass = "", ""
+			c.store = true
+			RefreshCredentials = false
 		}
 	}
 
-	creds.Token = token
-	creds.User = user
-	creds.Pass = pass
+	c.Token = token
+	c.User = user
+	c.Pass = pass
+	creds[h.Name] = c
+
+	return c, nil
+}
+
+// storedToken is the access token in our credential store for a host. If git's credential
+// helpers (or the keyring) have nothing, we will still use (and migrate) a token in our own cache.
+func storedToken(h *Host) (string, bool, error) {
+	store, err := Credentials()
+	if err != nil {
+		return "", false, err
+	}
+	c, err := store.Get(h.Name)
+	if err != nil {
+		local.RecoverableErrorf("Unable to read access token for [%s]: %v", h.Name, err)
+	}
+	if c != nil {
+		return c.Token, false, nil
+	}
+	if _, ok := store.(*FileCredentialStore); ok {
+		return "", false, nil
+	}
+	c, _ = (&FileCredentialStore{}).Get(h.Name)
+	if c != nil {
+		return c.Token, true, nil
+	}
+	return "", false, nil
+}
+
+// storeToken will keep an access token that works in our credential store
+func (s *GitServer) storeToken(token string) error {
+	store, err := Credentials()
+	if err != nil {
+		return err
+	}
+	return store.Store(&Credential{Host: s.getHost().Name, Username: *s.usr.Login, Token: token})
+}
 
-	return creds, nil
+// eraseToken will forget an access token that no longer works
+func (s *GitServer) eraseToken(token string) {
+	h := s.getHost()
+	delete(creds, h.Name)
+	os.Unsetenv(h.TokenEnv())
+	store, err := Credentials()
+	if err != nil {
+		return
+	}
+	err = store.Erase(&Credential{Host: h.Name, Token: token})
+	if err != nil {
+		local.RecoverableErrorf("Unable to erase access token for [%s]: %v", h.Name, err)
+	}
 }
@@ -1,20 +1,158 @@
 package github
 
 import (
+	"fmt"
+	"github.com/google/go-github/github"
+	"github.com/kris-nova/klone/pkg/auth"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
+// TestEnvUserPass will authenticate with a user and personal access token from
+// TEST_KLONE_GITHUBUSER and TEST_KLONE_GITHUBPASS, GitHub no longer takes passwords.
 func TestEnvUserPass(t *testing.T) {
-	if os.Getenv("TEST_KLONE_GITHUBUSER") != "" {
-		os.Setenv("KLONE_GITHUBUSER", os.Getenv("TEST_KLONE_GITHUBUSER"))
+	if testing.Short() {
+		t.Skip("Skipping GitHub test in short mode")
 	}
-	if os.Getenv("TEST_KLONE_GITHUBPASS") != "" {
-		os.Setenv("KLONE_GITHUBPASS", os.Getenv("TEST_KLONE_GITHUBPASS"))
+	user, pass := os.Getenv("TEST_KLONE_GITHUBUSER"), os.Getenv("TEST_KLONE_GITHUBPASS")
+	if user == "" || pass == "" {
+		t.Skip("Skipping GitHub test without TEST_KLONE_GITHUBUSER and TEST_KLONE_GITHUBPASS")
 	}
+	os.Setenv("KLONE_GITHUBUSER", user)
+	defer os.Unsetenv("KLONE_GITHUBUSER")
+	os.Setenv("KLONE_GITHUBPASS", pass)
+	defer os.Unsetenv("KLONE_GITHUBPASS")
+	dir, err := ioutil.TempDir("", "klone-auth")
+	if err != nil {
+		t.Fatalf("Unable to create cache dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(cache string) { Cache = cache }(Cache)
+	Cache = filepath.Join(dir, "auth")
+	credentialStore = &FileCredentialStore{}
+	defer func() { credentialStore = nil }()
 	server := GitServer{}
-	err := server.Authenticate()
+	err = server.Authenticate()
 	if err != nil {
 		t.Fatalf("Unable to auth: %v", err)
 	}
 }
+
+func TestLookupHost(t *testing.T) {
+	os.Setenv("KLONE_GITHUBENTERPRISE", "ghe.corp.example, ghe.other.example")
+	defer os.Unsetenv("KLONE_GITHUBENTERPRISE")
+	h, ok := LookupHost("github.com")
+	if !ok || h.IsEnterprise() || h.TokenEnv() != "KLONE_GITHUBTOKEN" || h.CachePath() != Cache {
+		t.Fatalf("Unexpected host for github.com: %v", h)
+	}
+	h, ok = LookupHost("ghe.other.example")
+	if !ok || !h.IsEnterprise() {
+		t.Fatal("Unable to find enterprise host from env")
+	}
+	if h.BaseURL != "https://ghe.other.example/api/v3/" {
+		t.Fatalf("Unexpected base url: %s", h.BaseURL)
+	}
+	if h.TokenEnv() != "KLONE_GITHUBTOKEN_GHE_OTHER_EXAMPLE" {
+		t.Fatalf("Unexpected token env: %s", h.TokenEnv())
+	}
+	if h.CachePath() == Cache {
+		t.Fatal("Enterprise hosts should not share the GitHub.com cache")
+	}
+	_, ok = LookupHost("gitlab.com")
+	if ok {
+		t.Fatal("Found GitHub host for gitlab.com")
+	}
+}
+
+func TestEnterpriseHost(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer enterprise-token" {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/user":
+			fmt.Fprint(w, `{"login":"alice","email":"alice@corp.example"}`)
+		case "/api/v3/repos/org/repo":
+			fmt.Fprint(w, `{"name":"repo","owner":{"login":"org"},"fork":false,"git_url":"git://ghe.corp.example/org/repo.git","clone_url":"https://ghe.corp.example/org/repo.git"}`)
+		default:
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	cache, err := ioutil.TempFile("", "klone-auth")
+	if err != nil {
+		t.Fatalf("Unable to create cache: %v", err)
+	}
+	defer os.Remove(cache.Name())
+	defer os.Remove(fmt.Sprintf("%s-ghe.corp.example", cache.Name()))
+	defer os.Remove(fmt.Sprintf("%s.key", cache.Name()))
+	defer func(cache string, refresh bool) {
+		Cache = cache
+		RefreshCredentials = refresh
+	}(Cache, RefreshCredentials)
+	Cache = cache.Name()
+	credentialStore = &FileCredentialStore{}
+	defer func() { credentialStore = nil }()
+	RefreshCredentials = false
+	RegisterHost(&Host{Name: "ghe.corp.example", BaseURL: fmt.Sprintf("%s/api/v3", ts.URL)})
+	defer func() {
+		delete(enterpriseHosts, "ghe.corp.example")
+		delete(creds, "ghe.corp.example")
+	}()
+	os.Setenv("KLONE_GITHUBTOKEN_GHE_CORP_EXAMPLE", "enterprise-token")
+	defer os.Unsetenv("KLONE_GITHUBTOKEN_GHE_CORP_EXAMPLE")
+	host, ok := LookupHost("ghe.corp.example")
+	if !ok {
+		t.Fatal("Unable to find registered host")
+	}
+	kp := &KloneProvider{Host: host}
+	s, err := kp.NewGitServer()
+	if err != nil {
+		t.Fatalf("Unable to auth: %v", err)
+	}
+	if s.GetServerString() != "ghe.corp.example" || s.OwnerName() != "alice" {
+		t.Fatalf("Unexpected server [%s] owner [%s]", s.GetServerString(), s.OwnerName())
+	}
+	repo, err := s.GetRepoByOwner("org", "repo")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if repo.GitRemoteUrl() != "git@ghe.corp.example:org/repo.git" {
+		t.Fatalf("Unexpected remote url: %s", repo.GitRemoteUrl())
+	}
+	if repo.GitCloneUrl() != "https://ghe.corp.example/org/repo.git" {
+		t.Fatalf("Unexpected clone url: %s", repo.GitCloneUrl())
+	}
+	auth.OptHostTransports = []string{"ghe.corp.example=https"}
+	defer func() { auth.OptHostTransports = nil }()
+	if repo.GitRemoteUrl() != "https://ghe.corp.example/org/repo.git" {
+		t.Fatalf("Unexpected remote url over HTTPS: %s", repo.GitRemoteUrl())
+	}
+	user, password := s.(provider.HTTPSCredentialer).HTTPSCredentials()
+	if user != "alice" || password != "enterprise-token" {
+		t.Fatalf("Unexpected HTTPS credentials [%s] [%s]", user, password)
+	}
+}
+
+// TestCloneUrlHTTPS will test we stop using git:// on GitHub.com when we use HTTPS
+func TestCloneUrlHTTPS(t *testing.T) {
+	r := &Repo{}
+	r.SetImplementation(&github.Repository{
+		GitURL:   github.String("git://github.com/kris-nova/klone.git"),
+		CloneURL: github.String("https://github.com/kris-nova/klone.git"),
+	})
+	if r.GitCloneUrl() != "git://github.com/kris-nova/klone.git" || r.GitRemoteUrl() != "git@github.com:kris-nova/klone.git" {
+		t.Fatalf("Unexpected urls over SSH [%s] [%s]", r.GitCloneUrl(), r.GitRemoteUrl())
+	}
+	auth.OptTransport = auth.TransportHTTPS
+	defer func() { auth.OptTransport = auth.TransportSSH }()
+	if r.GitCloneUrl() != "https://github.com/kris-nova/klone.git" || r.GitRemoteUrl() != "https://github.com/kris-nova/klone.git" {
+		t.Fatalf("Unexpected urls over HTTPS [%s] [%s]", r.GitCloneUrl(), r.GitRemoteUrl())
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// host.go is how we know about GitHub.com and any number of GitHub Enterprise hosts
+
+package github
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	PublicHost = "github.com"
+)
+
+// Host is a GitHub instance we can talk to. GitHub.com has an empty BaseURL
+// and UploadURL, and will use the defaults from the GitHub client. WebURL is
+// https://$name unless it is set.
+type Host struct {
+	Name      string
+	BaseURL   string
+	UploadURL string
+	WebURL    string
+}
+
+// enterpriseHosts are all the GitHub Enterprise hosts we know about by name
+var enterpriseHosts = make(map[string]*Host)
+
+// NewEnterpriseHost will define a GitHub Enterprise host using the API paths
+// GitHub Enterprise Server uses by default
+func NewEnterpriseHost(name string) *Host {
+	return &Host{
+		Name:      name,
+		BaseURL:   fmt.Sprintf("https://%s/api/v3/", name),
+		UploadURL: fmt.Sprintf("https://%s/api/uploads/", name),
+	}
+}
+
+// RegisterHost will register a GitHub Enterprise host so queries for it can be routed
+func RegisterHost(h *Host) {
+	if !strings.HasSuffix(h.BaseURL, "/") {
+		h.BaseURL = fmt.Sprintf("%s/", h.BaseURL)
+	}
+	if h.UploadURL != "" && !strings.HasSuffix(h.UploadURL, "/") {
+		h.UploadURL = fmt.Sprintf("%s/", h.UploadURL)
+	}
+	enterpriseHosts[h.Name] = h
+}
+
+// LookupHost will return the Host for a server string. GitHub.com is always known,
+// and GitHub Enterprise hosts are known if they have been registered or are
+// defined in $KLONE_GITHUBENTERPRISE (a comma separated list of hostnames)
+func LookupHost(server string) (*Host, bool) {
+	if server == PublicHost {
+		return &Host{Name: PublicHost}, true
+	}
+	if h, ok := enterpriseHosts[server]; ok {
+		return h, true
+	}
+	for _, name := range strings.Split(os.Getenv("KLONE_GITHUBENTERPRISE"), ",") {
+		if strings.TrimSpace(name) == server {
+			return NewEnterpriseHost(server), true
+		}
+	}
+	return nil, false
+}
+
+// EnterpriseHosts are the names of every GitHub Enterprise host we know about
+func EnterpriseHosts() []string {
+	var hosts []string
+	for name := range enterpriseHosts {
+		hosts = append(hosts, name)
+	}
+	for _, name := range strings.Split(os.Getenv("KLONE_GITHUBENTERPRISE"), ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := enterpriseHosts[name]; name != "" && !ok {
+			hosts = append(hosts, name)
+		}
+	}
+	return hosts
+}
+
+// IsEnterprise is true for any host that is not GitHub.com
+func (h *Host) IsEnterprise() bool {
+	return h.Name != PublicHost
+}
+
+// CachePath is where we cache the access token for this host
+func (h *Host) CachePath() string {
+	if !h.IsEnterprise() {
+		return Cache
+	}
+	return fmt.Sprintf("%s-%s", Cache, h.Name)
+}
+
+// TokenEnv is the env var we read an access token from for this host
+// E.G. KLONE_GITHUBTOKEN or KLONE_GITHUBTOKEN_GHE_CORP_EXAMPLE
+func (h *Host) TokenEnv() string {
+	return h.env("KLONE_GITHUBTOKEN")
+}
+
+// ClientIDEnv is the env var we read the client ID of an OAuth App from for this host
+// E.G. KLONE_GITHUBCLIENTID or KLONE_GITHUBCLIENTID_GHE_CORP_EXAMPLE
+func (h *Host) ClientIDEnv() string {
+	return h.env("KLONE_GITHUBCLIENTID")
+}
+
+func (h *Host) env(name string) string {
+	if !h.IsEnterprise() {
+		return name
+	}
+	r := strings.NewReplacer(".", "_", "-", "_", ":", "_")
+	return fmt.Sprintf("%s_%s", name, strings.ToUpper(r.Replace(h.Name)))
+}
+
+// webURL is where we send people in a browser, and where the OAuth endpoints are
+func (h *Host) webURL() string {
+	if h.WebURL != "" {
+		return strings.TrimSuffix(h.WebURL, "/")
+	}
+	return fmt.Sprintf("https://%s", h.Name)
+}
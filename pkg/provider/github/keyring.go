@@ -0,0 +1,116 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// keyring.go keeps GitHub access tokens in the keyring of the OS
+
+package github
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service we keep every access token under
+const keyringService = "klone"
+
+// KeyringCredentialStore keeps access tokens in the keyring of the OS, with security on
+// macOS and secret-tool (libsecret) everywhere else
+type KeyringCredentialStore struct {
+	Command string // security or secret-tool, by default
+}
+
+func (k *KeyringCredentialStore) command() string {
+	if k.Command != "" {
+		return k.Command
+	}
+	if runtime.GOOS == "darwin" {
+		return "security"
+	}
+	return "secret-tool"
+}
+
+// Available is true if we have a keyring to talk to
+func (k *KeyringCredentialStore) Available() bool {
+	if _, err := exec.LookPath(k.command()); err != nil {
+		return false
+	}
+	// libsecret needs a session bus to find the keyring
+	return runtime.GOOS == "darwin" || os.Getenv("DBUS_SESSION_BUS_ADDRESS") != ""
+}
+
+func (k *KeyringCredentialStore) Get(host string) (*Credential, error) {
+	var args []string
+	if runtime.GOOS == "darwin" {
+		args = []string{"find-generic-password", "-s", keyringService, "-a", host, "-w"}
+	} else {
+		args = []string{"lookup", "service", keyringService, "host", host}
+	}
+	out, err := k.run("", args...)
+	if _, ok := err.(*exec.ExitError); ok {
+		// The keyring has nothing for this host
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return nil, nil
+	}
+	return &Credential{Host: host, Token: token}, nil
+}
+
+func (k *KeyringCredentialStore) Store(c *Credential) error {
+	var err error
+	if runtime.GOOS == "darwin" {
+		// security -i reads commands from stdin, so the token is never in our arguments
+		_, err = k.run(fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s:%s -X %s\n", keyringService, c.Host, keyringService, c.Host, hex.EncodeToString([]byte(c.Token))), "-i")
+	} else {
+		_, err = k.run(c.Token, "store", fmt.Sprintf("--label=%s:%s", keyringService, c.Host), "service", keyringService, "host", c.Host)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to store access token in keyring: %v", err)
+	}
+	return nil
+}
+
+func (k *KeyringCredentialStore) Erase(c *Credential) error {
+	var err error
+	if runtime.GOOS == "darwin" {
+		_, err = k.run("", "delete-generic-password", "-s", keyringService, "-a", c.Host)
+	} else {
+		_, err = k.run("", "clear", "service", keyringService, "host", c.Host)
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		// Nothing to erase
+		return nil
+	}
+	return err
+}
+
+func (k *KeyringCredentialStore) run(stdin string, args ...string) ([]byte, error) {
+	cmd := exec.Command(k.command(), args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	return cmd.Output()
+}
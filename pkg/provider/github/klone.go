@@ -26,11 +26,17 @@ import (
 	"github.com/kris-nova/klone/pkg/provider"
 )
 
+// KloneProvider is the GitHub provider. Host is the GitHub instance we
+// will talk to, and will default to GitHub.com if nil.
 type KloneProvider struct {
+	Host *Host
 }
 
 func (k *KloneProvider) NewGitServer() (provider.GitServer, error) {
 	srv := &GitServer{}
+	if k != nil {
+		srv.host = k.Host
+	}
 	err := srv.Authenticate()
 	if err != nil {
 		return srv, err
@@ -24,6 +24,8 @@ package github
 
 import (
 	"github.com/google/go-github/github"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
 	"github.com/kris-nova/klone/pkg/provider"
 	"strings"
 )
@@ -33,6 +35,7 @@ type Repo struct {
 	forkedFrom   *Repo
 	assumedOwner string
 	lang         string
+	server       *GitServer
 }
 
 func (r *Repo) SetImplementation(impl interface{}) {
@@ -40,14 +43,21 @@ func (r *Repo) SetImplementation(impl interface{}) {
 	r.impl = gh
 }
 
+// GitRemoteUrl is the url we use for remotes, over SSH unless we are using HTTPS for the host
 func (r *Repo) GitRemoteUrl() string {
 	raw := r.impl.GetGitURL()
 	replc1 := strings.Replace(raw, "://", "@", 1)
 	replc2 := strings.Replace(replc1, "/", ":", 1)
-	return replc2
+	return provider.RemoteUrl(replc2, r.impl.GetCloneURL())
 }
 
+// GitCloneUrl is the git:// url on GitHub.com. GitHub Enterprise hosts
+// rarely serve the git:// protocol, so we clone those over HTTPS (as we
+// do for every host we are using HTTPS for).
 func (r *Repo) GitCloneUrl() string {
+	if !strings.HasPrefix(r.impl.GetGitURL(), "git://github.com/") || provider.UseHTTPS(r.impl.GetCloneURL()) {
+		return r.impl.GetCloneURL()
+	}
 	return r.impl.GetGitURL()
 }
 
@@ -84,6 +94,18 @@ func (r *Repo) ForkedFrom() provider.Repo {
 	}
 	return r.forkedFrom
 }
+
+// GetKlonefile will fetch the Klonefile of the repository, if it has one
 func (r *Repo) GetKlonefile() []byte {
-	return []byte("")
+	if r.server == nil || r.impl == nil {
+		return []byte("")
+	}
+	content, err := r.server.klonefile(r.Owner(), r.Name())
+	if err != nil {
+		if !strings.Contains(err.Error(), "404") {
+			local.Printf("Unable to fetch %s for [%s/%s]: %v", klonefile.Filename, r.Owner(), r.Name(), err)
+		}
+		return []byte("")
+	}
+	return content
 }
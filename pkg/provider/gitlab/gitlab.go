@@ -0,0 +1,352 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// gitlab.go is a representation of a GitLab instance (gitlab.com or self-hosted) as a git server
+
+package gitlab
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	CacheDir           = fmt.Sprintf("%s/.klone/gitlab", local.Home())
+	RefreshCredentials = false
+	Testing            = false
+
+	// ForkPollInterval and ForkTimeout control how long we wait on GitLab
+	// to finish importing a newly created fork
+	ForkPollInterval = time.Second * 1
+	ForkTimeout      = time.Second * 60
+)
+
+const (
+	DefaultBaseURL = "https://gitlab.com"
+	apiPath        = "/api/v4"
+)
+
+// GitServer is a representation of a GitLab instance
+type GitServer struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	usr     *user
+	repos   map[string]provider.Repo
+}
+
+type user struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+type namespace struct {
+	ID       int    `json:"id"`
+	Path     string `json:"path"`
+	FullPath string `json:"full_path"`
+	Kind     string `json:"kind"`
+}
+
+type project struct {
+	ID                int        `json:"id"`
+	Name              string     `json:"name"`
+	Path              string     `json:"path"`
+	PathWithNamespace string     `json:"path_with_namespace"`
+	Description       string     `json:"description"`
+	SSHURLToRepo      string     `json:"ssh_url_to_repo"`
+	HTTPURLToRepo     string     `json:"http_url_to_repo"`
+	Namespace         *namespace `json:"namespace"`
+	ForkedFromProject *project   `json:"forked_from_project"`
+	ImportStatus      string     `json:"import_status"`
+	ImportError       string     `json:"import_error"`
+	DefaultBranch     string     `json:"default_branch"`
+}
+
+// file is a file in a repository, as the GitLab files API returns it
+type file struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+// ServerBaseURL will return the base URL of the GitLab instance we know
+// for a server string. gitlab.com is always known, and a self-hosted
+// instance can be defined with $KLONE_GITLABURL
+func ServerBaseURL(server string) (string, bool) {
+	if server == "gitlab.com" {
+		return DefaultBaseURL, true
+	}
+	if host, ok := provider.CustomHost("KLONE_GITLABURL"); !ok || host != server {
+		return "", false
+	}
+	return provider.CustomBaseURL("KLONE_GITLABURL")
+}
+
+// Hosts are the server strings of every GitLab instance we know about
+func Hosts() []string {
+	hosts := []string{"gitlab.com"}
+	if host, ok := provider.CustomHost("KLONE_GITLABURL"); ok {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// GetServerString returns the host of the GitLab instance, which is the string
+// we would want to use in things like $GOPATH
+func (s *GitServer) GetServerString() string {
+	return provider.Host(s.baseURL)
+}
+
+func (s *GitServer) OwnerName() string {
+	return s.usr.Username
+}
+
+// HTTPSCredentials is our access token, GitLab takes any user with it for git over HTTPS
+func (s *GitServer) HTTPSCredentials() (string, string) {
+	return "oauth2", s.token
+}
+
+func (s *GitServer) OwnerEmail() string {
+	return s.usr.Email
+}
+
+// Authenticate will look for a personal access token with the following hierarchy.
+// 1. Access token from env var
+// 2. Access token from the local cache for this host
+// 3. Prompt for an access token (and cache it)
+func (s *GitServer) Authenticate() error {
+	_, token, err := s.tokenSource().Token()
+	if err != nil {
+		return err
+	}
+	RefreshCredentials = false
+	s.token = token
+	usr := &user{}
+	err = s.do("GET", "/user", nil, usr)
+	if err != nil {
+		return err
+	}
+	s.usr = usr
+	local.Printf("Successfully authenticated [%s] with [%s]", usr.Username, s.GetServerString())
+	return nil
+}
+
+// GetRepoByOwner is the most effecient way to look up a repository exactly by it's name and owner
+func (s *GitServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
+	r := &Repo{assumedOwner: owner, server: s}
+	p := &project{}
+	err := s.do("GET", fmt.Sprintf("/projects/%s", projectID(owner, name)), nil, p)
+	if err != nil {
+		local.Printf("Unable to find repo [%s/%s]", owner, name)
+		return r, err
+	}
+	r.impl = p
+	if p.ForkedFromProject != nil {
+		r.forkedFrom = &Repo{impl: p.ForkedFromProject, server: s}
+	}
+	return r, nil
+}
+
+// GetRepo is the most effecient way to look up a repository exactly by it's name and assumed owner (you)
+func (s *GitServer) GetRepo(name string) (provider.Repo, error) {
+	return s.GetRepoByOwner(s.OwnerName(), name)
+}
+
+// GetRepos will return (and cache) a hash map of repositories by name
+func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
+	providerRepos := make(map[string]provider.Repo)
+	if len(s.repos) == 0 {
+		page := "1"
+		for page != "" {
+			var projects []*project
+			resp, err := s.doResponse("GET", fmt.Sprintf("/projects?owned=true&per_page=100&page=%s", page), nil, &projects)
+			if err != nil {
+				return providerRepos, err
+			}
+			for _, p := range projects {
+				r := &Repo{impl: p, server: s}
+				if p.ForkedFromProject != nil {
+					r.forkedFrom = &Repo{impl: p.ForkedFromProject, server: s}
+				}
+				providerRepos[p.Path] = r
+			}
+			page = resp.Header.Get("X-Next-Page")
+		}
+		s.repos = providerRepos
+	}
+	local.Printf("Cached %d repositories in memory", len(s.repos))
+	return s.repos, nil
+}
+
+// Fork will fork a project into the namespace of newOwner. GitLab will
+// import the fork asynchronously, so we wait for the import to finish
+// before returning the new repository.
+func (s *GitServer) Fork(parent provider.Repo, newOwner string) (provider.Repo, error) {
+	body := map[string]string{
+		"namespace_path": newOwner,
+	}
+	p := &project{}
+	err := s.do("POST", fmt.Sprintf("/projects/%s/fork", projectID(parent.Owner(), parent.Name())), body, p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fork repository [%s]: %v", parent.Name(), err)
+	}
+	p, err = s.waitForImport(p)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fork repository [%s]: %v", parent.Name(), err)
+	}
+	r := &Repo{impl: p, server: s}
+	if p.ForkedFromProject != nil {
+		r.forkedFrom = &Repo{impl: p.ForkedFromProject, server: s}
+	}
+	return r, nil
+}
+
+// waitForImport will poll a project until GitLab reports the import has finished
+func (s *GitServer) waitForImport(p *project) (*project, error) {
+	deadline := time.Now().Add(ForkTimeout)
+	for {
+		switch p.ImportStatus {
+		case "", "none", "finished":
+			return p, nil
+		case "failed":
+			return nil, fmt.Errorf("import failed: %s", p.ImportError)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("import still [%s] after %v", p.ImportStatus, ForkTimeout)
+		}
+		local.Printf("Waiting on GitLab to import fork [%s] [%s]", p.PathWithNamespace, p.ImportStatus)
+		time.Sleep(ForkPollInterval)
+		next := &project{}
+		err := s.do("GET", fmt.Sprintf("/projects/%d", p.ID), nil, next)
+		if err != nil {
+			return nil, err
+		}
+		p = next
+	}
+}
+
+func (s *GitServer) NewRepo(name, desc string) (provider.Repo, error) {
+	body := map[string]interface{}{
+		"name":                   name,
+		"path":                   name,
+		"description":            desc,
+		"initialize_with_readme": true,
+	}
+	p := &project{}
+	err := s.do("POST", "/projects", body, p)
+	if err != nil {
+		return nil, err
+	}
+	r := &Repo{impl: p, server: s}
+	return r, nil
+}
+
+func (s *GitServer) DeleteRepoByOwner(owner, name string) (bool, error) {
+	err := s.do("DELETE", fmt.Sprintf("/projects/%s", projectID(owner, name)), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *GitServer) DeleteRepo(name string) (bool, error) {
+	return s.DeleteRepoByOwner(s.OwnerName(), name)
+}
+
+// PullRequestRef is where GitLab keeps the head of a merge request
+func (s *GitServer) PullRequestRef(number int) (string, error) {
+	return fmt.Sprintf("refs/merge-requests/%d/head", number), nil
+}
+
+// klonefile will fetch the Klonefile from the default branch of a project
+func (s *GitServer) klonefile(p *project) ([]byte, error) {
+	if p.DefaultBranch == "" {
+		return nil, fmt.Errorf("404 project [%s] has no default branch", p.PathWithNamespace)
+	}
+	f := &file{}
+	err := s.do("GET", fmt.Sprintf("/projects/%d/repository/files/%s?ref=%s", p.ID, url.PathEscape(klonefile.Filename), url.QueryEscape(p.DefaultBranch)), nil, f)
+	if err != nil {
+		return nil, err
+	}
+	if f.Encoding != "base64" {
+		return []byte(f.Content), nil
+	}
+	return base64.StdEncoding.DecodeString(f.Content)
+}
+
+// language will return the language GitLab detected the most of in a project
+func (s *GitServer) language(id int) string {
+	langs := make(map[string]float64)
+	err := s.do("GET", fmt.Sprintf("/projects/%d/languages", id), nil, &langs)
+	if err != nil {
+		local.RecoverableErrorf("Unable to detect language: %v", err)
+		return ""
+	}
+	var lang string
+	var max float64
+	for l, percent := range langs {
+		if percent > max {
+			lang = l
+			max = percent
+		}
+	}
+	return lang
+}
+
+// projectID is how GitLab identifies a project by path in the API
+func projectID(owner, name string) string {
+	return url.PathEscape(fmt.Sprintf("%s/%s", owner, name))
+}
+
+// do will send a request to the GitLab v4 API and decode the response into v
+func (s *GitServer) do(method, path string, body, v interface{}) error {
+	_, err := s.doResponse(method, path, body, v)
+	return err
+}
+
+func (s *GitServer) doResponse(method, path string, body, v interface{}) (*http.Response, error) {
+	api := &provider.API{
+		BaseURL: s.baseURL + apiPath,
+		Client:  s.client,
+		Authorize: func(req *http.Request) {
+			req.Header.Set("PRIVATE-TOKEN", s.token)
+		},
+	}
+	return api.DoResponse(method, path, body, v)
+}
+
+// tokenSource is where we find the access token for this GitLab host
+func (s *GitServer) tokenSource() *provider.TokenSource {
+	return &provider.TokenSource{
+		Name:        "GitLab",
+		Host:        s.GetServerString(),
+		CacheDir:    CacheDir,
+		TokenEnv:    "KLONE_GITLABTOKEN",
+		TokenPrompt: "Access Token",
+		Refresh:     RefreshCredentials,
+		Testing:     Testing,
+	}
+}
@@ -0,0 +1,248 @@
+package gitlab
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeGitLab is a small stand-in for the GitLab v4 API
+type fakeGitLab struct {
+	projects     map[string]*project
+	languages    map[int]map[string]float64
+	importPolls  int
+	lastForkBody map[string]string
+	nextID       int
+	klonefiles   map[int]string
+}
+
+func newFakeGitLab() *fakeGitLab {
+	f := &fakeGitLab{
+		projects:  make(map[string]*project),
+		languages: make(map[int]map[string]float64),
+		nextID:    100,
+		klonefiles: map[int]string{
+			1: "kloner: golang\n",
+		},
+	}
+	f.add(&project{ID: 1, Path: "kubernetes", Name: "kubernetes", Namespace: &namespace{FullPath: "upstream"}, DefaultBranch: "master"}, map[string]float64{"Go": 92.1, "Shell": 7.9})
+	return f
+}
+
+func (f *fakeGitLab) add(p *project, langs map[string]float64) {
+	p.PathWithNamespace = fmt.Sprintf("%s/%s", p.Namespace.FullPath, p.Path)
+	p.SSHURLToRepo = fmt.Sprintf("git@gitlab.example.com:%s.git", p.PathWithNamespace)
+	p.HTTPURLToRepo = fmt.Sprintf("https://gitlab.example.com/%s.git", p.PathWithNamespace)
+	f.projects[p.PathWithNamespace] = p
+	f.languages[p.ID] = langs
+}
+
+func (f *fakeGitLab) byID(id string) *project {
+	for _, p := range f.projects {
+		if fmt.Sprintf("%d", p.ID) == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.EscapedPath(), apiPath)
+	spl := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	switch {
+	case path == "/user":
+		json.NewEncoder(w).Encode(&user{ID: 7, Username: "alice", Email: "alice@example.com"})
+	case len(spl) == 2 && spl[0] == "projects" && r.Method == "GET":
+		id := strings.Replace(spl[1], "%2F", "/", -1)
+		p, ok := f.projects[id]
+		if !ok {
+			p = f.byID(id)
+		}
+		if p == nil {
+			http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+			return
+		}
+		if p.ImportStatus == "started" {
+			f.importPolls++
+			if f.importPolls >= 2 {
+				p.ImportStatus = "finished"
+			}
+		}
+		json.NewEncoder(w).Encode(p)
+	case len(spl) == 2 && spl[0] == "projects" && r.Method == "DELETE":
+		id := strings.Replace(spl[1], "%2F", "/", -1)
+		if _, ok := f.projects[id]; !ok {
+			http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+			return
+		}
+		delete(f.projects, id)
+		w.WriteHeader(http.StatusAccepted)
+	case len(spl) == 3 && spl[2] == "languages":
+		p := f.byID(spl[1])
+		if p == nil {
+			http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(f.languages[p.ID])
+	case len(spl) == 5 && spl[2] == "repository" && spl[3] == "files":
+		p := f.byID(spl[1])
+		content, ok := f.klonefiles[p.ID]
+		if !ok || spl[4] != ".Klonefile" || r.URL.Query().Get("ref") != p.DefaultBranch {
+			http.Error(w, `{"message":"404 File Not Found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&file{Content: base64.StdEncoding.EncodeToString([]byte(content)), Encoding: "base64"})
+	case len(spl) == 3 && spl[2] == "fork" && r.Method == "POST":
+		parent := f.projects[strings.Replace(spl[1], "%2F", "/", -1)]
+		f.lastForkBody = make(map[string]string)
+		json.NewDecoder(r.Body).Decode(&f.lastForkBody)
+		f.nextID++
+		fork := &project{ID: f.nextID, Path: parent.Path, Name: parent.Name, Namespace: &namespace{FullPath: f.lastForkBody["namespace_path"]}, ForkedFromProject: parent, ImportStatus: "started"}
+		f.add(fork, f.languages[parent.ID])
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(fork)
+	case path == "/projects" && r.Method == "POST":
+		body := make(map[string]interface{})
+		json.NewDecoder(r.Body).Decode(&body)
+		f.nextID++
+		p := &project{ID: f.nextID, Path: body["path"].(string), Name: body["name"].(string), Description: body["description"].(string), Namespace: &namespace{FullPath: "alice"}}
+		f.add(p, map[string]float64{})
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(p)
+	default:
+		http.Error(w, `{"message":"404 Not Found"}`, http.StatusNotFound)
+	}
+}
+
+func newTestServer(t *testing.T) (*GitServer, *fakeGitLab, func()) {
+	fake := newFakeGitLab()
+	ts := httptest.NewServer(fake)
+	os.Setenv("KLONE_GITLABTOKEN", "secret")
+	Testing = true
+	ForkPollInterval = time.Millisecond
+	s := &GitServer{baseURL: ts.URL}
+	err := s.Authenticate()
+	if err != nil {
+		ts.Close()
+		t.Fatalf("Unable to auth: %v", err)
+	}
+	return s, fake, ts.Close
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, _, done := newTestServer(t)
+	defer done()
+	if s.OwnerName() != "alice" {
+		t.Fatalf("Unexpected owner name: %s", s.OwnerName())
+	}
+	if s.OwnerEmail() != "alice@example.com" {
+		t.Fatalf("Unexpected owner email: %s", s.OwnerEmail())
+	}
+	if !strings.HasPrefix(s.GetServerString(), "127.0.0.1:") {
+		t.Fatalf("Unexpected server string: %s", s.GetServerString())
+	}
+}
+
+func TestGetRepoByOwner(t *testing.T) {
+	s, _, done := newTestServer(t)
+	defer done()
+	repo, err := s.GetRepoByOwner("upstream", "kubernetes")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if repo.Owner() != "upstream" || repo.Name() != "kubernetes" {
+		t.Fatalf("Unexpected repo: %s/%s", repo.Owner(), repo.Name())
+	}
+	if repo.Language() != "Go" {
+		t.Fatalf("Unexpected language: %s", repo.Language())
+	}
+	if repo.GitRemoteUrl() != "git@gitlab.example.com:upstream/kubernetes.git" {
+		t.Fatalf("Unexpected remote url: %s", repo.GitRemoteUrl())
+	}
+	if repo.ForkedFrom() != nil {
+		t.Fatal("Unexpected parent repository")
+	}
+	_, err = s.GetRepoByOwner("upstream", "missing")
+	if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+		t.Fatalf("Expected 404 for missing repo: %v", err)
+	}
+}
+
+func TestForkWaitsForImport(t *testing.T) {
+	s, fake, done := newTestServer(t)
+	defer done()
+	parent, err := s.GetRepoByOwner("upstream", "kubernetes")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	fork, err := s.Fork(parent, s.OwnerName())
+	if err != nil {
+		t.Fatalf("Unable to fork: %v", err)
+	}
+	if fake.lastForkBody["namespace_path"] != "alice" {
+		t.Fatalf("Unexpected fork namespace: %v", fake.lastForkBody)
+	}
+	if fake.importPolls < 2 {
+		t.Fatalf("Fork returned before import finished (%d polls)", fake.importPolls)
+	}
+	if fork.Owner() != "alice" || fork.ForkedFrom() == nil || fork.ForkedFrom().Owner() != "upstream" {
+		t.Fatalf("Unexpected fork: %s/%s", fork.Owner(), fork.Name())
+	}
+	repo, err := s.GetRepo("kubernetes")
+	if err != nil {
+		t.Fatalf("Unable to find fork: %v", err)
+	}
+	if repo.ForkedFrom() == nil {
+		t.Fatal("Unable to detect parent of fork")
+	}
+}
+
+func TestNewAndDeleteRepo(t *testing.T) {
+	s, _, done := newTestServer(t)
+	defer done()
+	repo, err := s.NewRepo("klone-e2e-query", "A throw-away repository")
+	if err != nil {
+		t.Fatalf("Unable to create repo: %v", err)
+	}
+	if repo.Owner() != "alice" || repo.Name() != "klone-e2e-query" {
+		t.Fatalf("Unexpected repo: %s/%s", repo.Owner(), repo.Name())
+	}
+	ok, err := s.DeleteRepo("klone-e2e-query")
+	if err != nil || !ok {
+		t.Fatalf("Unable to delete repo: %v", err)
+	}
+	_, err = s.GetRepo("klone-e2e-query")
+	if err == nil {
+		t.Fatal("Repo still exists after delete")
+	}
+}
+
+func TestGetKlonefile(t *testing.T) {
+	s, fake, done := newTestServer(t)
+	defer done()
+	repo, err := s.GetRepoByOwner("upstream", "kubernetes")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if content := string(repo.GetKlonefile()); content != fake.klonefiles[1] {
+		t.Fatalf("Unexpected Klonefile: %s", content)
+	}
+	fake.add(&project{ID: 2, Path: "empty", Name: "empty", Namespace: &namespace{FullPath: "upstream"}, DefaultBranch: "master"}, map[string]float64{})
+	repo, err = s.GetRepoByOwner("upstream", "empty")
+	if err != nil {
+		t.Fatalf("Unable to get repo: %v", err)
+	}
+	if content := repo.GetKlonefile(); len(content) != 0 {
+		t.Fatalf("Unexpected Klonefile: %s", content)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// repo.go is an implementation of a git repository according to klone
+
+package gitlab
+
+import (
+	"github.com/kris-nova/klone/pkg/klonefile"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"strings"
+)
+
+type Repo struct {
+	impl         *project
+	forkedFrom   *Repo
+	assumedOwner string
+	server       *GitServer
+	lang         *string
+}
+
+func (r *Repo) SetImplementation(impl interface{}) {
+	p := impl.(*project)
+	r.impl = p
+}
+
+// GitRemoteUrl is the url we use for remotes, over SSH unless we are using HTTPS for the host
+func (r *Repo) GitRemoteUrl() string {
+	return provider.RemoteUrl(r.impl.SSHURLToRepo, r.impl.HTTPURLToRepo)
+}
+
+// GitCloneUrl is the url we clone with, GitLab has no git:// protocol so we clone over HTTPS
+func (r *Repo) GitCloneUrl() string {
+	return r.impl.HTTPURLToRepo
+}
+
+func (r *Repo) HttpsCloneUrl() string {
+	return r.impl.HTTPURLToRepo
+}
+
+// Language is looked up (and remembered) the first time it is needed
+// as GitLab does not return languages with a project
+func (r *Repo) Language() string {
+	if r.lang == nil {
+		lang := ""
+		if r.server != nil && r.impl != nil {
+			lang = r.server.language(r.impl.ID)
+		}
+		r.lang = &lang
+	}
+	return *r.lang
+}
+
+// Owner is the full path of the namespace the project lives in (users, groups and subgroups)
+func (r *Repo) Owner() string {
+	if r.impl == nil || r.impl.Namespace == nil {
+		return r.assumedOwner
+	}
+	return r.impl.Namespace.FullPath
+}
+
+func (r *Repo) Name() string {
+	return r.impl.Path
+}
+
+func (r *Repo) Description() string {
+	return r.impl.Description
+}
+
+func (r *Repo) ForkedFrom() provider.Repo {
+	if r.forkedFrom == nil {
+		return nil
+	}
+	return r.forkedFrom
+}
+
+// GetKlonefile will fetch the Klonefile of the repository, if it has one
+func (r *Repo) GetKlonefile() []byte {
+	if r.server == nil || r.impl == nil {
+		return []byte("")
+	}
+	content, err := r.server.klonefile(r.impl)
+	if err != nil {
+		if !strings.Contains(err.Error(), "404") {
+			local.Printf("Unable to fetch %s for [%s/%s]: %v", klonefile.Filename, r.Owner(), r.Name(), err)
+		}
+		return []byte("")
+	}
+	return content
+}
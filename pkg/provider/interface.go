@@ -23,6 +23,16 @@
 
 package provider
 
+import (
+	"errors"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/auth"
+	"net/url"
+)
+
+// ErrForkNotSupported is returned from Fork() by git servers that have no way to fork a repository
+var ErrForkNotSupported = errors.New("git server does not support forking repositories")
+
 // KloneProvider is the core provider for using Klone
 type KloneProvider interface {
 	NewGitServer() (GitServer, error)
@@ -64,3 +74,40 @@ type GitServer interface {
 	DeleteRepoByOwner(owner, name string) (bool, error)
 	NewRepo(name, desc string) (Repo, error)
 }
+
+// PullRequester is implemented by git servers that keep pull requests as git refs
+type PullRequester interface {
+	PullRequestRef(number int) (string, error)
+}
+
+// PullRequestRef will return the git ref a git server keeps a pull request at
+func PullRequestRef(s GitServer, number int) (string, error) {
+	pr, ok := s.(PullRequester)
+	if !ok {
+		return "", fmt.Errorf("git server [%s] does not support pull requests", s.GetServerString())
+	}
+	return pr.PullRequestRef(number)
+}
+
+// HTTPSCredentialer is implemented by git servers that can authenticate git over HTTPS
+// with their access token
+type HTTPSCredentialer interface {
+	HTTPSCredentials() (user, password string)
+}
+
+// UseHTTPS is true if we are using HTTPS for the host of a url
+func UseHTTPS(httpsURL string) bool {
+	u, err := url.Parse(httpsURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return auth.UseHTTPS(u.Hostname())
+}
+
+// RemoteUrl is the url of a remote, over HTTPS if we are using HTTPS for it's host
+func RemoteUrl(sshURL, httpsURL string) string {
+	if sshURL == "" || UseHTTPS(httpsURL) {
+		return httpsURL
+	}
+	return sshURL
+}
@@ -0,0 +1,45 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// klone.go is top of the provider. This is the primary data structure.
+
+package plaingit
+
+import (
+	"github.com/kris-nova/klone/pkg/provider"
+)
+
+// KloneProvider is the plain git provider, for servers that have no API at all.
+// Prefix is what a repository path is appended to in order to build a remote url
+// (E.G. https://git.kernel.org/ or git@buildbox:)
+type KloneProvider struct {
+	Prefix string
+}
+
+func (k *KloneProvider) NewGitServer() (provider.GitServer, error) {
+	srv := &GitServer{
+		prefix: k.Prefix,
+	}
+	err := srv.Authenticate()
+	if err != nil {
+		return srv, err
+	}
+	return srv, nil
+}
@@ -0,0 +1,235 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// plaingit.go is a representation of a git server with no API (a bare repository on a
+// build box, cgit, kernel.org) where all we can do is ask git what exists
+
+package plaingit
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/auth"
+	"github.com/kris-nova/klone/pkg/local"
+	"github.com/kris-nova/klone/pkg/provider"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// DefaultScheme is used to build remote urls for server/owner/name queries
+	DefaultScheme = "https"
+
+	// webPaths are the path segments browsers add after owner/name that are not
+	// part of the repository (E.G. /tree/master/pkg or /pull/42)
+	webPaths = map[string]bool{
+		"tree": true, "blob": true, "commit": true, "commits": true, "pull": true, "pulls": true,
+		"issues": true, "src": true, "raw": true, "releases": true, "wiki": true,
+	}
+
+	schemeRegExp  = regexp.MustCompile(`^[a-z][a-z0-9+.-]*://`)
+	scpLikeRegExp = regexp.MustCompile(`^(?:[^@/\s]+@)?[^:/\s]+:[^\s]+$`)
+)
+
+// GitServer is a representation of a git server we can only talk git to
+type GitServer struct {
+	prefix string
+	repos  map[string]provider.Repo
+}
+
+// Hosts are the server strings defined in $KLONE_GITHOSTS (a comma separated list)
+func Hosts() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("KLONE_GITHOSTS"), ",") {
+		host = strings.TrimSpace(host)
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// ServerPrefix is the url prefix we use for a server string (E.G. https://git.kernel.org/)
+func ServerPrefix(server string) string {
+	return fmt.Sprintf("%s://%s/", DefaultScheme, server)
+}
+
+// IsURL is true if a query is a remote url (E.G. ssh://, https://, file:// or git@host:path)
+func IsURL(query string) bool {
+	return schemeRegExp.MatchString(query) || scpLikeRegExp.MatchString(query)
+}
+
+// SplitURL will split a remote (or browser) url into a prefix, owner, and name. The owner
+// is everything between the host and the name, and can have /'s in it.
+// E.G. https://git.kernel.org/pub/scm/git/git.git is [https://git.kernel.org/] [pub/scm/git] [git]
+func SplitURL(remote string) (prefix, owner, name string, err error) {
+	if !IsURL(remote) {
+		return "", "", "", fmt.Errorf("invalid remote url [%s]", remote)
+	}
+	ep, err := transport.NewEndpoint(remote)
+	if err != nil {
+		return "", "", "", err
+	}
+	path := ep.Path()
+	if path == "" || !strings.HasSuffix(remote, path) {
+		return "", "", "", fmt.Errorf("unable to find repository path in remote url [%s]", remote)
+	}
+	trimmed := strings.TrimLeft(path, "/")
+	prefix = remote[:len(remote)-len(trimmed)]
+	if ep.Protocol() == "http" || ep.Protocol() == "https" {
+		trimmed = trimWebPath(trimmed)
+	}
+	trimmed = strings.TrimSuffix(strings.TrimRight(trimmed, "/"), ".git")
+	i := strings.LastIndex(trimmed, "/")
+	owner, name = trimmed[:i+1], trimmed[i+1:]
+	if name == "" {
+		return "", "", "", fmt.Errorf("unable to find repository name in remote url [%s]", remote)
+	}
+	return prefix, strings.TrimSuffix(owner, "/"), name, nil
+}
+
+// trimWebPath will remove anything a browser adds to a repository path. GitLab
+// starts these with /-/, everyone else just starts them after owner/name.
+func trimWebPath(path string) string {
+	if i := strings.Index(path, "/-/"); i > 0 {
+		path = path[:i]
+	}
+	path = strings.SplitN(path, "?", 2)[0]
+	path = strings.SplitN(path, "#", 2)[0]
+	segments := strings.Split(path, "/")
+	for i := 2; i < len(segments); i++ {
+		if webPaths[segments[i]] {
+			return strings.Join(segments[:i], "/")
+		}
+	}
+	return path
+}
+
+// PrefixHost returns the host of a remote url prefix, or localhost for file://
+func PrefixHost(prefix string) string {
+	ep, err := transport.NewEndpoint(fmt.Sprintf("%srepo", prefix))
+	if err != nil || ep.Host() == "" {
+		return "localhost"
+	}
+	return ep.Host()
+}
+
+// GetServerString returns the host of the remote url prefix, or localhost for file://
+func (s *GitServer) GetServerString() string {
+	return PrefixHost(s.prefix)
+}
+
+// OwnerName is empty, as we never have a user on a plain git server
+func (s *GitServer) OwnerName() string {
+	return ""
+}
+
+func (s *GitServer) OwnerEmail() string {
+	return ""
+}
+
+// Authenticate has nothing to authenticate against, any credentials are handled by git
+func (s *GitServer) Authenticate() error {
+	if s.prefix == "" {
+		return fmt.Errorf("no remote url defined for plain git server")
+	}
+	if s.repos == nil {
+		s.repos = make(map[string]provider.Repo)
+	}
+	return nil
+}
+
+// GetRepoByOwner will build a remote url and ask git if the repository is there.
+// We try the url with and without a .git suffix, as servers disagree on which is right.
+func (s *GitServer) GetRepoByOwner(owner, name string) (provider.Repo, error) {
+	path := name
+	if owner != "" {
+		path = fmt.Sprintf("%s/%s", owner, name)
+	}
+	var err error
+	for _, suffix := range []string{"", ".git"} {
+		url := fmt.Sprintf("%s%s%s", s.prefix, path, suffix)
+		err = lsRemote(url)
+		if err == nil {
+			r := &Repo{url: url, owner: owner, name: name}
+			s.repos[name] = r
+			return r, nil
+		}
+	}
+	local.Printf("Unable to find repo [%s%s]", s.prefix, path)
+	return nil, err
+}
+
+// GetRepo is the same as GetRepoByOwner with no owner, as we have no user
+func (s *GitServer) GetRepo(name string) (provider.Repo, error) {
+	return s.GetRepoByOwner(s.OwnerName(), name)
+}
+
+// GetRepos will return the repositories we have found so far, plain git
+// has no way to list the repositories on a server
+func (s *GitServer) GetRepos() (map[string]provider.Repo, error) {
+	return s.repos, nil
+}
+
+// Fork will always return provider.ErrForkNotSupported
+func (s *GitServer) Fork(parent provider.Repo, newOwner string) (provider.Repo, error) {
+	return nil, provider.ErrForkNotSupported
+}
+
+func (s *GitServer) NewRepo(name, desc string) (provider.Repo, error) {
+	return nil, fmt.Errorf("unable to create repository [%s] on plain git server [%s]", name, s.GetServerString())
+}
+
+func (s *GitServer) DeleteRepoByOwner(owner, name string) (bool, error) {
+	return false, fmt.Errorf("unable to delete repository [%s/%s] on plain git server [%s]", owner, name, s.GetServerString())
+}
+
+func (s *GitServer) DeleteRepo(name string) (bool, error) {
+	return s.DeleteRepoByOwner(s.OwnerName(), name)
+}
+
+// lsRemote will ask the server for the references of a remote url, the same
+// way `git ls-remote` would. An empty repository still exists.
+func lsRemote(url string) error {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return err
+	}
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return err
+	}
+	pk, err := auth.GetTransportForUrl(url)
+	if err != nil {
+		return err
+	}
+	sess, err := c.NewUploadPackSession(ep, pk)
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	_, err = sess.AdvertisedReferences()
+	if err != nil && err != transport.ErrEmptyRemoteRepository {
+		return err
+	}
+	return nil
+}
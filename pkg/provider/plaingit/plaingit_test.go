@@ -0,0 +1,86 @@
+package plaingit
+
+import (
+	"fmt"
+	"github.com/kris-nova/klone/pkg/provider"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// newBareRepo will create a bare repository with a single commit at dir/owner/name.git
+func newBareRepo(t *testing.T, dir, owner, name string) {
+	work := fmt.Sprintf("%s/work-%s", dir, name)
+	bare := fmt.Sprintf("%s/%s/%s.git", dir, owner, name)
+	for _, args := range [][]string{
+		{"init", "-q", work},
+		{"-C", work, "-c", "user.name=klone", "-c", "user.email=klone@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"clone", "-q", "--bare", work, bare},
+	} {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unable to run git %v: %v %s", args, err, out)
+		}
+	}
+}
+
+func TestSplitURL(t *testing.T) {
+	cases := []struct {
+		url, prefix, owner, name string
+	}{
+		{"https://git.kernel.org/pub/scm/git/git.git", "https://git.kernel.org/", "pub/scm/git", "git"},
+		{"git@buildbox:team/service.git", "git@buildbox:", "team", "service"},
+		{"ssh://git@buildbox:2222/srv/git/service", "ssh://git@buildbox:2222/", "srv/git", "service"},
+		{"git://localhost/service.git/", "git://localhost/", "", "service"},
+		{"file:///srv/git/service.git", "file:///", "srv/git", "service"},
+	}
+	for _, c := range cases {
+		prefix, owner, name, err := SplitURL(c.url)
+		if err != nil {
+			t.Fatalf("Unable to split [%s]: %v", c.url, err)
+		}
+		if prefix != c.prefix || owner != c.owner || name != c.name {
+			t.Fatalf("Unexpected split of [%s]: [%s] [%s] [%s]", c.url, prefix, owner, name)
+		}
+	}
+	for _, query := range []string{"klone", "kris-nova/klone", "github.com/kris-nova/klone"} {
+		if IsURL(query) {
+			t.Fatalf("Query [%s] detected as a url", query)
+		}
+	}
+}
+
+func TestGetRepoByOwner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klone-plaingit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	newBareRepo(t, dir, "team", "service")
+
+	kp := &KloneProvider{Prefix: fmt.Sprintf("file://%s/", dir)}
+	s, err := kp.NewGitServer()
+	if err != nil {
+		t.Fatalf("Unable to create git server: %v", err)
+	}
+	if s.GetServerString() != "localhost" {
+		t.Fatalf("Unexpected server string: %s", s.GetServerString())
+	}
+	repo, err := s.GetRepoByOwner("team", "service")
+	if err != nil {
+		t.Fatalf("Unable to find repo: %v", err)
+	}
+	expected := fmt.Sprintf("file://%s/team/service", dir)
+	if repo.GitCloneUrl() != expected || repo.Owner() != "team" || repo.Name() != "service" {
+		t.Fatalf("Unexpected repo: %s %s/%s", repo.GitCloneUrl(), repo.Owner(), repo.Name())
+	}
+	_, err = s.GetRepoByOwner("team", "missing")
+	if err == nil {
+		t.Fatal("Found missing repo")
+	}
+	_, err = s.Fork(repo, "")
+	if err != provider.ErrForkNotSupported {
+		t.Fatalf("Expected fork to be unsupported: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// repo.go is an implementation of a git repository according to klone
+
+package plaingit
+
+import (
+	"github.com/kris-nova/klone/pkg/provider"
+)
+
+// Repo is a repository we only know the remote url of
+type Repo struct {
+	url   string
+	owner string
+	name  string
+}
+
+func (r *Repo) SetImplementation(impl interface{}) {
+	r.url = impl.(string)
+}
+
+// GitRemoteUrl is the url we found the repository at
+func (r *Repo) GitRemoteUrl() string {
+	return r.url
+}
+
+// GitCloneUrl is the url we found the repository at
+func (r *Repo) GitCloneUrl() string {
+	return r.url
+}
+
+func (r *Repo) HttpsCloneUrl() string {
+	return r.url
+}
+
+// Language can not be detected without an API
+func (r *Repo) Language() string {
+	return ""
+}
+
+func (r *Repo) Owner() string {
+	return r.owner
+}
+
+func (r *Repo) Name() string {
+	return r.name
+}
+
+func (r *Repo) Description() string {
+	return ""
+}
+
+// ForkedFrom is always nil, plain git has no idea of a fork
+func (r *Repo) ForkedFrom() provider.Repo {
+	return nil
+}
+
+// GetKlonefile is always empty, plain git has no API to fetch a single file before cloning
+func (r *Repo) GetKlonefile() []byte {
+	return []byte("")
+}
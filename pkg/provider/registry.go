@@ -0,0 +1,131 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// registry.go is where KloneProviders register the servers they can talk to. The query
+// parser iterates the registry, so new providers never need to touch the parser.
+
+package provider
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registration describes a KloneProvider to the query parser
+type Registration struct {
+	// Name is a unique name for the provider (E.G. github)
+	Name string
+
+	// Hosts returns the server strings (E.G. github.com) the provider can talk to.
+	// This is a function so providers can read hosts from configuration.
+	Hosts func() []string
+
+	// Priority is the order providers are tried in, lower goes first
+	Priority int
+
+	// GuessName is true if a single name query (E.G. klone) should be tried against
+	// the provider's hosts, using your own user as the owner
+	GuessName bool
+
+	// GuessOwnerName is true if an owner/name query (E.G. kris-nova/klone) should
+	// be tried against the provider's hosts
+	GuessOwnerName bool
+
+	// New returns a KloneProvider for one of the provider's hosts
+	New func(server string) KloneProvider
+}
+
+var (
+	registry   = make(map[string]*Registration)
+	registryMu sync.Mutex
+)
+
+// Register will add a provider to the registry, replacing any provider with the same name
+func Register(r *Registration) error {
+	if r.Name == "" {
+		return fmt.Errorf("unable to register provider without a name")
+	}
+	if r.Hosts == nil || r.New == nil {
+		return fmt.Errorf("unable to register provider [%s] without Hosts and New", r.Name)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[r.Name] = r
+	return nil
+}
+
+// Unregister will remove a provider from the registry by name
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
+
+// Registrations returns every registered provider in priority order
+func Registrations() []*Registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	var regs []*Registration
+	for _, r := range registry {
+		regs = append(regs, r)
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		if regs[i].Priority == regs[j].Priority {
+			return regs[i].Name < regs[j].Name
+		}
+		return regs[i].Priority < regs[j].Priority
+	})
+	return regs
+}
+
+// Lookup will return the highest priority provider that can talk to server
+func Lookup(server string) (*Registration, bool) {
+	for _, r := range Registrations() {
+		for _, host := range r.Hosts() {
+			if host == server {
+				return r, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// GuessNameServers are the servers to try (in order) for a single name query
+func GuessNameServers() []string {
+	var servers []string
+	for _, r := range Registrations() {
+		if r.GuessName {
+			servers = append(servers, r.Hosts()...)
+		}
+	}
+	return servers
+}
+
+// GuessOwnerNameServers are the servers to try (in order) for an owner/name query
+func GuessOwnerNameServers() []string {
+	var servers []string
+	for _, r := range Registrations() {
+		if r.GuessOwnerName {
+			servers = append(servers, r.Hosts()...)
+		}
+	}
+	return servers
+}
@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fakeProvider struct {
+	server string
+}
+
+func (f *fakeProvider) NewGitServer() (GitServer, error) {
+	return nil, nil
+}
+
+func register(t *testing.T, name string, priority int, guess bool, hosts ...string) {
+	err := Register(&Registration{
+		Name:           name,
+		Hosts:          func() []string { return hosts },
+		Priority:       priority,
+		GuessName:      guess,
+		GuessOwnerName: guess,
+		New: func(server string) KloneProvider {
+			return &fakeProvider{server: server}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to register [%s]: %v", name, err)
+	}
+}
+
+func TestRegistryOrder(t *testing.T) {
+	register(t, "test-low", 50, true, "low.example")
+	register(t, "test-high", 5, true, "high.example", "high2.example")
+	register(t, "test-quiet", 1, false, "quiet.example")
+	defer Unregister("test-low")
+	defer Unregister("test-high")
+	defer Unregister("test-quiet")
+
+	expected := []string{"high.example", "high2.example", "low.example"}
+	if servers := GuessNameServers(); !reflect.DeepEqual(servers, expected) {
+		t.Fatalf("Unexpected name guesses: %v", servers)
+	}
+	if servers := GuessOwnerNameServers(); !reflect.DeepEqual(servers, expected) {
+		t.Fatalf("Unexpected owner/name guesses: %v", servers)
+	}
+
+	r, ok := Lookup("quiet.example")
+	if !ok || r.Name != "test-quiet" {
+		t.Fatalf("Unable to look up server that does not guess: %v", r)
+	}
+	kp := r.New("quiet.example").(*fakeProvider)
+	if kp.server != "quiet.example" {
+		t.Fatalf("Unexpected server passed to New: %s", kp.server)
+	}
+	_, ok = Lookup("unknown.example")
+	if ok {
+		t.Fatal("Found provider for unknown server")
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	if err := Register(&Registration{Name: "test-invalid"}); err == nil {
+		t.Fatal("Able to register provider without Hosts and New")
+	}
+	if err := Register(&Registration{}); err == nil {
+		t.Fatal("Able to register provider without a name")
+	}
+}
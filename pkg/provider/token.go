@@ -0,0 +1,163 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// token.go is where we find the access token for a git server, and cache it between klones
+
+package provider
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/kris-nova/klone/pkg/local"
+	"golang.org/x/crypto/ssh/terminal"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// KeepCredentials will cache (or store) the credentials we ask for. Planning a klone
+// (--dry-run) turns it off, so a plan never writes a token anywhere.
+var KeepCredentials = true
+
+// TokenSource finds the access token for a git server with the following hierarchy.
+// 1. Access token (and user) from env vars
+// 2. Access token (and user) from the local cache for the host, an age file (see EncryptedFile)
+// 3. Prompt for them (and cache them, if we keep credentials)
+type TokenSource struct {
+	// Name is the git server, E.G. GitLab
+	Name string
+
+	// Host is the server string of the git server
+	Host string
+
+	// CacheDir is where we cache tokens, one file per host
+	CacheDir string
+
+	// TokenEnv is the env var we read the access token from
+	TokenEnv string
+
+	// UserEnv is the env var we read a user from, for git servers that need one with the token
+	UserEnv string
+
+	// TokenPrompt is what we call the access token when we ask for it
+	TokenPrompt string
+
+	// Refresh will ignore the env and the cache, and ask for a new token
+	Refresh bool
+
+	// Testing will never ask for a token
+	Testing bool
+}
+
+// CachePath is where we cache the access token for the host
+func (t *TokenSource) CachePath() string {
+	return filepath.Join(t.CacheDir, t.Host)
+}
+
+// Token will return the user (if we need one) and access token for the host
+func (t *TokenSource) Token() (string, string, error) {
+	user := ""
+	if t.UserEnv != "" {
+		user = os.Getenv(t.UserEnv)
+	}
+	token := os.Getenv(t.TokenEnv)
+	if !t.Refresh {
+		if token != "" && (t.UserEnv == "" || user != "") {
+			return user, token, nil
+		}
+		cached, err := t.cached()
+		if err != nil {
+			return "", "", err
+		}
+		if t.UserEnv == "" && cached != "" {
+			return "", cached, nil
+		}
+		if spl := strings.SplitN(cached, ":", 2); t.UserEnv != "" && len(spl) == 2 {
+			return spl[0], spl[1], nil
+		}
+	}
+	if t.Testing {
+		return "", "", fmt.Errorf("no %s access token for [%s]", t.Name, t.Host)
+	}
+	if t.UserEnv != "" && (user == "" || t.Refresh) {
+		local.PrintPrompt(fmt.Sprintf("%s Username [%s]: ", t.Name, t.Host))
+		u, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", "", err
+		}
+		user = strings.TrimSpace(u)
+	}
+	local.PrintPrompt(fmt.Sprintf("%s %s [%s]: ", t.Name, t.TokenPrompt, t.Host))
+	b, err := terminal.ReadPassword(int(syscall.Stdin))
+	if err != nil {
+		return "", "", err
+	}
+	token = strings.TrimSpace(string(b))
+	if !KeepCredentials {
+		return user, token, nil
+	}
+	cached := token
+	if t.UserEnv != "" {
+		cached = fmt.Sprintf("%s:%s", user, token)
+	}
+	err = t.Cache(cached)
+	if err != nil {
+		local.RecoverableErrorf("Unable to cache access token: %v", err)
+	}
+	return user, token, nil
+}
+
+// cached is what we cached for the host. A plaintext cache from an older klone is
+// encrypted the first time we read it, unless we are only planning a klone.
+func (t *TokenSource) cached() (string, error) {
+	content, encrypted, err := t.file().Read()
+	if err != nil {
+		return "", err
+	}
+	cached := string(content)
+	if cached != "" && !encrypted && KeepCredentials {
+		err = t.Cache(cached)
+		if err != nil {
+			local.RecoverableErrorf("Unable to rewrite access token cache [%s]: %v", t.CachePath(), err)
+		} else {
+			local.Printf("Rewrote plaintext access token cache [%s]", t.CachePath())
+		}
+	}
+	return cached, nil
+}
+
+// Cache will encrypt content into the cache for the host, only we can read it (0600)
+func (t *TokenSource) Cache(content string) error {
+	err := os.MkdirAll(t.CacheDir, 0700)
+	if err != nil {
+		return err
+	}
+	return t.file().Write(content)
+}
+
+// file is the encrypted cache for the host, every host shares the identity next to CacheDir
+// (E.G. ~/.klone/gitlab.key)
+func (t *TokenSource) file() *EncryptedFile {
+	return &EncryptedFile{
+		Path:         t.CachePath(),
+		IdentityPath: fmt.Sprintf("%s.key", filepath.Clean(t.CacheDir)),
+	}
+}
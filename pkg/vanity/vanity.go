@@ -0,0 +1,149 @@
+// Copyright © 2017 Kris Nova <kris@nivenly.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+//  _  ___
+// | |/ / | ___  _ __   ___
+// | ' /| |/ _ \| '_ \ / _ \
+// | . \| | (_) | | | |  __/
+// |_|\_\_|\___/|_| |_|\___|
+//
+// vanity.go will resolve a vanity import path (E.G. golang.org/x/tools) to the git
+// repository behind it, the same way `go get` does with ?go-get=1 meta tags
+
+package vanity
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// Scheme is how we fetch an import path
+	Scheme = "https"
+
+	// Client is the client we fetch import paths with, a host that does not answer
+	// in time is not an import path we can use
+	Client = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Import is a go-import meta tag
+// E.G. <meta name="go-import" content="golang.org/x/tools git https://go.googlesource.com/tools">
+type Import struct {
+	Prefix  string // The import path of the repository root
+	VCS     string
+	RepoURL string
+}
+
+// IsImportPath is true if a query could be an import path, the first element
+// of an import path is always a domain (E.G. k8s.io/client-go)
+func IsImportPath(query string) bool {
+	if strings.Contains(query, "://") || !strings.Contains(query, "/") {
+		return false
+	}
+	host := strings.Split(query, "/")[0]
+	return strings.Contains(host, ".") && !strings.Contains(host, "@") && !strings.Contains(host, ":")
+}
+
+// Resolve will fetch the meta tags for an import path, and find the git repository it lives in
+func Resolve(importPath string) (*Import, error) {
+	importPath = strings.TrimSuffix(importPath, "/")
+	url := fmt.Sprintf("%s://%s?go-get=1", Scheme, importPath)
+	resp, err := Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch [%s]: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch [%s]: %s", url, resp.Status)
+	}
+	imports, err := ParseMetaImports(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse [%s]: %v", url, err)
+	}
+	return matchImport(importPath, imports)
+}
+
+// matchImport will find the one import that is a prefix of our import path
+func matchImport(importPath string, imports []*Import) (*Import, error) {
+	var match *Import
+	for _, imp := range imports {
+		if imp.Prefix != importPath && !strings.HasPrefix(importPath, imp.Prefix+"/") {
+			continue
+		}
+		if imp.VCS == "mod" {
+			// A module proxy, we need the repository
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("multiple go-import meta tags for [%s]", importPath)
+		}
+		match = imp
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no go-import meta tag for [%s]", importPath)
+	}
+	if match.VCS != "git" {
+		return nil, fmt.Errorf("[%s] is in a %s repository, klone only speaks git", match.Prefix, match.VCS)
+	}
+	if !strings.Contains(match.RepoURL, "://") {
+		return nil, fmt.Errorf("invalid repository url [%s] for [%s]", match.RepoURL, match.Prefix)
+	}
+	return match, nil
+}
+
+// ParseMetaImports will find the go-import meta tags in the <head> of an html page
+func ParseMetaImports(r io.Reader) ([]*Import, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	var imports []*Import
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF || len(imports) > 0 && err != nil {
+			return imports, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := t.(xml.StartElement); ok && strings.EqualFold(e.Name.Local, "body") {
+			return imports, nil
+		}
+		if e, ok := t.(xml.EndElement); ok && strings.EqualFold(e.Name.Local, "head") {
+			return imports, nil
+		}
+		e, ok := t.(xml.StartElement)
+		if !ok || !strings.EqualFold(e.Name.Local, "meta") || attr(e, "name") != "go-import" {
+			continue
+		}
+		fields := strings.Fields(attr(e, "content"))
+		if len(fields) == 3 {
+			imports = append(imports, &Import{Prefix: fields[0], VCS: fields[1], RepoURL: fields[2]})
+		}
+	}
+}
+
+// attr is the value of an attribute, or "" if the element does not have it
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if strings.EqualFold(a.Name.Local, name) {
+			return a.Value
+		}
+	}
+	return ""
+}
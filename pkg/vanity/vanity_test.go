@@ -0,0 +1,115 @@
+package vanity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>
+<meta name="go-import" content="golang.org/x/tools git https://go.googlesource.com/tools">
+<meta name="go-import" content="golang.org/x/tools mod https://proxy.golang.org">
+<meta name="go-source" content="golang.org/x/tools https://github.com/golang/tools/ https://github.com/golang/tools/tree/master{/dir} https://github.com/golang/tools/blob/master{/dir}/{file}#L{line}">
+</head>
+<body>
+<meta name="go-import" content="golang.org/x/tools git https://example.com/ignored">
+</body>
+</html>`
+
+func TestParseMetaImports(t *testing.T) {
+	imports, err := ParseMetaImports(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Unable to parse meta tags: %v", err)
+	}
+	if len(imports) != 2 {
+		t.Fatalf("Expected 2 imports, got %d", len(imports))
+	}
+	if imports[0].Prefix != "golang.org/x/tools" || imports[0].VCS != "git" || imports[0].RepoURL != "https://go.googlesource.com/tools" {
+		t.Fatalf("Unexpected import: %+v", imports[0])
+	}
+}
+
+func TestIsImportPath(t *testing.T) {
+	cases := map[string]bool{
+		"golang.org/x/tools":            true,
+		"k8s.io/client-go/kubernetes":   true,
+		"kubernetes/kubernetes":         false,
+		"klone":                         false,
+		"k8s.io":                        false,
+		"https://github.com/org/repo":   false,
+		"git@github.com:org/repo.git":   false,
+		"git.example.com:8443/org/repo": false,
+	}
+	for query, expected := range cases {
+		if actual := IsImportPath(query); actual != expected {
+			t.Errorf("Expected [%t] for [%s]", expected, query)
+		}
+	}
+}
+
+// newVanityServer will serve meta tags for every host, like a few vanity domains would
+func newVanityServer(t *testing.T) func() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("go-get") != "1" {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		switch r.Host {
+		case "golang.org":
+			fmt.Fprint(w, page)
+		case "k8s.io":
+			fmt.Fprint(w, `<html><head><meta name="go-import" content="k8s.io/client-go git https://github.com/kubernetes/client-go"></head></html>`)
+		case "hg.example.com":
+			fmt.Fprint(w, `<html><head><meta name="go-import" content="hg.example.com/lib hg https://hg.example.com/lib"></head></html>`)
+		default:
+			fmt.Fprint(w, `<html><head></head></html>`)
+		}
+	}))
+	scheme, client := Scheme, Client
+	Scheme = "http"
+	Client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, ts.Listener.Addr().String())
+		},
+	}}
+	return func() {
+		ts.Close()
+		Scheme, Client = scheme, client
+	}
+}
+
+func TestResolve(t *testing.T) {
+	defer newVanityServer(t)()
+	cases := map[string]*Import{
+		"golang.org/x/tools":          {Prefix: "golang.org/x/tools", VCS: "git", RepoURL: "https://go.googlesource.com/tools"},
+		"golang.org/x/tools/go/ssa":   {Prefix: "golang.org/x/tools", VCS: "git", RepoURL: "https://go.googlesource.com/tools"},
+		"k8s.io/client-go/kubernetes": {Prefix: "k8s.io/client-go", VCS: "git", RepoURL: "https://github.com/kubernetes/client-go"},
+	}
+	for importPath, expected := range cases {
+		imp, err := Resolve(importPath)
+		if err != nil {
+			t.Fatalf("Unable to resolve [%s]: %v", importPath, err)
+		}
+		if *imp != *expected {
+			t.Fatalf("Unexpected import for [%s]: %+v", importPath, imp)
+		}
+	}
+	invalid := map[string]string{
+		"golang.org/x/toolsmith": "no go-import meta tag",
+		"hg.example.com/lib":     "only speaks git",
+		"example.com/nothing":    "no go-import meta tag",
+	}
+	for importPath, expected := range invalid {
+		_, err := Resolve(importPath)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected [%s] for [%s], got: %v", expected, importPath, err)
+		}
+	}
+}